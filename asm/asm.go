@@ -0,0 +1,410 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"go6502/go6502"
+	"io"
+	"strings"
+)
+
+/*
+Assembler is a two-pass assembler for 6502 source code:
+
+	        .org $0801
+	SCREEN  = $D000
+	main:   ldx #0
+	@loop:  lda text,x      ; local labels start with @ and belong to last global label
+	        sta SCREEN,x
+	        inx
+	        cpx #5
+	        bne @loop
+	        rts
+	text:   .byte "hello"
+	        .word main, *+2
+
+First pass assigns addresses to labels and decides size of each instruction, second one
+emits bytes. Zero page addressing is used only when operand value is known in first pass.
+*/
+type Assembler struct {
+	Instructions *go6502.InstructionSet
+}
+
+// Error describes a problem in assembled source, with position where it was found.
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type statement struct {
+	line    int
+	source  string
+	label   string // fully qualified, local labels are prefixed with their scope
+	op      string // mnemonic (upper case), directive (lower case) or "=" for assignment
+	operand string
+	scope   string
+	mode    go6502.AddressingMode
+	size    int
+}
+
+type assembly struct {
+	*Assembler
+	file       string
+	statements []*statement
+	symbols    map[string]int
+	labels     map[string]bool
+	program    *Program
+	pass       int
+	pc         int
+}
+
+func (a *Assembler) Assemble(file string, source io.Reader) (*Program, error) {
+	as := &assembly{
+		Assembler: a,
+		file:      file,
+		symbols:   map[string]int{},
+		labels:    map[string]bool{},
+	}
+	if err := as.parse(source); err != nil {
+		return nil, err
+	}
+	for as.pass = 1; as.pass <= 2; as.pass++ {
+		as.program = newProgram(file)
+		if err := as.run(); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range as.symbols {
+		as.program.Symbols[name] = uint16(value)
+	}
+	for name := range as.labels {
+		as.program.labels[name] = true
+	}
+	return as.program, nil
+}
+
+// Assemble assembles source for NMOS 6502
+func Assemble(source string) (*Program, error) {
+	return New().Assemble("source", strings.NewReader(source))
+}
+
+// MustAssemble is like Assemble, but panics on error. Useful in tests.
+func MustAssemble(source string) *Program {
+	program, err := Assemble(source)
+	if err != nil {
+		panic(err)
+	}
+	return program
+}
+
+func New() *Assembler {
+	return &Assembler{
+		Instructions: &go6502.NMOS6502,
+	}
+}
+
+func (as *assembly) errorf(s *statement, format string, args ...interface{}) error {
+	return &Error{File: as.file, Line: s.line, Err: fmt.Errorf(format, args...)}
+}
+
+func (as *assembly) isOp(word string) bool {
+	return strings.HasPrefix(word, ".") || as.Instructions.HasMnemonic(strings.ToUpper(word))
+}
+
+func (as *assembly) parse(source io.Reader) error {
+	scanner := bufio.NewScanner(source)
+	scope := ""
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		s := &statement{line: lineNumber, source: scanner.Text()}
+		label, op, operand := splitStatement(s.source, as.isOp)
+		if label != "" {
+			if !isValidSymbol(label) {
+				return as.errorf(s, "invalid label %q", label)
+			}
+			if strings.HasPrefix(label, "@") {
+				if scope == "" {
+					return as.errorf(s, "local label %s without preceding global label", label)
+				}
+				label = scope + label
+			} else if op != "=" {
+				scope = label
+			}
+		}
+		s.label = label
+		s.scope = scope
+		s.operand = operand
+		if strings.HasPrefix(op, ".") || op == "=" {
+			s.op = strings.ToLower(op)
+		} else {
+			s.op = strings.ToUpper(op)
+		}
+		as.statements = append(as.statements, s)
+	}
+	return scanner.Err()
+}
+
+func (as *assembly) resolver(scope string) symbolResolver {
+	return func(name string) (int, bool) {
+		if strings.HasPrefix(name, "@") {
+			name = scope + name
+		}
+		value, ok := as.symbols[name]
+		return value, ok
+	}
+}
+
+func (as *assembly) evaluate(s *statement, expr string) (int, bool, error) {
+	value, known, err := evaluate(expr, as.pc, as.resolver(s.scope))
+	if err != nil {
+		return 0, false, as.errorf(s, "%v", err)
+	}
+	if !known && as.pass == 2 {
+		return 0, false, as.errorf(s, "undefined symbol in %q", expr)
+	}
+	return value, known, nil
+}
+
+func (as *assembly) define(s *statement, name string, value int) error {
+	if previous, ok := as.symbols[name]; ok && as.pass == 1 {
+		return as.errorf(s, "symbol %s already defined as $%04X", name, previous)
+	} else if ok && previous != value && as.labels[name] {
+		return as.errorf(s, "label %s changed value between passes ($%04X -> $%04X)", name, previous, value)
+	}
+	as.symbols[name] = value
+	return nil
+}
+
+func (as *assembly) run() error {
+	as.pc = 0
+	for _, s := range as.statements {
+		address := as.pc
+		if s.label != "" && s.op != "=" {
+			if err := as.define(s, s.label, as.pc); err != nil {
+				return err
+			}
+			as.labels[s.label] = true
+		}
+		bytes, err := as.runStatement(s)
+		if err != nil {
+			return err
+		}
+		if s.op == ".org" {
+			address = as.pc
+		}
+		if as.pc+len(bytes) > 0x10000 {
+			return as.errorf(s, "code doesn't fit in address space")
+		}
+		if as.pass == 2 {
+			as.program.emit(uint16(as.pc), bytes)
+			as.program.Listing = append(as.program.Listing, ListingLine{
+				Address: uint16(address),
+				Bytes:   bytes,
+				Line:    s.line,
+				Source:  s.source,
+			})
+		}
+		as.pc += len(bytes)
+	}
+	return nil
+}
+
+// runStatement returns bytes to emit. In first pass, only their count matters.
+func (as *assembly) runStatement(s *statement) ([]uint8, error) {
+	switch s.op {
+	case "":
+		return nil, nil
+	case "=":
+		value, known, err := as.evaluate(s, s.operand)
+		if err != nil {
+			return nil, err
+		}
+		if known {
+			return nil, as.define(s, s.label, value)
+		}
+		return nil, nil
+	case ".org":
+		value, known, err := as.evaluate(s, s.operand)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, as.errorf(s, ".org address must be known in first pass")
+		}
+		if value < 0 || value > 0xFFFF {
+			return nil, as.errorf(s, ".org address $%X out of range", value)
+		}
+		as.pc = value
+		return nil, nil
+	case ".byte":
+		return as.data(s, 1)
+	case ".word":
+		return as.data(s, 2)
+	}
+	if strings.HasPrefix(s.op, ".") {
+		return nil, as.errorf(s, "unknown directive %s", s.op)
+	}
+	return as.instruction(s)
+}
+
+func (as *assembly) data(s *statement, size int) ([]uint8, error) {
+	if s.operand == "" {
+		return nil, as.errorf(s, "%s needs at least one value", s.op)
+	}
+	var bytes []uint8
+	for _, item := range splitTopLevel(s.operand, ',') {
+		item = strings.TrimSpace(item)
+		if size == 1 && len(item) >= 2 && item[0] == '"' && item[len(item)-1] == '"' {
+			bytes = append(bytes, item[1:len(item)-1]...)
+			continue
+		}
+		value, _, err := as.evaluate(s, item)
+		if err != nil {
+			return nil, err
+		}
+		if size == 1 {
+			if value < -0x80 || value > 0xFF {
+				return nil, as.errorf(s, "value %d doesn't fit in byte", value)
+			}
+			bytes = append(bytes, uint8(value))
+		} else {
+			if value < -0x8000 || value > 0xFFFF {
+				return nil, as.errorf(s, "value %d doesn't fit in word", value)
+			}
+			bytes = append(bytes, uint8(value), uint8(value>>8))
+		}
+	}
+	return bytes, nil
+}
+
+func (as *assembly) instruction(s *statement) ([]uint8, error) {
+	kind, expr, err := parseOperand(s.operand)
+	if err != nil {
+		return nil, as.errorf(s, "%v", err)
+	}
+	if as.pass == 1 {
+		mode, err := as.selectMode(s, kind, expr)
+		if err != nil {
+			return nil, err
+		}
+		s.mode = mode
+		s.size = 1 + mode.OperandSize()
+		return make([]uint8, s.size), nil
+	}
+
+	opcode, _ := as.Instructions.Opcode(s.op, s.mode)
+	bytes := []uint8{opcode}
+	if s.mode.OperandSize() == 0 {
+		return bytes, nil
+	}
+	if kind == operandIndirect && s.mode != go6502.Indirect {
+		expr = s.operand // Not indirect, parentheses were just grouping an expression
+	}
+	value, _, err := as.evaluate(s, expr)
+	if err != nil {
+		return nil, err
+	}
+	switch s.mode {
+	case go6502.Immediate:
+		if value < -0x80 || value > 0xFF {
+			return nil, as.errorf(s, "immediate value %d doesn't fit in byte", value)
+		}
+		return append(bytes, uint8(value)), nil
+	case go6502.Relative:
+		offset := value - (as.pc + 2)
+		if offset < -128 || offset > 127 {
+			return nil, as.errorf(s, "branch target $%04X out of range (%d bytes)", value, offset)
+		}
+		return append(bytes, uint8(offset)), nil
+	}
+	if s.mode.OperandSize() == 1 {
+		if value < 0 || value > 0xFF {
+			return nil, as.errorf(s, "address $%X is not in zero page", value)
+		}
+		return append(bytes, uint8(value)), nil
+	}
+	if value < 0 || value > 0xFFFF {
+		return nil, as.errorf(s, "address $%X out of range", value)
+	}
+	return append(bytes, uint8(value), uint8(value>>8)), nil
+}
+
+func (as *assembly) has(s *statement, mode go6502.AddressingMode) bool {
+	_, ok := as.Instructions.Opcode(s.op, mode)
+	return ok
+}
+
+func (as *assembly) selectMode(s *statement, kind operandKind, expr string) (go6502.AddressingMode, error) {
+	if !as.Instructions.HasMnemonic(s.op) {
+		return 0, as.errorf(s, "unknown instruction %s", s.op)
+	}
+	candidates := map[operandKind][]go6502.AddressingMode{
+		operandNone:            {go6502.Implied, go6502.Accumulator},
+		operandAccumulator:     {go6502.Accumulator},
+		operandImmediate:       {go6502.Immediate},
+		operandIndexedIndirect: {go6502.IndexedIndirect},
+		operandIndirectIndexed: {go6502.IndirectIndexed},
+		operandIndirect:        {go6502.Indirect},
+	}
+	switch kind {
+	case operandIndirect:
+		if !as.has(s, go6502.Indirect) {
+			// Parentheses around whole operand, which is just an expression
+			return as.selectMode(s, operandDirect, s.operand)
+		}
+	case operandAccumulator:
+		if !as.has(s, go6502.Accumulator) {
+			return as.selectMode(s, operandDirect, expr)
+		}
+	case operandDirect:
+		if as.has(s, go6502.Relative) {
+			return go6502.Relative, nil
+		}
+		return as.selectSize(s, expr, go6502.ZeroPage, go6502.Absolute)
+	case operandIndexedX:
+		return as.selectSize(s, expr, go6502.ZeroPageX, go6502.AbsoluteX)
+	case operandIndexedY:
+		return as.selectSize(s, expr, go6502.ZeroPageY, go6502.AbsoluteY)
+	}
+	for _, mode := range candidates[kind] {
+		if as.has(s, mode) {
+			return mode, nil
+		}
+	}
+	return 0, as.errorf(s, "addressing mode not supported by %s", s.op)
+}
+
+func (as *assembly) selectSize(s *statement, expr string, zeroPage, absolute go6502.AddressingMode) (go6502.AddressingMode, error) {
+	value, known, err := as.evaluate(s, expr)
+	if err != nil {
+		return 0, err
+	}
+	hasZeroPage, hasAbsolute := as.has(s, zeroPage), as.has(s, absolute)
+	switch {
+	case hasZeroPage && (!hasAbsolute || known && value >= 0 && value <= 0xFF):
+		return zeroPage, nil
+	case hasAbsolute:
+		return absolute, nil
+	}
+	return 0, as.errorf(s, "addressing mode not supported by %s", s.op)
+}
+
+func isValidSymbol(name string) bool {
+	if name == "" || !isSymbolStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isSymbolChar(name[i]) || name[i] == '@' {
+			return false
+		}
+	}
+	return true
+}
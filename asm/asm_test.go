@@ -0,0 +1,207 @@
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"go6502/go6502"
+	"strings"
+	"testing"
+)
+
+func assertBytes(t *testing.T, program *Program, origin uint16, expected ...uint8) {
+	t.Helper()
+	start, image := program.Image()
+	if start != origin {
+		t.Fatalf("Program should start at %04X, got %04X", origin, start)
+	}
+	if !bytes.Equal(image, expected) {
+		t.Fatalf("Wrong program bytes. Expected % X, got % X", expected, image)
+	}
+}
+
+func TestAddressingModes(t *testing.T) {
+	program := MustAssemble(`
+		.org $1000
+		nop
+		asl
+		asl a
+		lda #$10
+		lda $10
+		lda $10,x
+		ldx $10,y
+		lda $1234
+		lda $1234,X
+		lda $1234, y
+		lda ($10,x)
+		lda ($10),y
+		jmp ($1234)
+		lda ($10+2)*2
+	`)
+	assertBytes(t, program, 0x1000,
+		0xEA,
+		0x0A,
+		0x0A,
+		0xA9, 0x10,
+		0xA5, 0x10,
+		0xB5, 0x10,
+		0xB6, 0x10,
+		0xAD, 0x34, 0x12,
+		0xBD, 0x34, 0x12,
+		0xB9, 0x34, 0x12,
+		0xA1, 0x10,
+		0xB1, 0x10,
+		0x6C, 0x34, 0x12,
+		0xA5, 0x24,
+	)
+}
+
+func TestLabelsAndBranches(t *testing.T) {
+	program := MustAssemble(`
+		.org $0200
+start:	ldx #3
+@loop:	dex
+		bne @loop
+		beq end      ; forward reference
+		jmp start
+end		rts
+other:
+@loop:	jmp @loop
+	`)
+	assertBytes(t, program, 0x0200,
+		0xA2, 0x03,
+		0xCA,
+		0xD0, 0xFD,
+		0xF0, 0x03,
+		0x4C, 0x00, 0x02,
+		0x60,
+		0x4C, 0x0B, 0x02,
+	)
+	if program.Symbols["start@loop"] != 0x0202 || program.Symbols["other@loop"] != 0x020B {
+		t.Fatalf("Local labels should be scoped by global label. Got %v", program.Symbols)
+	}
+}
+
+func TestForwardReferenceUsesAbsolute(t *testing.T) {
+	program := MustAssemble(`
+		lda value
+		lda ZP
+ZP = $20
+		lda ZP
+value:	.byte 1
+	`)
+	assertBytes(t, program, 0x0000,
+		0xAD, 0x08, 0x00,
+		0xAD, 0x20, 0x00, // size decided in first pass, before ZP was known
+		0xA5, 0x20,
+		0x01,
+	)
+}
+
+func TestExpressionsAndDirectives(t *testing.T) {
+	program := MustAssemble(`
+		*= $C000
+BASE = $1234
+		lda #<BASE
+		ldx #>BASE
+		ldy #%1010 | 1
+		lda #'A'+1
+		.byte "Hi;", 0, -1, BASE & $FF
+		.word BASE, * + 2, [2+3]*4
+	`)
+	assertBytes(t, program, 0xC000,
+		0xA9, 0x34,
+		0xA2, 0x12,
+		0xA0, 0x0B,
+		0xA9, 0x42,
+		'H', 'i', ';', 0x00, 0xFF, 0x34,
+		0x34, 0x12, 0x10, 0xC0, 0x14, 0x00,
+	)
+}
+
+func TestMultipleSegments(t *testing.T) {
+	program := MustAssemble(`
+		.org $10
+		.byte 1
+		.org $20
+		.byte 2
+	`)
+	if len(program.Segments) != 2 {
+		t.Fatalf("Each .org should start new segment. Got %v segments", len(program.Segments))
+	}
+	origin, image := program.Image()
+	if origin != 0x10 || len(image) != 0x11 || image[0] != 1 || image[0x10] != 2 {
+		t.Fatalf("Image should cover both segments, got %04X % X", origin, image)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	sources := map[string]string{
+		"unknown instruction": "  foo #1",
+		"undefined symbol":    "  lda missing",
+		"branch out of range": "start: nop\n .org $1000\n bne start",
+		"zero page":           "  lda ($1234),y",
+		"duplicate symbol":    "a: nop\na: nop",
+		"unsupported mode":    "  stx $1234,x",
+		"byte out of range":   "  lda #$100",
+		"unknown directive":   "  .foo 1",
+	}
+	for name, source := range sources {
+		_, err := Assemble(source)
+		var asmError *Error
+		if !errors.As(err, &asmError) {
+			t.Fatalf("%v: assembling %q should fail with position. Got %v", name, source, err)
+		}
+	}
+}
+
+func TestListingAndSymbols(t *testing.T) {
+	program := MustAssemble("\t.org $0801\nmain:\tlda #1 ; load\n\t.byte 1,2,3,4\n")
+
+	listing := strings.Builder{}
+	program.WriteListing(&listing)
+	expected := "" +
+		"    1                \t.org $0801\n" +
+		"    2 0801 A9 01     main:\tlda #1 ; load\n" +
+		"    3 0803 01 02 03  \t.byte 1,2,3,4\n" +
+		"    3 0806 04        \n"
+	if listing.String() != expected {
+		t.Fatalf("Wrong listing. Expected:\n%s\ngot:\n%s", expected, listing.String())
+	}
+
+	symbols := strings.Builder{}
+	program.WriteSymbols(&symbols)
+	if strings.TrimSpace(symbols.String()) != "main                     = $0801" {
+		t.Fatalf("Wrong symbol table, got %q", symbols.String())
+	}
+
+	info := program.DebugInfo()
+	if label, _ := info.Label(0x0801); label != "main" {
+		t.Fatalf("Debug info should contain labels. Expected %v, got %v", "main", label)
+	}
+	if line, _ := info.Line(0x0803); line.Line != 3 {
+		t.Fatalf("Debug info should contain lines. Expected %v, got %v", 3, line.Line)
+	}
+}
+
+func TestAssembledProgramRuns(t *testing.T) {
+	cpu := go6502.NewDefaultMemoryCPU()
+	MustAssemble(`
+		.org $FFFC
+		.word main
+		.org $0400
+main:	lda #$42
+		tax
+		inx
+		txa
+		sta result
+		rts
+result:	.byte 0
+	`).Load(cpu.Memory)
+	cpu.Initialize()
+	for i := 0; i < 5; i++ {
+		cpu.Advance()
+	}
+	if cpu.Memory.Get(0x0409) != 0x43 {
+		t.Fatalf("Program should store result. Expected %x, got %x", 0x43, cpu.Memory.Get(0x0409))
+	}
+}
@@ -0,0 +1,216 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expressions support numbers ($hex, %binary, decimal, 'c'), symbols, * as current address,
+// binary operators | ^ & << >> + - * / % (lowest to highest precedence),
+// unary - ~ < (low byte) > (high byte) and grouping with () or [].
+
+type symbolResolver func(name string) (int, bool)
+
+type exprParser struct {
+	text    string
+	pos     int
+	pc      int
+	resolve symbolResolver
+	known   bool // false if any used symbol was not defined yet
+}
+
+func evaluate(text string, pc int, resolve symbolResolver) (value int, known bool, err error) {
+	p := &exprParser{text: text, pc: pc, resolve: resolve, known: true}
+	value, err = p.parseBinary(0)
+	if err != nil {
+		return 0, false, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.text) {
+		return 0, false, fmt.Errorf("unexpected %q in expression %q", p.text[p.pos:], text)
+	}
+	return value, p.known, nil
+}
+
+var binaryOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) matchOperator(level int) (string, bool) {
+	p.skipSpaces()
+	for _, operator := range binaryOperators[level] {
+		if strings.HasPrefix(p.text[p.pos:], operator) {
+			p.pos += len(operator)
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseBinary(level int) (int, error) {
+	if level == len(binaryOperators) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		operator, ok := p.matchOperator(level)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch operator {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<":
+			left <<= uint(right)
+		case ">>":
+			left >>= uint(right)
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				if !p.known {
+					// Value of undefined symbol is a placeholder, don't fail before second pass
+					return 0, nil
+				}
+				return 0, fmt.Errorf("division by zero in %q", p.text)
+			}
+			if operator == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (int, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.text) {
+		return 0, fmt.Errorf("unexpected end of expression %q", p.text)
+	}
+	switch p.text[p.pos] {
+	case '-':
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	case '~':
+		p.pos++
+		value, err := p.parseUnary()
+		return ^value, err
+	case '<':
+		p.pos++
+		value, err := p.parseUnary()
+		return value & 0xFF, err
+	case '>':
+		p.pos++
+		value, err := p.parseUnary()
+		return (value >> 8) & 0xFF, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (int, error) {
+	c := p.text[p.pos]
+	switch {
+	case c == '(' || c == '[':
+		closing := byte(')')
+		if c == '[' {
+			closing = ']'
+		}
+		p.pos++
+		value, err := p.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.text) || p.text[p.pos] != closing {
+			return 0, fmt.Errorf("missing %q in expression %q", closing, p.text)
+		}
+		p.pos++
+		return value, nil
+	case c == '*':
+		p.pos++
+		return p.pc, nil
+	case c == '$':
+		p.pos++
+		return p.parseNumber(16, isHexDigit)
+	case c == '%':
+		p.pos++
+		return p.parseNumber(2, func(c byte) bool { return c == '0' || c == '1' })
+	case isDigit(c):
+		return p.parseNumber(10, isDigit)
+	case c == '\'':
+		if p.pos+2 >= len(p.text) || p.text[p.pos+2] != '\'' {
+			return 0, fmt.Errorf("invalid character constant in %q", p.text)
+		}
+		value := int(p.text[p.pos+1])
+		p.pos += 3
+		return value, nil
+	case isSymbolStart(c):
+		start := p.pos
+		for p.pos < len(p.text) && isSymbolChar(p.text[p.pos]) {
+			p.pos++
+		}
+		value, ok := p.resolve(p.text[start:p.pos])
+		if !ok {
+			p.known = false
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("unexpected %q in expression %q", p.text[p.pos:], p.text)
+}
+
+func (p *exprParser) parseNumber(base int, valid func(c byte) bool) (int, error) {
+	start := p.pos
+	for p.pos < len(p.text) && valid(p.text[p.pos]) {
+		p.pos++
+	}
+	value, err := strconv.ParseInt(p.text[start:p.pos], base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number in expression %q", p.text)
+	}
+	return int(value), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isSymbolStart(c byte) bool {
+	return c == '_' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSymbolChar(c byte) bool {
+	return isSymbolStart(c) || isDigit(c)
+}
@@ -0,0 +1,158 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+type operandKind int
+
+const (
+	operandNone            operandKind = iota
+	operandAccumulator                 // A
+	operandImmediate                   // #expr
+	operandDirect                      // expr - zero page, absolute or relative
+	operandIndexedX                    // expr,X
+	operandIndexedY                    // expr,Y
+	operandIndirect                    // (expr)
+	operandIndexedIndirect             // (expr,X)
+	operandIndirectIndexed             // (expr),Y
+)
+
+// splitStatement splits source line into label, operation and operand. Label has to end with
+// colon, unless it starts in first column. Assignments (NAME = expr, *= expr) are also recognized.
+func splitStatement(line string, isOp func(word string) bool) (label, op, operand string) {
+	line = stripComment(line)
+	if strings.TrimSpace(line) == "" {
+		return "", "", ""
+	}
+	indented := line[0] == ' ' || line[0] == '\t'
+	rest := strings.TrimSpace(line)
+
+	if strings.HasPrefix(rest, "*") && strings.HasPrefix(strings.TrimSpace(rest[1:]), "=") {
+		return "", ".org", strings.TrimSpace(strings.TrimSpace(rest[1:])[1:])
+	}
+
+	end := strings.IndexAny(rest, " \t:=")
+	if end == -1 {
+		end = len(rest)
+	}
+	word, after := rest[:end], strings.TrimSpace(rest[end:])
+	switch {
+	case strings.HasPrefix(after, ":"):
+		label, rest = word, strings.TrimSpace(after[1:])
+	case strings.HasPrefix(after, "="):
+		return word, "=", strings.TrimSpace(after[1:])
+	case !indented && !isOp(word):
+		label, rest = word, after
+	}
+
+	op = rest
+	if space := strings.IndexAny(rest, " \t"); space != -1 {
+		op, operand = rest[:space], rest[space+1:]
+	}
+	return label, op, strings.TrimSpace(operand)
+}
+
+func stripComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == '\'' && i+2 < len(line) && line[i+2] == '\'':
+			i += 2 // character constant, like ';'
+		case c == ';':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitTopLevel splits text on separator, which is not inside quotes or parentheses
+func splitTopLevel(text string, separator byte) []string {
+	var parts []string
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '\'' && i+2 < len(text) && text[i+2] == '\'':
+			i += 2
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == separator && depth == 0:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, text[start:])
+}
+
+// closingParen returns index of parenthesis closing the one opened at text[0]
+func closingParen(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseOperand(operand string) (operandKind, string, error) {
+	upper := strings.ToUpper(operand)
+	switch {
+	case operand == "":
+		return operandNone, "", nil
+	case upper == "A":
+		return operandAccumulator, operand, nil
+	case operand[0] == '#':
+		return operandImmediate, operand[1:], nil
+	case operand[0] == '(':
+		closing := closingParen(operand)
+		if closing == -1 {
+			return 0, "", fmt.Errorf("missing ')' in operand %q", operand)
+		}
+		inner := operand[1:closing]
+		suffix := strings.ToUpper(strings.ReplaceAll(operand[closing+1:], " ", ""))
+		if suffix == ",Y" {
+			return operandIndirectIndexed, inner, nil
+		}
+		if suffix == "" {
+			parts := splitTopLevel(inner, ',')
+			if len(parts) == 2 && strings.ToUpper(strings.TrimSpace(parts[1])) == "X" {
+				return operandIndexedIndirect, parts[0], nil
+			}
+			return operandIndirect, inner, nil
+		}
+	}
+
+	parts := splitTopLevel(operand, ',')
+	switch {
+	case len(parts) == 1:
+		return operandDirect, operand, nil
+	case len(parts) == 2 && strings.ToUpper(strings.TrimSpace(parts[1])) == "X":
+		return operandIndexedX, parts[0], nil
+	case len(parts) == 2 && strings.ToUpper(strings.TrimSpace(parts[1])) == "Y":
+		return operandIndexedY, parts[0], nil
+	}
+	return 0, "", fmt.Errorf("invalid operand %q", operand)
+}
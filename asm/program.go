@@ -0,0 +1,152 @@
+package asm
+
+import (
+	"fmt"
+	"go6502/go6502"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Segment is continuous block of assembled bytes
+type Segment struct {
+	Origin uint16
+	Data   []uint8
+}
+
+// ListingLine is one line of source together with address and bytes generated for it
+type ListingLine struct {
+	Address uint16
+	Bytes   []uint8
+	Line    int
+	Source  string
+}
+
+type Program struct {
+	File     string
+	Segments []Segment
+	Symbols  map[string]uint16
+	Listing  []ListingLine
+	labels   map[string]bool
+}
+
+func (p *Program) emit(address uint16, bytes []uint8) {
+	if len(bytes) == 0 {
+		return
+	}
+	if len(p.Segments) > 0 {
+		last := &p.Segments[len(p.Segments)-1]
+		if int(last.Origin)+len(last.Data) == int(address) {
+			last.Data = append(last.Data, bytes...)
+			return
+		}
+	}
+	p.Segments = append(p.Segments, Segment{Origin: address, Data: append([]uint8{}, bytes...)})
+}
+
+// Load copies all segments into memory
+func (p *Program) Load(memory *go6502.Memory) {
+	for _, segment := range p.Segments {
+		memory.Set(segment.Origin, segment.Data...)
+	}
+}
+
+// Image returns program as single flat binary starting at lowest used address.
+// Gaps between segments are filled with zeros.
+func (p *Program) Image() (uint16, []uint8) {
+	if len(p.Segments) == 0 {
+		return 0, nil
+	}
+	start, end := 0x10000, 0
+	for _, segment := range p.Segments {
+		if int(segment.Origin) < start {
+			start = int(segment.Origin)
+		}
+		if int(segment.Origin)+len(segment.Data) > end {
+			end = int(segment.Origin) + len(segment.Data)
+		}
+	}
+	image := make([]uint8, end-start)
+	for _, segment := range p.Segments {
+		copy(image[int(segment.Origin)-start:], segment.Data)
+	}
+	return uint16(start), image
+}
+
+// PRG returns program image prefixed with load address, as used by Commodore machines
+func (p *Program) PRG() []uint8 {
+	origin, image := p.Image()
+	return append([]uint8{uint8(origin), uint8(origin >> 8)}, image...)
+}
+
+// DebugInfo returns symbols and source lines of program, so they can be used by tracer or debugger
+func (p *Program) DebugInfo() *go6502.DebugInfo {
+	info := go6502.NewDebugInfo()
+	for name, value := range p.Symbols {
+		if p.labels[name] {
+			info.AddLabel(name, value)
+		} else {
+			info.AddSymbol(name, value)
+		}
+	}
+	for _, line := range p.Listing {
+		if len(line.Bytes) > 0 {
+			info.AddLine(line.Address, p.File, line.Line)
+		}
+	}
+	return info
+}
+
+func (p *Program) WriteListing(w io.Writer) error {
+	for _, line := range p.Listing {
+		bytes := line.Bytes
+		if len(bytes) == 0 {
+			if _, err := fmt.Fprintf(w, "%5d %4s %-9s %s\n", line.Line, "", "", line.Source); err != nil {
+				return err
+			}
+			continue
+		}
+		for row := 0; len(bytes) > 0; row++ {
+			chunk := bytes
+			if len(chunk) > 3 {
+				chunk = chunk[:3]
+			}
+			bytes = bytes[len(chunk):]
+			hex := make([]string, len(chunk))
+			for i, b := range chunk {
+				hex[i] = fmt.Sprintf("%02X", b)
+			}
+			source := line.Source
+			if row > 0 {
+				source = ""
+			}
+			_, err := fmt.Fprintf(w, "%5d %04X %-9s %s\n", line.Line, int(line.Address)+row*3, strings.Join(hex, " "), source)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Program) WriteSymbols(w io.Writer) error {
+	names := make([]string, 0, len(p.Symbols))
+	for name := range p.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%-24s = $%04X\n", name, p.Symbols[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newProgram(file string) *Program {
+	return &Program{
+		File:    file,
+		Symbols: map[string]uint16{},
+		labels:  map[string]bool{},
+	}
+}
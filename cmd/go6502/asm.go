@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go6502/asm"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runAssembler(args []string) error {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default: source name with .bin or .prg extension)")
+	prg := flags.Bool("prg", false, "write PRG file with load address header instead of flat binary")
+	listing := flags.String("l", "", "write listing to file")
+	symbols := flags.String("s", "", "write symbol table to file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 asm [options] source.s\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	sourceFile := flags.Arg(0)
+	source, err := os.Open(sourceFile)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	program, err := asm.New().Assemble(filepath.Base(sourceFile), source)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		extension := ".bin"
+		if *prg {
+			extension = ".prg"
+		}
+		*output = strings.TrimSuffix(sourceFile, filepath.Ext(sourceFile)) + extension
+	}
+	data := program.PRG()
+	if !*prg {
+		_, data = program.Image()
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+
+	if *listing != "" {
+		if err := writeFile(*listing, program.WriteListing); err != nil {
+			return err
+		}
+	}
+	if *symbols != "" {
+		if err := writeFile(*symbols, program.WriteSymbols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
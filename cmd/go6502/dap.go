@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go6502/dap"
+	"go6502/go6502"
+	"io"
+	"os"
+)
+
+func runDAPServer(args []string) error {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	address := flags.String("listen", "", "TCP address to listen on, instead of stdin and stdout")
+	origin := flags.String("origin", "", "address where binary image is loaded")
+	dbgFile := flags.String("dbg", "", "ld65 debug file with symbols and source lines")
+	mapFile := flags.String("map", "", "ld65 map file with symbols")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 dap [options] [image.prg | source.s | binary]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cpu := go6502.NewDefaultMemoryCPU()
+	debug, err := loadDebugInfo(*dbgFile, *mapFile)
+	if err != nil {
+		return err
+	}
+	// Stdout carries protocol messages, so everything else goes to stderr
+	if err := loadImage(cpu, debug, flags.Args(), *origin, os.Stderr); err != nil {
+		return err
+	}
+
+	server := dap.NewServer(cpu, debug)
+	if *address != "" {
+		fmt.Fprintf(os.Stderr, "Waiting for debugger on %s\n", *address)
+		return server.ListenAndServe("tcp", *address)
+	}
+	return server.ServeConn(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout})
+}
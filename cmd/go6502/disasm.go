@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go6502/go6502"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func runDisassembler(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	origin := flags.String("origin", "0", "address where binary is loaded, like $0801 or 0x0801")
+	prg := flags.Bool("prg", false, "file is PRG, load address is taken from its header")
+	dbgFile := flags.String("dbg", "", "ld65 debug file with symbols")
+	mapFile := flags.String("map", "", "ld65 map file with symbols")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 disasm [options] binary\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	memory := go6502.DefaultMemory()
+	var start uint16
+	var size int
+	if *prg {
+		start, err = go6502.LoadPRG(memory, bytes.NewReader(data))
+		size = len(data) - 2
+	} else {
+		start, err = parseAddress(*origin)
+		if err != nil {
+			return err
+		}
+		size, err = go6502.LoadBinary(memory, start, bytes.NewReader(data))
+	}
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	debug, err := loadDebugInfo(*dbgFile, *mapFile)
+	if err != nil {
+		return err
+	}
+	for _, line := range go6502.NewDisassembler(debug).Disassemble(memory, start, start+uint16(size-1)) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// parseAddress accepts $hex, 0xhex and decimal numbers
+func parseAddress(text string) (uint16, error) {
+	if strings.HasPrefix(text, "$") {
+		text = "0x" + text[1:]
+	}
+	value, err := strconv.ParseUint(text, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", text)
+	}
+	return uint16(value), nil
+}
+
+func loadDebugInfo(dbgFile, mapFile string) (*go6502.DebugInfo, error) {
+	debug := go6502.NewDebugInfo()
+	if dbgFile != "" {
+		if err := mergeDebugFile(debug, dbgFile, go6502.ParseLd65Debug); err != nil {
+			return nil, err
+		}
+	}
+	if mapFile != "" {
+		if err := mergeDebugFile(debug, mapFile, go6502.ParseLd65Map); err != nil {
+			return nil, err
+		}
+	}
+	return debug, nil
+}
+
+func mergeDebugFile(debug *go6502.DebugInfo, name string, parse func(r io.Reader) (*go6502.DebugInfo, error)) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := parse(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	debug.Merge(info)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go6502/gdb"
+	"go6502/go6502"
+	"go6502/internal/loader"
+	"io"
+	"os"
+)
+
+func runGDBServer(args []string) error {
+	flags := flag.NewFlagSet("gdb", flag.ExitOnError)
+	address := flags.String("listen", "localhost:2345", "TCP address to listen on")
+	socket := flags.String("unix", "", "listen on unix socket instead of TCP")
+	origin := flags.String("origin", "", "address where binary image is loaded")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 gdb [options] [image.prg | source.s | binary]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cpu := go6502.NewDefaultMemoryCPU()
+	if err := loadImage(cpu, go6502.NewDebugInfo(), flags.Args(), *origin, os.Stdout); err != nil {
+		return err
+	}
+
+	server := gdb.NewServer(cpu)
+	if *socket != "" {
+		fmt.Printf("Waiting for debugger on %s\n", *socket)
+		return server.ListenAndServe("unix", *socket)
+	}
+	fmt.Printf("Waiting for debugger on %s\n", *address)
+	return server.ListenAndServe("tcp", *address)
+}
+
+// loadImage loads optional image given on command line and reports its location to log
+func loadImage(cpu *go6502.CPU, debug *go6502.DebugInfo, files []string, origin string, log io.Writer) error {
+	if len(files) == 0 {
+		return nil
+	}
+	var address []uint16
+	if origin != "" {
+		value, err := parseAddress(origin)
+		if err != nil {
+			return err
+		}
+		address = append(address, value)
+	}
+	start, size, err := loader.Image(cpu, debug, files[0], address...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "Loaded %d bytes at %04X\n", size, start)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"asm", "assemble 6502 source file", runAssembler},
+	{"disasm", "disassemble binary file", runDisassembler},
+	{"monitor", "interactive debugger and memory monitor", runMonitor},
+	{"gdb", "serve CPU to GDB remote protocol debuggers", runGDBServer},
+	{"dap", "serve CPU to editors over debug adapter protocol", runDAPServer},
+	{"screenshot", "run program without window and save screen as PNG", runScreenshot},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: go6502 <command> [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'go6502 <command> -h' for command arguments.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "go6502 %s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go6502/go6502"
+	"go6502/monitor"
+	"os"
+	"os/signal"
+)
+
+func runMonitor(args []string) error {
+	flags := flag.NewFlagSet("monitor", flag.ExitOnError)
+	origin := flags.String("origin", "", "address where binary image is loaded")
+	dbgFile := flags.String("dbg", "", "ld65 debug file with symbols and source lines")
+	mapFile := flags.String("map", "", "ld65 map file with symbols")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 monitor [options] [image.prg | source.s | binary]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cpu := go6502.NewDefaultMemoryCPU()
+	m := monitor.New(cpu, os.Stdout)
+	debug, err := loadDebugInfo(*dbgFile, *mapFile)
+	if err != nil {
+		return err
+	}
+	m.Debug.Merge(debug)
+	if err := loadImage(cpu, m.Debug, flags.Args(), *origin, os.Stdout); err != nil {
+		return err
+	}
+
+	// Ctrl-C stops running program instead of quitting monitor
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			m.Interrupt()
+		}
+	}()
+	return m.Run(os.Stdin)
+}
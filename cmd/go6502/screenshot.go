@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go6502/go6502"
+	"os"
+)
+
+func runScreenshot(args []string) error {
+	flags := flag.NewFlagSet("screenshot", flag.ExitOnError)
+	origin := flags.String("origin", "", "address where binary image is loaded")
+	screenStart := flags.String("screen", "$D000", "address of screen memory")
+	instructions := flags.Int("n", 100000, "number of instructions to run")
+	output := flags.String("o", "screen.png", "PNG file to write")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: go6502 screenshot [options] image.prg | source.s | binary\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	start, err := parseAddress(*screenStart)
+	if err != nil {
+		return err
+	}
+
+	// RAM is mapped around screen
+	screen := go6502.NewScreen(start)
+	cpu := go6502.NewCPU(go6502.NewMemory(screen, go6502.NewRAM(0, 0x10000)))
+	if err := loadImage(cpu, go6502.NewDebugInfo(), flags.Args(), *origin, os.Stdout); err != nil {
+		return err
+	}
+	for i := 0; i < *instructions && !cpu.State.Halted(); i++ {
+		cpu.Advance()
+	}
+	return screen.SavePNG(*output)
+}
@@ -0,0 +1,212 @@
+/*
+Package cputest helps to write short CPU tests. State of machine is described by values like A(0x42),
+Carry(true) or Memory(0x0200, 0xA9, 0x42), which are used both to set up CPU and to check it:
+
+	m := cputest.New(t, cputest.Program(0x0200, go6502.OpLDA_imm, 0x42), cputest.Carry(true))
+	m.Run(1).Expect(cputest.A(0x42), cputest.PC(0x0202), cputest.Zero(false))
+
+Expect reports all mismatches of a run in one failure.
+*/
+package cputest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go6502/go6502"
+)
+
+// DefaultLimit is maximum number of instructions executed by RunUntil
+const DefaultLimit = 1000000
+
+// State is a part of machine state, e.g. value of register, flag or memory
+type State interface {
+	apply(cpu *go6502.CPU)
+	// diff describes difference of CPU from the state, it's empty when they match
+	diff(cpu *go6502.CPU) []string
+}
+
+type stateFunc struct {
+	set   func(cpu *go6502.CPU)
+	check func(cpu *go6502.CPU) []string
+}
+
+func (s *stateFunc) apply(cpu *go6502.CPU)         { s.set(cpu) }
+func (s *stateFunc) diff(cpu *go6502.CPU) []string { return s.check(cpu) }
+
+func compare(name string, expected interface{}, actual interface{}) []string {
+	if expected == actual {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: expected %v, got %v", name, expected, actual)}
+}
+
+type hex8 uint8
+
+func (h hex8) String() string { return fmt.Sprintf("%02X", uint8(h)) }
+
+type hex16 uint16
+
+func (h hex16) String() string { return fmt.Sprintf("%04X", uint16(h)) }
+
+type flagsValue uint8
+
+func (f flagsValue) String() string {
+	flags := go6502.Flags{}
+	flags.SetValue(uint8(f))
+	return fmt.Sprintf("%02X (%v)", uint8(f), &flags)
+}
+
+func register8(name string, value uint8, register func(cpu *go6502.CPU) *uint8) State {
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) { *register(cpu) = value },
+		check: func(cpu *go6502.CPU) []string {
+			return compare(name, hex8(value), hex8(*register(cpu)))
+		},
+	}
+}
+
+func A(value uint8) State {
+	return register8("A", value, func(cpu *go6502.CPU) *uint8 { return &cpu.A })
+}
+
+func X(value uint8) State {
+	return register8("X", value, func(cpu *go6502.CPU) *uint8 { return &cpu.X })
+}
+
+func Y(value uint8) State {
+	return register8("Y", value, func(cpu *go6502.CPU) *uint8 { return &cpu.Y })
+}
+
+func S(value uint8) State {
+	return register8("S", value, func(cpu *go6502.CPU) *uint8 { return &cpu.S })
+}
+
+func PC(value uint16) State {
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) { cpu.PC = value },
+		check: func(cpu *go6502.CPU) []string {
+			return compare("PC", hex16(value), hex16(cpu.PC))
+		},
+	}
+}
+
+// Flags is value of whole status register, break and unused bits are ignored as by PLP
+func Flags(value uint8) State {
+	expected := go6502.Flags{}
+	expected.SetValue(value)
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) { cpu.Flags.SetValue(value) },
+		check: func(cpu *go6502.CPU) []string {
+			return compare("Flags", flagsValue(expected.Value()), flagsValue(cpu.Flags.Value()))
+		},
+	}
+}
+
+func flag(name string, index int, value bool) State {
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) { cpu.Flags.SetFlag(index, value) },
+		check: func(cpu *go6502.CPU) []string {
+			return compare(name, value, cpu.Flags.HasFlag(index))
+		},
+	}
+}
+
+func Carry(value bool) State            { return flag("Carry", 0, value) }
+func Zero(value bool) State             { return flag("Zero", 1, value) }
+func InterruptDisable(value bool) State { return flag("InterruptDisable", 2, value) }
+func Decimal(value bool) State          { return flag("Decimal", 3, value) }
+func Overflow(value bool) State         { return flag("Overflow", 6, value) }
+func Negative(value bool) State         { return flag("Negative", 7, value) }
+
+// Memory is contents of memory from address
+func Memory(address uint16, values ...uint8) State {
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) { cpu.Memory.Set(address, values...) },
+		check: func(cpu *go6502.CPU) []string {
+			var lines []string
+			for i, value := range values {
+				location := address + uint16(i)
+				lines = append(lines, compare(fmt.Sprintf("$%04X", location), hex8(value), hex8(cpu.Memory.Get(location)))...)
+			}
+			return lines
+		},
+	}
+}
+
+// Program is code loaded at address, PC is set to its start
+func Program(address uint16, code ...uint8) State {
+	memory, pc := Memory(address, code...), PC(address)
+	return &stateFunc{
+		set: func(cpu *go6502.CPU) {
+			memory.apply(cpu)
+			pc.apply(cpu)
+		},
+		check: memory.diff,
+	}
+}
+
+// Machine runs CPU in test and checks its state, failing the test on mismatch
+type Machine struct {
+	*go6502.CPU
+	t testing.TB
+}
+
+// New creates machine with 64 KB of RAM and given state
+func New(t testing.TB, states ...State) *Machine {
+	return ForCPU(t, go6502.NewCPU(go6502.NewMemory(go6502.NewRAM(0, 0x10000))), states...)
+}
+
+// ForCPU creates machine for CPU with custom memory map, e.g. with devices, and sets given state
+func ForCPU(t testing.TB, cpu *go6502.CPU, states ...State) *Machine {
+	m := &Machine{CPU: cpu, t: t}
+	m.Set(states...)
+	return m
+}
+
+// Set changes state of machine
+func (m *Machine) Set(states ...State) *Machine {
+	for _, state := range states {
+		state.apply(m.CPU)
+	}
+	return m
+}
+
+// Run executes given number of instructions
+func (m *Machine) Run(instructions int) *Machine {
+	for i := 0; i < instructions; i++ {
+		m.Advance()
+	}
+	return m
+}
+
+// RunUntil executes instructions until PC reaches pc, test fails when it doesn't in DefaultLimit instructions
+func (m *Machine) RunUntil(pc uint16) *Machine {
+	m.t.Helper()
+	for i := 0; m.PC != pc; i++ {
+		if i == DefaultLimit {
+			m.t.Fatalf("PC should reach %04X, but it's %04X after %d instructions", pc, m.PC, DefaultLimit)
+		}
+		m.Advance()
+	}
+	return m
+}
+
+// Diff lists differences of machine from expected state, one per line
+func (m *Machine) Diff(states ...State) string {
+	var lines []string
+	for _, state := range states {
+		lines = append(lines, state.diff(m.CPU)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Expect fails the test when machine doesn't match expected state
+func (m *Machine) Expect(states ...State) *Machine {
+	m.t.Helper()
+	if diff := m.Diff(states...); diff != "" {
+		m.t.Fatalf("Unexpected state:\n%s\n%v", diff, m.CPU)
+	}
+	return m
+}
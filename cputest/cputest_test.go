@@ -0,0 +1,34 @@
+package cputest
+
+import (
+	"testing"
+
+	"go6502/go6502"
+)
+
+func TestRun(t *testing.T) {
+	m := New(t, Program(0x0200, go6502.OpLDA_imm, 0x80, go6502.OpSTA_zeropage, 0x10, go6502.OpINX), X(0xFF))
+	m.Run(3).Expect(A(0x80), X(0x00), PC(0x0205), Memory(0x0010, 0x80), Zero(true), Negative(false))
+}
+
+func TestRunUntil(t *testing.T) {
+	m := New(t,
+		Program(0x0200, go6502.OpLDX_imm, 0x05, go6502.OpDEX, go6502.OpBNE, 0xFD, go6502.OpNOOP),
+		Flags(0x00))
+	m.RunUntil(0x0205).Expect(X(0x00), Flags(0x02))
+}
+
+func TestDiff(t *testing.T) {
+	m := New(t, A(0x42), Memory(0x0300, 0x01, 0x02), Carry(true))
+	expected := "A: expected 43, got 42\n" +
+		"$0301: expected 03, got 02\n" +
+		"Carry: expected false, got true\n" +
+		"Flags: expected A0 (Nv1bdizc), got 21 (nv1bdizC)"
+	diff := m.Diff(A(0x43), Memory(0x0300, 0x01, 0x03), Carry(false), Flags(0x80))
+	if diff != expected {
+		t.Fatalf("Diff should list every mismatch. Expected\n%s\ngot\n%s", expected, diff)
+	}
+	if diff := m.Diff(A(0x42), Carry(true)); diff != "" {
+		t.Fatalf("Matching state shouldn't have diff, got\n%s", diff)
+	}
+}
@@ -0,0 +1,748 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go6502/go6502"
+	"go6502/internal/loader"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+Server implements Debug Adapter Protocol for emulated CPU. CPU registers and flags are
+exposed as variables, memory can be read through memory references (addresses like
+"0x0200" or symbol names) and call stack is decoded from return addresses pushed by JSR.
+Source breakpoints need line info of loaded program, from assembler or ld65 debug file.
+*/
+type Server struct {
+	CPU   *go6502.CPU
+	Debug *go6502.DebugInfo
+	// Breakpoint addresses by source path, as debugger always sets all breakpoints of a file
+	sources     map[string][]uint16
+	breakpoints map[uint16]bool
+}
+
+const threadID = 1
+
+// Variable references of scopes
+const (
+	registersReference = 1
+	flagsReference     = 2
+)
+
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type source struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type stackFrame struct {
+	ID                          int     `json:"id"`
+	Name                        string  `json:"name"`
+	Source                      *source `json:"source,omitempty"`
+	Line                        int     `json:"line"`
+	Column                      int     `json:"column"`
+	InstructionPointerReference string  `json:"instructionPointerReference"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+type breakpoint struct {
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line"`
+	Message  string `json:"message,omitempty"`
+}
+
+// incoming is either request from client or error reading it
+type incoming struct {
+	request request
+	err     error
+}
+
+type session struct {
+	*Server
+	w           io.Writer
+	seq         int
+	requests    chan incoming
+	stopOnEntry bool
+	running     bool
+	// resume is execution started by last request, it's run after response is sent
+	resume func() error
+	done   bool
+}
+
+var errRunning = errors.New("program is running")
+
+// ListenAndServe listens on "tcp" address like localhost:4711 or "unix" socket path
+func (s *Server) ListenAndServe(network, address string) error {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	return s.Serve(listener)
+}
+
+// Serve accepts debugger connections one after another
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		err = s.ServeConn(conn)
+		conn.Close()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+}
+
+// ServeConn handles single debugging session until client disconnects. For stdio,
+// pass reader and writer combined, e.g. struct{ io.Reader; io.Writer }{os.Stdin, os.Stdout}.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	session := &session{
+		Server:   s,
+		w:        conn,
+		requests: make(chan incoming),
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go readRequests(conn, session.requests, done)
+
+	for r := range session.requests {
+		if r.err != nil {
+			return r.err
+		}
+		if err := session.handle(r.request); err != nil {
+			return err
+		}
+		for session.resume != nil && !session.done {
+			resume := session.resume
+			session.resume = nil
+			if err := resume(); err != nil {
+				return err
+			}
+		}
+		if session.done {
+			return nil
+		}
+	}
+	return nil
+}
+
+func readRequests(conn io.Reader, requests chan<- incoming, done <-chan struct{}) {
+	reader := bufio.NewReader(conn)
+	for {
+		var r incoming
+		data, err := readMessage(reader)
+		if err == nil {
+			err = json.Unmarshal(data, &r.request)
+		}
+		r.err = err
+		select {
+		case requests <- r:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readMessage reads message body framed by Content-Length header
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("dap: invalid content length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("dap: missing Content-Length header")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(reader, data)
+	return data, err
+}
+
+func (s *session) send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *session) respond(r request, body interface{}, err error) error {
+	s.seq++
+	reply := response{Seq: s.seq, Type: "response", RequestSeq: r.Seq, Success: err == nil, Command: r.Command, Body: body}
+	if err != nil {
+		reply.Message = err.Error()
+	}
+	return s.send(reply)
+}
+
+func (s *session) event(name string, body interface{}) error {
+	s.seq++
+	return s.send(event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func (s *session) stopped(reason string) error {
+	return s.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+}
+
+// handle responds to request, execution requests set resume to be run afterwards
+func (s *session) handle(r request) error {
+	var body interface{}
+	var err error
+	switch r.Command {
+	case "initialize":
+		body = map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsSetVariable":              true,
+			"supportsReadMemoryRequest":        true,
+			"supportsWriteMemoryRequest":       true,
+			"supportsEvaluateForHovers":        true,
+			"supportsStepBack":                 true,
+		}
+		if err := s.respond(r, body, nil); err != nil {
+			return err
+		}
+		return s.event("initialized", nil)
+	case "launch", "attach":
+		err = s.launch(r.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(r.Arguments)
+	case "configurationDone":
+		if s.stopOnEntry {
+			s.resume = func() error { return s.stopped("entry") }
+		} else {
+			s.resume = func() error { return s.run("breakpoint", func() bool { return false }) }
+		}
+	case "threads":
+		body = map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "6502"}},
+		}
+	case "stackTrace":
+		body = map[string]interface{}{"stackFrames": s.stackTrace()}
+	case "scopes":
+		body = map[string]interface{}{"scopes": []map[string]interface{}{
+			{"name": "Registers", "variablesReference": registersReference, "expensive": false},
+			{"name": "Flags", "variablesReference": flagsReference, "expensive": false},
+		}}
+	case "variables":
+		body, err = s.variables(r.Arguments)
+	case "setVariable":
+		body, err = s.setVariable(r.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(r.Arguments)
+	case "readMemory":
+		body, err = s.readMemory(r.Arguments)
+	case "writeMemory":
+		body, err = s.writeMemory(r.Arguments)
+	case "continue":
+		body = map[string]bool{"allThreadsContinued": true}
+		err = s.execute(func() error { return s.run("breakpoint", func() bool { return false }) })
+	case "next":
+		err = s.execute(s.next)
+	case "stepIn":
+		err = s.execute(func() error { return s.run("step", func() bool { return true }) })
+	case "stepOut":
+		err = s.execute(s.stepOut)
+	case "stepBack":
+		err = s.execute(func() error { return s.reverse(s.CPU.History.StepBack(), "step") })
+	case "reverseContinue":
+		err = s.execute(func() error {
+			return s.reverse(s.CPU.History.RunBack(func(cpu *go6502.CPU) bool { return s.breakpoints[cpu.PC] }), "breakpoint")
+		})
+	case "pause":
+		if !s.running {
+			err = fmt.Errorf("program isn't running")
+		}
+	case "disconnect", "terminate":
+		s.done = true
+	default:
+		err = fmt.Errorf("unsupported command %q", r.Command)
+	}
+	return s.respond(r, body, err)
+}
+
+// execute schedules execution after response, unless program is already running
+func (s *session) execute(run func() error) error {
+	if s.running {
+		return errRunning
+	}
+	s.resume = run
+	return nil
+}
+
+// run executes instructions until stop returns true, breakpoint is hit or client pauses.
+// Requests received meanwhile are handled, so breakpoints can be changed while running.
+func (s *session) run(reason string, stop func() bool) error {
+	s.running = true
+	defer func() { s.running = false }()
+	for i := 1; ; i++ {
+		s.CPU.Advance()
+		if stop() {
+			return s.stopped(reason)
+		}
+		if s.breakpoints[s.CPU.PC] {
+			return s.stopped("breakpoint")
+		}
+		if s.CPU.State != go6502.Running {
+			return s.stopped("exception")
+		}
+		if i%1000 != 0 {
+			continue
+		}
+		select {
+		case r := <-s.requests:
+			if r.err != nil {
+				return r.err
+			}
+			if err := s.handle(r.request); err != nil {
+				return err
+			}
+			if s.done {
+				return nil
+			}
+			if r.request.Command == "pause" {
+				return s.stopped("pause")
+			}
+		default:
+		}
+	}
+}
+
+// reverse reports result of going back in history, which can stop at its beginning
+func (s *session) reverse(found bool, reason string) error {
+	if !found {
+		return s.stopped("entry")
+	}
+	return s.stopped(reason)
+}
+
+// next steps over subroutine calls
+func (s *session) next() error {
+	if s.CPU.Memory.Get(s.CPU.PC) != go6502.OpJSR_absolute {
+		return s.run("step", func() bool { return true })
+	}
+	returnAddress := s.CPU.PC + 3
+	stack := s.CPU.S
+	return s.run("step", func() bool { return s.CPU.PC == returnAddress && popped(s.CPU.S, stack) >= 0 })
+}
+
+// stepOut runs until RTS or RTI pulls return address from above stack pointer at start,
+// so bytes pushed and pulled by subroutine and nested calls don't stop it
+func (s *session) stepOut() error {
+	stack := s.CPU.S
+	returning := s.returning()
+	return s.run("step", func() bool {
+		if returning && popped(s.CPU.S, stack) >= 2 {
+			return true
+		}
+		returning = s.returning()
+		return false
+	})
+}
+
+// returning tells if the next instruction returns from subroutine or interrupt
+func (s *session) returning() bool {
+	opcode := s.CPU.Memory.Get(s.CPU.PC)
+	return opcode == go6502.OpRTS || opcode == go6502.OpRTI
+}
+
+// popped returns number of bytes pulled from stack since it was at stack, negative if bytes
+// were pushed. Stack pointer wraps around within the stack page.
+func popped(s uint8, stack uint8) int {
+	return int(int8(s - stack))
+}
+
+type launchArguments struct {
+	Program     string `json:"program"`
+	Origin      string `json:"origin"`
+	Symbols     string `json:"symbols"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+}
+
+// launch loads optional program (PRG, assembler source or binary at origin) and symbol files
+func (s *session) launch(data json.RawMessage) error {
+	var args launchArguments
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &args); err != nil {
+			return err
+		}
+	}
+	s.stopOnEntry = args.StopOnEntry
+	if args.Symbols != "" {
+		if err := loader.Symbols(s.Debug, args.Symbols); err != nil {
+			return err
+		}
+	}
+	if args.Program == "" {
+		return nil
+	}
+	var origin []uint16
+	if args.Origin != "" {
+		value, err := s.parseValue(args.Origin)
+		if err != nil {
+			return err
+		}
+		origin = append(origin, value)
+	}
+	_, _, err := loader.Image(s.CPU, s.Debug, args.Program, origin...)
+	return err
+}
+
+func (s *session) setBreakpoints(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	var addresses []uint16
+	breakpoints := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		address, ok := s.Debug.LineAddress(args.Source.Path, b.Line)
+		if !ok {
+			breakpoints = append(breakpoints, breakpoint{Line: b.Line, Message: "no code at this line"})
+			continue
+		}
+		addresses = append(addresses, address)
+		breakpoints = append(breakpoints, breakpoint{Verified: true, Line: b.Line})
+	}
+	s.sources[args.Source.Path] = addresses
+	s.breakpoints = map[uint16]bool{}
+	for _, addresses := range s.sources {
+		for _, address := range addresses {
+			s.breakpoints[address] = true
+		}
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// callStack decodes JSR return addresses from stack page and returns addresses of calls,
+// innermost first. Value on stack is treated as return address only if JSR is before it.
+func (s *session) callStack() []uint16 {
+	var calls []uint16
+	memory := s.CPU.Memory
+	for address := 0x0100 + int(s.CPU.S) + 1; address < 0x01FF; address++ {
+		pushed := uint16(memory.Get(uint16(address))) + uint16(memory.Get(uint16(address+1)))<<8
+		call := pushed - 2
+		if memory.Get(call) == go6502.OpJSR_absolute {
+			calls = append(calls, call)
+			address++
+		}
+	}
+	return calls
+}
+
+func (s *session) stackTrace() []stackFrame {
+	calls := s.callStack()
+	addresses := append([]uint16{s.CPU.PC}, calls...)
+	frames := make([]stackFrame, len(addresses))
+	for i, address := range addresses {
+		// Frame is named by subroutine it's in, which is target of the call from outer frame
+		name := s.describe(address)
+		if i < len(calls) {
+			memory := s.CPU.Memory
+			name = s.describe(uint16(memory.Get(calls[i]+1)) + uint16(memory.Get(calls[i]+2))<<8)
+		}
+		frames[i] = stackFrame{
+			ID:                          i,
+			Name:                        name,
+			InstructionPointerReference: fmt.Sprintf("0x%04X", address),
+		}
+		if line, ok := s.Debug.Line(address); ok {
+			path, err := filepath.Abs(line.File)
+			if err != nil {
+				path = line.File
+			}
+			frames[i].Source = &source{Name: filepath.Base(line.File), Path: path}
+			frames[i].Line = line.Line
+			frames[i].Column = 1
+		}
+	}
+	return frames
+}
+
+func (s *session) describe(address uint16) string {
+	if label, ok := s.Debug.Label(address); ok {
+		return label
+	}
+	return fmt.Sprintf("$%04X", address)
+}
+
+var flagNames = []struct {
+	name string
+	bit  uint8
+}{
+	{"N", 0x80}, {"V", 0x40}, {"D", 0x08}, {"I", 0x04}, {"Z", 0x02}, {"C", 0x01},
+}
+
+func (s *session) registers() []variable {
+	cpu := s.CPU
+	return []variable{
+		{Name: "A", Value: fmt.Sprintf("$%02X", cpu.A), Type: "uint8"},
+		{Name: "X", Value: fmt.Sprintf("$%02X", cpu.X), Type: "uint8"},
+		{Name: "Y", Value: fmt.Sprintf("$%02X", cpu.Y), Type: "uint8"},
+		{Name: "S", Value: fmt.Sprintf("$%02X", cpu.S), Type: "uint8", MemoryReference: fmt.Sprintf("0x%04X", 0x0100+uint16(cpu.S))},
+		{Name: "P", Value: fmt.Sprintf("$%02X", cpu.Flags.Value()), Type: "uint8"},
+		{Name: "PC", Value: fmt.Sprintf("$%04X", cpu.PC), Type: "uint16", MemoryReference: fmt.Sprintf("0x%04X", cpu.PC)},
+	}
+}
+
+func (s *session) variables(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	var variables []variable
+	switch args.VariablesReference {
+	case registersReference:
+		variables = s.registers()
+	case flagsReference:
+		for _, flag := range flagNames {
+			value := "0"
+			if s.CPU.Flags.Value()&flag.bit != 0 {
+				value = "1"
+			}
+			variables = append(variables, variable{Name: flag.name, Value: value, Type: "bool"})
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *session) setVariable(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	value, err := s.parseValue(args.Value)
+	if err != nil {
+		return nil, err
+	}
+	cpu := s.CPU
+	if args.VariablesReference == flagsReference {
+		for _, flag := range flagNames {
+			if flag.name == args.Name {
+				flags := cpu.Flags.Value() &^ flag.bit
+				if value != 0 {
+					flags |= flag.bit
+				}
+				cpu.Flags.SetValue(flags)
+				return map[string]string{"value": strconv.Itoa(int(value))}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown flag %q", args.Name)
+	}
+	if args.Name != "PC" && value > 0xFF {
+		return nil, fmt.Errorf("value %q doesn't fit in register %s", args.Value, args.Name)
+	}
+	switch args.Name {
+	case "A":
+		cpu.A = uint8(value)
+	case "X":
+		cpu.X = uint8(value)
+	case "Y":
+		cpu.Y = uint8(value)
+	case "S":
+		cpu.S = uint8(value)
+	case "P":
+		cpu.Flags.SetValue(uint8(value))
+	case "PC":
+		cpu.PC = value
+		return map[string]string{"value": fmt.Sprintf("$%04X", value)}, nil
+	default:
+		return nil, fmt.Errorf("unknown register %q", args.Name)
+	}
+	return map[string]string{"value": fmt.Sprintf("$%02X", value)}, nil
+}
+
+// evaluate shows value of register or symbol, with memory reference to its address
+func (s *session) evaluate(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	expression := strings.TrimSpace(args.Expression)
+	for _, register := range s.registers() {
+		if strings.EqualFold(register.Name, expression) {
+			return map[string]interface{}{"result": register.Value, "variablesReference": 0,
+				"memoryReference": register.MemoryReference}, nil
+		}
+	}
+	value, err := s.parseValue(expression)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result":             fmt.Sprintf("$%04X", value),
+		"variablesReference": 0,
+		"memoryReference":    fmt.Sprintf("0x%04X", value),
+	}, nil
+}
+
+func (s *session) readMemory(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	reference, err := s.parseValue(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	start := int(reference) + args.Offset
+	if start < 0 || start > 0xFFFF {
+		return map[string]interface{}{"address": args.MemoryReference, "unreadableBytes": args.Count}, nil
+	}
+	count := args.Count
+	if start+count > 0x10000 {
+		count = 0x10000 - start
+	}
+	bytes := make([]uint8, count)
+	for i := range bytes {
+		bytes[i] = s.CPU.Memory.Get(uint16(start + i))
+	}
+	return map[string]interface{}{
+		"address":         fmt.Sprintf("0x%04X", start),
+		"data":            base64.StdEncoding.EncodeToString(bytes),
+		"unreadableBytes": args.Count - count,
+	}, nil
+}
+
+func (s *session) writeMemory(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Data            string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	reference, err := s.parseValue(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return nil, err
+	}
+	start := int(reference) + args.Offset
+	if start < 0 || start+len(bytes) > 0x10000 {
+		return nil, fmt.Errorf("memory write outside of address space")
+	}
+	s.CPU.Memory.Set(uint16(start), bytes...)
+	return map[string]int{"bytesWritten": len(bytes)}, nil
+}
+
+// parseValue parses symbol name, or number: $hex, 0xhex or decimal
+func (s *session) parseValue(text string) (uint16, error) {
+	if address, ok := s.Debug.Symbol(text); ok {
+		return address, nil
+	}
+	var value uint64
+	var err error
+	switch {
+	case strings.HasPrefix(text, "$"):
+		value, err = strconv.ParseUint(text[1:], 16, 16)
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		value, err = strconv.ParseUint(text[2:], 16, 16)
+	default:
+		value, err = strconv.ParseUint(text, 10, 16)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	return uint16(value), nil
+}
+
+// historySize is number of instructions debugger can go back
+const historySize = 100000
+
+func NewServer(cpu *go6502.CPU, debug *go6502.DebugInfo) *Server {
+	if cpu.History == nil {
+		go6502.NewHistory(cpu, historySize)
+	}
+	if debug == nil {
+		debug = go6502.NewDebugInfo()
+	}
+	return &Server{
+		CPU:         cpu,
+		Debug:       debug,
+		sources:     map[string][]uint16{},
+		breakpoints: map[uint16]bool{},
+	}
+}
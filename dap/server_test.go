@@ -0,0 +1,329 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go6502/asm"
+	"go6502/go6502"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type message struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is minimal DAP client, which keeps events received while waiting for responses
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+	events []message
+}
+
+func (c *client) read() message {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, err := readMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("Reading message failed: %v", err)
+	}
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		c.t.Fatalf("Invalid message %s: %v", data, err)
+	}
+	return m
+}
+
+// request sends request and decodes body of successful response into result
+func (c *client) request(command string, arguments interface{}, result interface{}) {
+	c.t.Helper()
+	if m := c.send(command, arguments); !m.Success {
+		c.t.Fatalf("Request %s failed: %s", command, m.Message)
+	} else if result != nil {
+		json.Unmarshal(m.Body, result)
+	}
+}
+
+func (c *client) send(command string, arguments interface{}) message {
+	c.t.Helper()
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("Sending %s failed: %v", command, err)
+	}
+	for {
+		m := c.read()
+		if m.Type == "response" && m.RequestSeq == c.seq {
+			return m
+		}
+		c.events = append(c.events, m)
+	}
+}
+
+// expectEvent waits for event and checks its reason, if it has one
+func (c *client) expectEvent(name string, reason string) {
+	c.t.Helper()
+	for len(c.events) == 0 {
+		c.events = append(c.events, c.read())
+	}
+	m := c.events[0]
+	c.events = c.events[1:]
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(m.Body, &body)
+	if m.Event != name || body.Reason != reason {
+		c.t.Fatalf("Expected %s event with reason %q, got %s %s", name, reason, m.Event, m.Body)
+	}
+}
+
+const testProgram = `	.org $0200
+main:	ldx #$01
+	jsr sub
+loop:	jmp loop
+sub:	lda #$42
+	sta $10
+	rts
+`
+
+func startServer(t *testing.T, source string) (*go6502.CPU, *client) {
+	t.Helper()
+	cpu := go6502.NewDefaultMemoryCPU()
+	program, err := asm.New().Assemble("test.s", strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("Test program should assemble: %v", err)
+	}
+	program.Load(cpu.Memory)
+	cpu.PC = 0x0200
+
+	server, conn := net.Pipe()
+	go NewServer(cpu, program.DebugInfo()).ServeConn(server)
+	t.Cleanup(func() { conn.Close() })
+	c := &client{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	c.request("initialize", map[string]string{"adapterID": "go6502"}, nil)
+	c.expectEvent("initialized", "")
+	return cpu, c
+}
+
+func TestBreakpointsAndStack(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	c.request("launch", map[string]bool{"stopOnEntry": true}, nil)
+	var breakpoints struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "/src/test.s"},
+		"breakpoints": []map[string]int{{"line": 6}, {"line": 100}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 2 || !breakpoints.Breakpoints[0].Verified || breakpoints.Breakpoints[1].Verified {
+		t.Fatalf("Only breakpoint on line with code should be verified, got %+v", breakpoints.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+	c.expectEvent("stopped", "entry")
+
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "breakpoint")
+	if cpu.PC != 0x020A {
+		t.Fatalf("Program should stop at breakpoint. Expected %x, got %x", 0x020A, cpu.PC)
+	}
+
+	var trace struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	frames := trace.StackFrames
+	if len(frames) != 2 {
+		t.Fatalf("Stack should have subroutine and caller frames, got %+v", frames)
+	}
+	if frames[0].Name != "sub" || frames[0].Line != 6 || frames[0].Source == nil || frames[0].Source.Name != "test.s" {
+		t.Fatalf("Wrong subroutine frame: %+v", frames[0])
+	}
+	if frames[1].Line != 3 || frames[1].InstructionPointerReference != "0x0202" {
+		t.Fatalf("Caller frame should point at JSR: %+v", frames[1])
+	}
+
+	c.request("stepIn", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "step")
+	if cpu.PC != 0x020C || cpu.Memory.Get(0x10) != 0x42 {
+		t.Fatalf("Step should execute one instruction, PC is %x", cpu.PC)
+	}
+
+	c.request("stepBack", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "step")
+	if cpu.PC != 0x020A || cpu.Memory.Get(0x10) != 0 {
+		t.Fatalf("Step back should undo store, PC is %x", cpu.PC)
+	}
+	c.request("reverseContinue", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "entry")
+	if cpu.PC != 0x0200 || cpu.S != 0xFF {
+		t.Fatalf("Reverse continue should go back to start, PC is %x", cpu.PC)
+	}
+}
+
+const nestedProgram = `	.org $0200
+main:	jsr outer
+loop:	jmp loop
+outer:	lda #$00
+	pha
+	jsr inner
+	pla
+	rts
+inner:	lda #$42
+	rts
+`
+
+// stopInInner runs nested program to breakpoint in inner subroutine
+func stopInInner(t *testing.T, stack uint8) (*go6502.CPU, *client) {
+	t.Helper()
+	cpu, c := startServer(t, nestedProgram)
+	cpu.S = stack
+	c.request("launch", nil, nil)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "/src/test.s"},
+		"breakpoints": []map[string]int{{"line": 9}},
+	}, nil)
+	c.request("configurationDone", nil, nil)
+	c.expectEvent("stopped", "breakpoint")
+	return cpu, c
+}
+
+func TestNestedStack(t *testing.T) {
+	_, c := stopInInner(t, 0xFF)
+	var trace struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	// JSR pushes address of its last byte, so pushed value minus 2 is address of JSR.
+	// Byte pushed by PHA in outer isn't return address.
+	expected := []struct {
+		name    string
+		line    int
+		address string
+	}{{"inner", 9, "0x020E"}, {"outer", 6, "0x0209"}, {"main", 2, "0x0200"}}
+	frames := trace.StackFrames
+	if len(frames) != len(expected) {
+		t.Fatalf("Stack should have %d frames, got %+v", len(expected), frames)
+	}
+	for i, frame := range frames {
+		if frame.Name != expected[i].name || frame.Line != expected[i].line || frame.InstructionPointerReference != expected[i].address {
+			t.Fatalf("Wrong frame %d. Expected %+v, got %+v", i, expected[i], frame)
+		}
+	}
+}
+
+func TestStepOutWrapsStack(t *testing.T) {
+	// Return address of main is pushed at $0101 and $0100, the rest wraps to $01FF
+	cpu, c := stopInInner(t, 0x01)
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "step")
+	if cpu.PC != 0x020C {
+		t.Fatalf("Step out should return to outer. Expected %x, got %x", 0x020C, cpu.PC)
+	}
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "step")
+	if cpu.PC != 0x0203 || cpu.S != 0x01 {
+		t.Fatalf("Step out should return to main across end of stack page. Expected %x, got %x (S %x)", 0x0203, cpu.PC, cpu.S)
+	}
+}
+
+func TestStepOutSkipsPulls(t *testing.T) {
+	cpu, c := startServer(t, `	.org $0200
+main:	jsr save
+loop:	jmp loop
+save:	php
+	pha
+	lda #$42
+	pla
+	plp
+	rts
+`)
+	c.request("launch", nil, nil)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "/src/test.s"},
+		"breakpoints": []map[string]int{{"line": 6}},
+	}, nil)
+	c.request("configurationDone", nil, nil)
+	c.expectEvent("stopped", "breakpoint")
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "step")
+	if cpu.PC != 0x0203 || cpu.S != 0xFF {
+		t.Fatalf("Step out shouldn't stop on PLA and PLP. Expected %x, got %x (S %x)", 0x0203, cpu.PC, cpu.S)
+	}
+}
+
+func TestVariablesAndMemory(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	cpu.A = 0x42
+	cpu.Flags.SetCarry(true)
+
+	var variables struct {
+		Variables []variable `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": registersReference}, &variables)
+	if a := variables.Variables[0]; a.Name != "A" || a.Value != "$42" {
+		t.Fatalf("Wrong accumulator variable: %+v", a)
+	}
+	if pc := variables.Variables[5]; pc.Name != "PC" || pc.Value != "$0200" || pc.MemoryReference != "0x0200" {
+		t.Fatalf("Wrong PC variable: %+v", pc)
+	}
+	c.request("variables", map[string]int{"variablesReference": flagsReference}, &variables)
+	if carry := variables.Variables[5]; carry.Name != "C" || carry.Value != "1" {
+		t.Fatalf("Wrong carry variable: %+v", carry)
+	}
+
+	c.request("setVariable", map[string]interface{}{"variablesReference": registersReference, "name": "X", "value": "$10"}, nil)
+	c.request("setVariable", map[string]interface{}{"variablesReference": flagsReference, "name": "C", "value": "0"}, nil)
+	if cpu.X != 0x10 || cpu.Flags.HasCarry() {
+		t.Fatalf("Variables should be written, got %v", cpu)
+	}
+	if m := c.send("setVariable", map[string]interface{}{"variablesReference": registersReference, "name": "A", "value": "$100"}); m.Success {
+		t.Fatalf("Too big register value should fail")
+	}
+
+	var memory struct {
+		Address string `json:"address"`
+		Data    string `json:"data"`
+	}
+	c.request("readMemory", map[string]interface{}{"memoryReference": "sub", "offset": 1, "count": 2}, &memory)
+	if memory.Address != "0x0209" || memory.Data != "QoU=" { // 42 85
+		t.Fatalf("Wrong memory read at %s: %s", memory.Address, memory.Data)
+	}
+	c.request("writeMemory", map[string]interface{}{"memoryReference": "0x1000", "data": "vu8="}, nil)
+	if cpu.Memory.Get(0x1000) != 0xBE || cpu.Memory.Get(0x1001) != 0xEF {
+		t.Fatalf("Memory should be written, got %x %x", cpu.Memory.Get(0x1000), cpu.Memory.Get(0x1001))
+	}
+}
+
+func TestPause(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	c.request("launch", nil, nil)
+	c.request("configurationDone", nil, nil) // Program loops forever
+	c.request("pause", map[string]int{"threadId": threadID}, nil)
+	c.expectEvent("stopped", "pause")
+	if cpu.PC != 0x0205 {
+		t.Fatalf("Program should be paused in loop, PC is %x", cpu.PC)
+	}
+	c.request("disconnect", nil, nil)
+}
+
+func TestHalted(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	cpu.State = go6502.Stopped
+	c.request("launch", nil, nil)
+	c.request("configurationDone", nil, nil)
+	c.expectEvent("stopped", "exception")
+	if cpu.PC != 0x0200 {
+		t.Fatalf("Halted CPU shouldn't run, PC is %x", cpu.PC)
+	}
+}
@@ -0,0 +1,71 @@
+package display
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"go6502/go6502"
+	"image"
+)
+
+// Window shows contents of emulated screen using ebiten. It's kept out of go6502 package,
+// so emulator core can be used without GUI libraries.
+type Window struct {
+	screen *go6502.Screen
+	// frame keeps rendered screen, only changed blocks are redrawn and it's uploaded to image once per frame
+	frame *image.RGBA
+	image *ebiten.Image
+	// When CPU is set, it's executed by Update, instructionsPerFrame at each tick
+	cpu                  *go6502.CPU
+	instructionsPerFrame int
+	rewind               *go6502.Rewind
+	back                 int // how many snapshots back emulation is, while rewind key is held
+}
+
+// RewindKey rewinds emulation while it's held, releasing it resumes from shown point
+const RewindKey = ebiten.KeyBackspace
+
+func (w *Window) Update() error {
+	if w.cpu == nil {
+		return nil
+	}
+	if ebiten.IsKeyPressed(RewindKey) {
+		if w.back+1 < w.rewind.Len() {
+			w.back++
+			return w.rewind.Restore(w.back)
+		}
+		return nil
+	}
+	w.back = 0
+	for i := 0; i < w.instructionsPerFrame; i++ {
+		w.cpu.Advance()
+	}
+	return w.rewind.Capture()
+}
+
+func (w *Window) Draw(screen *ebiten.Image) {
+	if w.screen.RenderDirty(w.frame) {
+		w.image.ReplacePixels(w.frame.Pix)
+	}
+	screen.DrawImage(w.image, nil)
+}
+
+func (w *Window) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	return go6502.ScreenWidth, go6502.ScreenHeight
+}
+
+func NewWindow(screen *go6502.Screen) *Window {
+	return &Window{
+		screen: screen,
+		frame:  image.NewRGBA(image.Rect(0, 0, go6502.ScreenWidth, go6502.ScreenHeight)),
+		image:  ebiten.NewImage(go6502.ScreenWidth, go6502.ScreenHeight),
+	}
+}
+
+// NewEmulatorWindow creates window, which runs CPU and keeps snapshots of its state from
+// last rewindSeconds, so emulation can be rewound with RewindKey.
+func NewEmulatorWindow(cpu *go6502.CPU, screen *go6502.Screen, instructionsPerFrame int, rewindSeconds int) *Window {
+	w := NewWindow(screen)
+	w.cpu = cpu
+	w.instructionsPerFrame = instructionsPerFrame
+	w.rewind = go6502.NewRewind(cpu, rewindSeconds*ebiten.DefaultTPS)
+	return w
+}
@@ -0,0 +1,613 @@
+package gdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go6502/go6502"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*
+Server implements GDB remote serial protocol stub for emulated CPU. Registers are numbered:
+
+	0 - A, 1 - X, 2 - Y, 3 - S, 4 - P (flags), 5 - PC (2 bytes, little endian)
+
+and their layout is also described in target.xml, served with qXfer:features:read.
+Only one debugger can be connected at a time.
+*/
+type Server struct {
+	CPU         *go6502.CPU
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]watchKind
+	watchHit    *watchHit
+	executing   bool
+}
+
+type watchKind int
+
+const (
+	watchWrite  watchKind = 2
+	watchRead   watchKind = 3
+	watchAccess watchKind = 4
+)
+
+type watchHit struct {
+	address uint16
+	kind    watchKind
+}
+
+const (
+	signalInterrupt = 2
+	signalIllegal   = 4
+	signalTrap      = 5
+)
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.go6502.cpu">
+    <reg name="a" bitsize="8" type="uint8" regnum="0"/>
+    <reg name="x" bitsize="8" type="uint8" regnum="1"/>
+    <reg name="y" bitsize="8" type="uint8" regnum="2"/>
+    <reg name="s" bitsize="8" type="uint8" regnum="3"/>
+    <reg name="p" bitsize="8" type="uint8" regnum="4"/>
+    <reg name="pc" bitsize="16" type="code_ptr" regnum="5"/>
+  </feature>
+</target>
+`
+
+// event is either a packet from debugger or interrupt request (Ctrl-C byte)
+type event struct {
+	packet    string
+	interrupt bool
+	corrupted bool // packet with wrong checksum, debugger resends it after '-'
+	err       error
+}
+
+var errChecksum = errors.New("gdb: invalid checksum")
+
+type session struct {
+	*Server
+	conn   io.Writer
+	events chan event
+	ack    bool
+	err    error // connection error noticed while CPU was running
+	// queued packets were received while CPU was running, they are handled after stop reply
+	queued []string
+}
+
+func (s *Server) MemoryRead(address uint16, value uint8) {
+	if kind, ok := s.watchpoints[address]; ok && s.executing && kind != watchWrite {
+		s.watchHit = &watchHit{address: address, kind: kind}
+	}
+}
+
+func (s *Server) MemoryWritten(address uint16, previous uint8, value uint8) {
+	if kind, ok := s.watchpoints[address]; ok && s.executing && kind != watchRead {
+		s.watchHit = &watchHit{address: address, kind: kind}
+	}
+}
+
+// ListenAndServe listens on "tcp" address like localhost:2345 or "unix" socket path
+func (s *Server) ListenAndServe(network, address string) error {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	return s.Serve(listener)
+}
+
+// Serve accepts debugger connections one after another
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		err = s.ServeConn(conn)
+		conn.Close()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+}
+
+// ServeConn handles single debugging session until debugger detaches or disconnects
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	session := &session{
+		Server: s,
+		conn:   conn,
+		events: make(chan event),
+		ack:    true,
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go readEvents(conn, session.events, done)
+
+	for e := range session.events {
+		if e.err != nil {
+			return e.err
+		}
+		if e.interrupt {
+			session.send(fmt.Sprintf("S%02x", signalInterrupt))
+			continue
+		}
+		if err := session.acknowledge(e); err != nil {
+			return err
+		}
+		if e.corrupted {
+			continue
+		}
+		session.queued = append(session.queued, e.packet)
+		for len(session.queued) > 0 {
+			packet := session.queued[0]
+			session.queued = session.queued[1:]
+			reply, detach := session.handle(packet)
+			if session.err != nil {
+				return session.err
+			}
+			if err := session.send(reply); err != nil {
+				return err
+			}
+			if detach {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// acknowledge confirms packet, or asks debugger to resend corrupted one
+func (s *session) acknowledge(e event) error {
+	if !s.ack {
+		return nil
+	}
+	reply := "+"
+	if e.corrupted {
+		reply = "-"
+	}
+	_, err := s.conn.Write([]byte(reply))
+	return err
+}
+
+func readEvents(conn io.Reader, events chan<- event, done <-chan struct{}) {
+	reader := bufio.NewReader(conn)
+	emit := func(e event) bool {
+		select {
+		case events <- e:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			emit(event{err: err})
+			return
+		}
+		switch c {
+		case 0x03:
+			if !emit(event{interrupt: true}) {
+				return
+			}
+		case '$':
+			packet, err := readPacket(reader)
+			if errors.Is(err, errChecksum) {
+				if !emit(event{corrupted: true}) {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				emit(event{err: err})
+				return
+			}
+			if !emit(event{packet: packet}) {
+				return
+			}
+		}
+		// Acknowledgements ('+' and '-') are ignored, nothing is resent
+	}
+}
+
+// readPacket reads packet data after '$', checks its checksum and removes escaping
+func readPacket(reader *bufio.Reader) (string, error) {
+	data, err := reader.ReadBytes('#')
+	if err != nil {
+		return "", err
+	}
+	data = data[:len(data)-1]
+	checksum := make([]byte, 2)
+	if _, err := io.ReadFull(reader, checksum); err != nil {
+		return "", err
+	}
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	if fmt.Sprintf("%02x", sum) != strings.ToLower(string(checksum)) {
+		return "", fmt.Errorf("%w of packet %q", errChecksum, data)
+	}
+	unescaped := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			unescaped = append(unescaped, data[i]^0x20)
+		} else {
+			unescaped = append(unescaped, data[i])
+		}
+	}
+	return string(unescaped), nil
+}
+
+func (s *session) send(data string) error {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	_, err := fmt.Fprintf(s.conn, "$%s#%02x", data, sum)
+	return err
+}
+
+// handle returns reply to packet and whether debugger detached
+func (s *session) handle(packet string) (string, bool) {
+	if packet == "" {
+		return "", false
+	}
+	args := packet[1:]
+	switch packet[0] {
+	case '?':
+		return fmt.Sprintf("S%02x", signalTrap), false
+	case 'g':
+		return s.readRegisters(), false
+	case 'G':
+		return s.writeRegisters(args), false
+	case 'p':
+		return s.readRegister(args), false
+	case 'P':
+		return s.writeRegister(args), false
+	case 'm':
+		return s.readMemory(args), false
+	case 'M':
+		return s.writeMemory(args, true), false
+	case 'X':
+		return s.writeMemory(args, false), false
+	case 's':
+		if reply, ok := s.jump(args); !ok {
+			return reply, false
+		}
+		return s.step(), false
+	case 'c':
+		if reply, ok := s.jump(args); !ok {
+			return reply, false
+		}
+		return s.resume(), false
+	case 'b':
+		return s.reverse(args), false
+	case 'Z', 'z':
+		return s.point(packet[0] == 'Z', args), false
+	case 'H':
+		return "OK", false
+	case 'D':
+		return "OK", true
+	case 'k':
+		return "", true
+	case 'q', 'Q':
+		return s.query(packet), false
+	}
+	return "", false // Unsupported packet
+}
+
+func (s *session) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;swbreak+;hwbreak+;QStartNoAckMode+;ReverseStep+;ReverseContinue+"
+	case packet == "QStartNoAckMode":
+		s.ack = false
+		return "OK"
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		var offset, length int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(packet, "qXfer:features:read:target.xml:"), "%x,%x", &offset, &length); err != nil {
+			return "E01"
+		}
+		if offset >= len(targetXML) {
+			return "l"
+		}
+		if offset+length >= len(targetXML) {
+			return "l" + targetXML[offset:]
+		}
+		return "m" + targetXML[offset:offset+length]
+	}
+	return ""
+}
+
+func (s *session) registerBytes() []uint8 {
+	cpu := s.CPU
+	return []uint8{cpu.A, cpu.X, cpu.Y, cpu.S, cpu.Flags.Value(), uint8(cpu.PC), uint8(cpu.PC >> 8)}
+}
+
+func (s *session) readRegisters() string {
+	return hex.EncodeToString(s.registerBytes())
+}
+
+func (s *session) writeRegisters(args string) string {
+	data, err := hex.DecodeString(args)
+	if err != nil || len(data) != 7 {
+		return "E01"
+	}
+	for register := 0; register < 6; register++ {
+		s.setRegister(register, data[register:])
+	}
+	return "OK"
+}
+
+// registerOffset returns offset and size of register in 'g' packet
+func registerOffset(register int) (int, int, bool) {
+	switch {
+	case register >= 0 && register < 5:
+		return register, 1, true
+	case register == 5:
+		return 5, 2, true
+	}
+	return 0, 0, false
+}
+
+func (s *session) readRegister(args string) string {
+	register, err := strconv.ParseInt(args, 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	offset, size, ok := registerOffset(int(register))
+	if !ok {
+		return "E01"
+	}
+	return hex.EncodeToString(s.registerBytes()[offset : offset+size])
+}
+
+func (s *session) writeRegister(args string) string {
+	number, value, found := strings.Cut(args, "=")
+	register, err := strconv.ParseInt(number, 16, 32)
+	if !found || err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(value)
+	_, size, ok := registerOffset(int(register))
+	if err != nil || !ok || len(data) != size {
+		return "E01"
+	}
+	s.setRegister(int(register), data)
+	return "OK"
+}
+
+func (s *session) setRegister(register int, data []uint8) {
+	cpu := s.CPU
+	switch register {
+	case 0:
+		cpu.A = data[0]
+	case 1:
+		cpu.X = data[0]
+	case 2:
+		cpu.Y = data[0]
+	case 3:
+		cpu.S = data[0]
+	case 4:
+		cpu.Flags.SetValue(data[0])
+	case 5:
+		cpu.PC = uint16(data[0]) + uint16(data[1])<<8
+	}
+}
+
+// parseAddressLength parses "addr,length" used by memory and breakpoint packets
+func parseAddressLength(args string) (uint16, int, error) {
+	addressText, lengthText, found := strings.Cut(args, ",")
+	if !found {
+		return 0, 0, fmt.Errorf("missing length")
+	}
+	address, err := strconv.ParseUint(addressText, 16, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(lengthText, 16, 32)
+	if err != nil || length > 0x10000 {
+		return 0, 0, fmt.Errorf("invalid length %q", lengthText)
+	}
+	return uint16(address), int(length), nil
+}
+
+func (s *session) readMemory(args string) string {
+	address, length, err := parseAddressLength(args)
+	if err != nil {
+		return "E01"
+	}
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = s.CPU.Memory.Get(address + uint16(i))
+	}
+	return hex.EncodeToString(data)
+}
+
+// writeMemory handles M (hex encoded data) and X (binary data) packets
+func (s *session) writeMemory(args string, hexData bool) string {
+	location, value, found := strings.Cut(args, ":")
+	address, length, err := parseAddressLength(location)
+	if !found || err != nil {
+		return "E01"
+	}
+	data := []uint8(value)
+	if hexData {
+		if data, err = hex.DecodeString(value); err != nil {
+			return "E01"
+		}
+	}
+	if len(data) != length {
+		return "E01"
+	}
+	s.CPU.Memory.Set(address, data...)
+	return "OK"
+}
+
+// jump handles optional address argument of step and continue
+func (s *session) jump(args string) (string, bool) {
+	if args == "" {
+		return "", true
+	}
+	address, err := strconv.ParseUint(args, 16, 16)
+	if err != nil {
+		return "E01", false
+	}
+	s.CPU.PC = uint16(address)
+	return "", true
+}
+
+func (s *session) point(insert bool, args string) string {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	kind, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "E01"
+	}
+	address, length, err := parseAddressLength(parts[1])
+	if err != nil {
+		return "E01"
+	}
+	switch kind {
+	case 0, 1: // Software and hardware breakpoints are the same in emulator
+		if insert {
+			s.breakpoints[address] = true
+		} else {
+			delete(s.breakpoints, address)
+		}
+	case 2, 3, 4:
+		if length == 0 {
+			length = 1
+		}
+		for i := 0; i < length; i++ {
+			if insert {
+				s.watchpoints[address+uint16(i)] = watchKind(kind)
+			} else {
+				delete(s.watchpoints, address+uint16(i))
+			}
+		}
+	default:
+		return ""
+	}
+	return "OK"
+}
+
+// execute runs single instruction, watching memory accesses
+func (s *session) execute() {
+	s.watchHit = nil
+	s.executing = true
+	s.CPU.Advance()
+	s.executing = false
+}
+
+func (s *session) step() string {
+	s.execute()
+	if s.watchHit != nil {
+		return s.watchReply()
+	}
+	return fmt.Sprintf("S%02x", signalTrap)
+}
+
+// resume continues execution until breakpoint, watchpoint or interrupt from debugger
+func (s *session) resume() string {
+	for i := 0; ; i++ {
+		s.execute()
+		if s.watchHit != nil {
+			return s.watchReply()
+		}
+		if s.breakpoints[s.CPU.PC] {
+			return fmt.Sprintf("T%02xswbreak:;", signalTrap)
+		}
+		if s.CPU.State != go6502.Running {
+			return s.haltReply()
+		}
+		if i%1000 == 0 {
+			select {
+			case e := <-s.events:
+				if e.err != nil {
+					s.err = e.err
+					return ""
+				}
+				if e.interrupt {
+					return fmt.Sprintf("S%02x", signalInterrupt)
+				}
+				// Packet is confirmed now, so debugger doesn't resend it
+				if err := s.acknowledge(e); err != nil {
+					s.err = err
+					return ""
+				}
+				if !e.corrupted {
+					s.queued = append(s.queued, e.packet)
+				}
+			default:
+			}
+		}
+	}
+}
+
+// haltReply tells debugger that CPU stopped by itself, jammed and faulted CPU get SIGILL
+func (s *session) haltReply() string {
+	switch s.CPU.State {
+	case go6502.Jammed, go6502.Faulted:
+		return fmt.Sprintf("S%02x", signalIllegal)
+	}
+	return fmt.Sprintf("S%02x", signalTrap)
+}
+
+// reverse handles backward step (bs) and continue (bc) using CPU history
+func (s *session) reverse(args string) string {
+	history := s.CPU.History
+	switch args {
+	case "s":
+		if history.StepBack() {
+			return fmt.Sprintf("S%02x", signalTrap)
+		}
+	case "c":
+		if history.RunBack(func(cpu *go6502.CPU) bool { return s.breakpoints[cpu.PC] }) {
+			return fmt.Sprintf("T%02xswbreak:;", signalTrap)
+		}
+	default:
+		return ""
+	}
+	return fmt.Sprintf("T%02xreplaylog:begin;", signalTrap)
+}
+
+func (s *session) watchReply() string {
+	name := map[watchKind]string{watchWrite: "watch", watchRead: "rwatch", watchAccess: "awatch"}[s.watchHit.kind]
+	return fmt.Sprintf("T%02x%s:%04x;", signalTrap, name, s.watchHit.address)
+}
+
+// historySize is number of instructions debugger can go back
+const historySize = 100000
+
+func NewServer(cpu *go6502.CPU) *Server {
+	if cpu.History == nil {
+		go6502.NewHistory(cpu, historySize)
+	}
+	s := &Server{
+		CPU:         cpu,
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]watchKind{},
+	}
+	cpu.Memory.AddObserver(s)
+	return s
+}
@@ -0,0 +1,237 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"go6502/asm"
+	"go6502/go6502"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is minimal GDB remote protocol client, speaking to stub over TCP
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *client) request(packet string) string {
+	c.t.Helper()
+	c.send(packet)
+	return c.reply()
+}
+
+// send sends packet and waits only for acknowledgement
+func (c *client) send(packet string) {
+	c.t.Helper()
+	var sum uint8
+	for i := 0; i < len(packet); i++ {
+		sum += packet[i]
+	}
+	if _, err := fmt.Fprintf(c.conn, "$%s#%02x", packet, sum); err != nil {
+		c.t.Fatalf("Sending %q failed: %v", packet, err)
+	}
+	if ack, err := c.reader.ReadByte(); err != nil || ack != '+' {
+		c.t.Fatalf("Packet %q wasn't acknowledged, got %q, %v", packet, ack, err)
+	}
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if start, err := c.reader.ReadByte(); err != nil || start != '$' {
+		c.t.Fatalf("Expected reply packet, got %q, %v", start, err)
+	}
+	data, err := c.reader.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("Reading reply failed: %v", err)
+	}
+	checksum := make([]byte, 2)
+	io.ReadFull(c.reader, checksum)
+	c.conn.Write([]byte("+"))
+	return strings.TrimSuffix(data, "#")
+}
+
+func startServer(t *testing.T, source string) (*go6502.CPU, *client) {
+	t.Helper()
+	cpu := go6502.NewDefaultMemoryCPU()
+	program := asm.MustAssemble(source)
+	program.Load(cpu.Memory)
+	cpu.PC = program.Segments[0].Origin
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't listen: %v", err)
+	}
+	server := NewServer(cpu)
+	go server.Serve(listener)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Can't connect: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		listener.Close()
+	})
+	return cpu, &client{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+const testProgram = `
+	.org $0200
+main:	lda #$42
+	sta $10
+	lda $11
+	tax
+loop:	jmp loop
+`
+
+func assertReply(t *testing.T, c *client, packet string, expected string) {
+	t.Helper()
+	if reply := c.request(packet); reply != expected {
+		t.Fatalf("Wrong reply to %q. Expected %q, got %q", packet, expected, reply)
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	assertReply(t, c, "?", "S05")
+	cpu.A = 0x12
+	cpu.Flags.SetCarry(true)
+	assertReply(t, c, "g", "120000ff21"+"0002")
+	assertReply(t, c, "p5", "0002")
+	assertReply(t, c, "P1=7f", "OK")
+	assertReply(t, c, "G0102030405"+"3412", "OK")
+	if cpu.A != 1 || cpu.X != 2 || cpu.Y != 3 || cpu.S != 4 || cpu.Flags.Value() != 0x25 || cpu.PC != 0x1234 {
+		t.Fatalf("Registers should be written, got %v", cpu)
+	}
+	assertReply(t, c, "p9", "E01")
+}
+
+func TestMemory(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	assertReply(t, c, "m200,3", "a94285")
+	assertReply(t, c, "M1000,2:beef", "OK")
+	assertReply(t, c, "X1002,2:}]a", "OK") // Escaped '}' (0x7d) followed by 'a'
+	if cpu.Memory.Get(0x1000) != 0xBE || cpu.Memory.Get(0x1001) != 0xEF {
+		t.Fatalf("Memory should be written, got %x %x", cpu.Memory.Get(0x1000), cpu.Memory.Get(0x1001))
+	}
+	if cpu.Memory.Get(0x1002) != 0x7D || cpu.Memory.Get(0x1003) != 'a' {
+		t.Fatalf("Binary data should be unescaped, got %x %x", cpu.Memory.Get(0x1002), cpu.Memory.Get(0x1003))
+	}
+	assertReply(t, c, "M1000,2:be", "E01")
+}
+
+func TestCorruptedPacket(t *testing.T) {
+	_, c := startServer(t, testProgram)
+	c.conn.Write([]byte("$g#00"))
+	if nak, err := c.reader.ReadByte(); err != nil || nak != '-' {
+		t.Fatalf("Packet with wrong checksum should be rejected with '-', got %q, %v", nak, err)
+	}
+	assertReply(t, c, "m200,1", "a9")
+}
+
+func TestStepAndBreakpoints(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	assertReply(t, c, "s", "S05")
+	if cpu.PC != 0x0202 || cpu.A != 0x42 {
+		t.Fatalf("Step should execute one instruction, PC is %x", cpu.PC)
+	}
+	assertReply(t, c, "Z0,206,1", "OK")
+	assertReply(t, c, "c", "T05swbreak:;")
+	if cpu.PC != 0x0206 {
+		t.Fatalf("Continue should stop at breakpoint. Expected %x, got %x", 0x0206, cpu.PC)
+	}
+	assertReply(t, c, "z0,206,1", "OK")
+	assertReply(t, c, "Z0,207,1", "OK")
+	assertReply(t, c, "c200", "T05swbreak:;")
+	assertReply(t, c, "c", "T05swbreak:;") // Continuing from breakpoint executes at least one instruction
+	if cpu.PC != 0x0207 || cpu.X != 0 {
+		t.Fatalf("Continue from address should run whole program. PC is %x, X is %x", cpu.PC, cpu.X)
+	}
+}
+
+func TestReverseExecution(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	assertReply(t, c, "bs", "T05replaylog:begin;")
+	assertReply(t, c, "Z0,207,1", "OK")
+	assertReply(t, c, "c", "T05swbreak:;")
+	assertReply(t, c, "bs", "S05")
+	if cpu.PC != 0x0206 || cpu.X != 0 {
+		t.Fatalf("Backward step should undo TAX. PC is %x, X is %x", cpu.PC, cpu.X)
+	}
+	assertReply(t, c, "Z0,202,1", "OK")
+	assertReply(t, c, "bc", "T05swbreak:;")
+	if cpu.PC != 0x0202 || cpu.Memory.Get(0x10) != 0 {
+		t.Fatalf("Backward continue should stop at breakpoint before store. PC is %x", cpu.PC)
+	}
+	assertReply(t, c, "bc", "T05replaylog:begin;")
+	if cpu.PC != 0x0200 || cpu.A != 0 {
+		t.Fatalf("Backward continue should stop at start of history. PC is %x", cpu.PC)
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	assertReply(t, c, "Z2,10,1", "OK")
+	assertReply(t, c, "Z3,11,1", "OK")
+	assertReply(t, c, "c", "T05watch:0010;")
+	if cpu.PC != 0x0204 {
+		t.Fatalf("Write watchpoint should stop after store. Expected %x, got %x", 0x0204, cpu.PC)
+	}
+	assertReply(t, c, "m10,1", "42") // Debugger reads don't trigger watchpoints
+	assertReply(t, c, "c", "T05rwatch:0011;")
+	assertReply(t, c, "z3,11,1", "OK")
+}
+
+func TestInterrupt(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	c.send("c") // Program loops forever, so nothing is returned until interrupt
+	c.conn.Write([]byte{0x03})
+	if reply := c.reply(); reply != "S02" {
+		t.Fatalf("Interrupt should stop execution with SIGINT. Got %q", reply)
+	}
+	if cpu.PC != 0x0207 {
+		t.Fatalf("CPU should be stopped in loop, PC is %x", cpu.PC)
+	}
+}
+
+func TestPacketWhileRunning(t *testing.T) {
+	_, c := startServer(t, testProgram)
+	c.send("c")
+	c.send("m200,1")
+	c.conn.Write([]byte{0x03})
+	if reply := c.reply(); reply != "S02" {
+		t.Fatalf("Interrupt should stop execution with SIGINT. Got %q", reply)
+	}
+	if reply := c.reply(); reply != "a9" {
+		t.Fatalf("Packet received while running should be handled after stop. Expected %q, got %q", "a9", reply)
+	}
+}
+
+func TestHalted(t *testing.T) {
+	cpu, c := startServer(t, testProgram)
+	cpu.State = go6502.Stopped
+	assertReply(t, c, "c", "S05")
+	cpu.State = go6502.Faulted
+	assertReply(t, c, "c", "S04")
+}
+
+func TestQueries(t *testing.T) {
+	_, c := startServer(t, testProgram)
+	if reply := c.request("qSupported:multiprocess+"); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Fatalf("Target description should be supported, got %q", reply)
+	}
+	xml := c.request("qXfer:features:read:target.xml:0,1000")
+	if !strings.HasPrefix(xml, "l<?xml") || !strings.Contains(xml, `name="pc"`) {
+		t.Fatalf("Whole target description should be returned, got %q", xml)
+	}
+	if reply := c.request("qXfer:features:read:target.xml:0,a"); reply != "m<?xml vers" {
+		t.Fatalf("Part of target description should be returned, got %q", reply)
+	}
+	assertReply(t, c, "vMustReplyEmpty", "")
+	assertReply(t, c, "D", "OK")
+}
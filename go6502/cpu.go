@@ -7,6 +7,10 @@ import (
 const (
 	ResetVectorL = 0xFFFC
 	ResetVectorH = 0xFFFD
+	IRQVectorL   = 0xFFFE
+	IRQVectorH   = 0xFFFF
+	NMIVectorL   = 0xFFFA
+	NMIVectorH   = 0xFFFB
 )
 
 const (
@@ -32,25 +36,144 @@ const (
 	OpSED = 0xF8
 
 	// Accumulator
-	OpLDA_imm        = 0xA9
-	OpLDA_zeropage   = 0xA5
-	OpLDA_zeropage_x = 0xB5
-	OpLDA_absolute   = 0xAD
-	OpLDA_absolute_x = 0xBD
-	OpLDA_absolute_y = 0xB9
-	OpSTA_zeropage   = 0x85
-	OpSTA_absolute   = 0x8D
-	OpSTA_zeropage_x = 0x95
-
-	OpCMP_imm = 0xC9
+	OpLDA_imm              = 0xA9
+	OpLDA_zeropage         = 0xA5
+	OpLDA_zeropage_x       = 0xB5
+	OpLDA_absolute         = 0xAD
+	OpLDA_absolute_x       = 0xBD
+	OpLDA_absolute_y       = 0xB9
+	OpSTA_zeropage         = 0x85
+	OpSTA_absolute         = 0x8D
+	OpSTA_zeropage_x       = 0x95
+	OpLDA_indexed_indirect = 0xA1
+	OpLDA_indirect_indexed = 0xB1
+	OpSTA_absolute_x       = 0x9D
+	OpSTA_absolute_y       = 0x99
+	OpSTA_indexed_indirect = 0x81
+	OpSTA_indirect_indexed = 0x91
+
+	OpCMP_imm              = 0xC9
+	OpCMP_zeropage         = 0xC5
+	OpCMP_zeropage_x       = 0xD5
+	OpCMP_absolute         = 0xCD
+	OpCMP_absolute_x       = 0xDD
+	OpCMP_absolute_y       = 0xD9
+	OpCMP_indexed_indirect = 0xC1
+	OpCMP_indirect_indexed = 0xD1
+	OpCPX_imm              = 0xE0
+	OpCPX_zeropage         = 0xE4
+	OpCPX_absolute         = 0xEC
+	OpCPY_imm              = 0xC0
+	OpCPY_zeropage         = 0xC4
+	OpCPY_absolute         = 0xCC
 
 	// X register
-	OpLDX_imm      = 0xA2
-	OpLDX_zeropage = 0xA6
+	OpLDX_imm        = 0xA2
+	OpLDX_zeropage   = 0xA6
+	OpLDX_zeropage_y = 0xB6
+	OpLDX_absolute   = 0xAE
+	OpLDX_absolute_y = 0xBE
+	OpSTX_zeropage   = 0x86
+	OpSTX_zeropage_y = 0x96
+	OpSTX_absolute   = 0x8E
 
 	// Y register
-	OpLDY_imm      = 0xA0
-	OpLDY_zeropage = 0xA4
+	OpLDY_imm        = 0xA0
+	OpLDY_zeropage   = 0xA4
+	OpLDY_zeropage_x = 0xB4
+	OpLDY_absolute   = 0xAC
+	OpLDY_absolute_x = 0xBC
+	OpSTY_zeropage   = 0x84
+	OpSTY_zeropage_x = 0x94
+	OpSTY_absolute   = 0x8C
+
+	// Arithmetic
+	OpADC_imm              = 0x69
+	OpADC_zeropage         = 0x65
+	OpADC_zeropage_x       = 0x75
+	OpADC_absolute         = 0x6D
+	OpADC_absolute_x       = 0x7D
+	OpADC_absolute_y       = 0x79
+	OpADC_indexed_indirect = 0x61
+	OpADC_indirect_indexed = 0x71
+	OpSBC_imm              = 0xE9
+	OpSBC_zeropage         = 0xE5
+	OpSBC_zeropage_x       = 0xF5
+	OpSBC_absolute         = 0xED
+	OpSBC_absolute_x       = 0xFD
+	OpSBC_absolute_y       = 0xF9
+	OpSBC_indexed_indirect = 0xE1
+	OpSBC_indirect_indexed = 0xF1
+
+	// Logic
+	OpAND_imm              = 0x29
+	OpAND_zeropage         = 0x25
+	OpAND_zeropage_x       = 0x35
+	OpAND_absolute         = 0x2D
+	OpAND_absolute_x       = 0x3D
+	OpAND_absolute_y       = 0x39
+	OpAND_indexed_indirect = 0x21
+	OpAND_indirect_indexed = 0x31
+	OpORA_imm              = 0x09
+	OpORA_zeropage         = 0x05
+	OpORA_zeropage_x       = 0x15
+	OpORA_absolute         = 0x0D
+	OpORA_absolute_x       = 0x1D
+	OpORA_absolute_y       = 0x19
+	OpORA_indexed_indirect = 0x01
+	OpORA_indirect_indexed = 0x11
+	OpEOR_imm              = 0x49
+	OpEOR_zeropage         = 0x45
+	OpEOR_zeropage_x       = 0x55
+	OpEOR_absolute         = 0x4D
+	OpEOR_absolute_x       = 0x5D
+	OpEOR_absolute_y       = 0x59
+	OpEOR_indexed_indirect = 0x41
+	OpEOR_indirect_indexed = 0x51
+	OpBIT_zeropage         = 0x24
+	OpBIT_absolute         = 0x2C
+
+	// Shifts and rotations
+	OpASL_accumulator = 0x0A
+	OpASL_zeropage    = 0x06
+	OpASL_zeropage_x  = 0x16
+	OpASL_absolute    = 0x0E
+	OpASL_absolute_x  = 0x1E
+	OpLSR_accumulator = 0x4A
+	OpLSR_zeropage    = 0x46
+	OpLSR_zeropage_x  = 0x56
+	OpLSR_absolute    = 0x4E
+	OpLSR_absolute_x  = 0x5E
+	OpROL_accumulator = 0x2A
+	OpROL_zeropage    = 0x26
+	OpROL_zeropage_x  = 0x36
+	OpROL_absolute    = 0x2E
+	OpROL_absolute_x  = 0x3E
+	OpROR_accumulator = 0x6A
+	OpROR_zeropage    = 0x66
+	OpROR_zeropage_x  = 0x76
+	OpROR_absolute    = 0x6E
+	OpROR_absolute_x  = 0x7E
+
+	// Increments and decrements
+	OpINC_zeropage   = 0xE6
+	OpINC_zeropage_x = 0xF6
+	OpINC_absolute   = 0xEE
+	OpINC_absolute_x = 0xFE
+	OpDEC_zeropage   = 0xC6
+	OpDEC_zeropage_x = 0xD6
+	OpDEC_absolute   = 0xCE
+	OpDEC_absolute_x = 0xDE
+
+	// Branches
+	OpBPL = 0x10
+	OpBMI = 0x30
+	OpBVC = 0x50
+	OpBVS = 0x70
+	OpBCC = 0x90
+	OpBCS = 0xB0
+	OpBNE = 0xD0
+	OpBEQ = 0xF0
 
 	// Jumps
 	OpJMP_absolute = 0x4C
@@ -60,6 +183,10 @@ const (
 
 	OpRTS = 0x60
 
+	// Interrupts
+	OpBRK = 0x00
+	OpRTI = 0x40
+
 	// Stack Operations
 	OpPHA = 0x48
 	OpPHP = 0x08
@@ -67,6 +194,9 @@ const (
 	OpPLA = 0x68
 
 	OpPLP = 0x28
+
+	OpTSX = 0xBA
+	OpTXS = 0x9A
 )
 
 type CPU struct {
@@ -77,22 +207,165 @@ type CPU struct {
 	S      uint8
 	Flags  *Flags
 	Memory *Memory
+	Tracer Tracer
+	// History of executed instructions, if recording was started with NewHistory
+	History *History
+	// Variant of CPU, set by WithVariant option of NewCPU
+	Variant Variant
+	// State tells if CPU executes instructions or waits, e.g. after WAI
+	State RunState
+	// DecimalMode selects behavior of ADC and SBC with decimal flag set
+	DecimalMode DecimalMode
+	// Undocumented enables stable undocumented opcodes of NMOS 6502, set by WithUndocumented
+	Undocumented bool
+	// Strict makes CPU fault on opcodes missing in its instruction set, instead of skipping them
+	Strict bool
+	// Err describes why CPU Faulted
+	Err error
+	// Port is I/O port of MOS6510 variant, found among memory entries by NewCPU
+	Port *Port6510
+	// Cycles counts clock cycles run by Tick
+	Cycles uint64
+
+	bus bus
+	// resetting is set by Reset until reset sequence is run
+	resetting bool
+	// irqPending and nmiPending latch interrupts requested until the next instruction boundary
+	irqPending bool
+	nmiPending bool
+	// interrupting is vector of interrupt, whose entry sequence runs instead of the next instruction
+	interrupting uint16
+	// notReady is inverted level of RDY input, soLow of SO input
+	notReady bool
+	soLow    bool
+}
+
+// RunState tells whether CPU executes instructions, Advance does nothing unless it's Running
+type RunState int
+
+const (
+	Running RunState = iota
+	// Waiting for interrupt after WAI
+	Waiting
+	// Stopped by STP until reset
+	Stopped
+	// Jammed by JAM opcode of NMOS 6502 until reset
+	Jammed
+	// Faulted on undefined opcode in strict mode, until reset
+	Faulted
+)
+
+func (s RunState) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case Waiting:
+		return "waiting"
+	case Stopped:
+		return "stopped"
+	case Jammed:
+		return "jammed"
+	case Faulted:
+		return "faulted"
+	}
+	return fmt.Sprintf("RunState(%d)", int(s))
 }
 
+// Halted tells if only reset makes CPU run again, interrupts are ignored
+func (s RunState) Halted() bool {
+	return s == Stopped || s == Jammed || s == Faulted
+}
+
+// DecimalMode is variant of BCD arithmetic, see http://www.6502.org/tutorials/decimal_mode.html
+type DecimalMode int
+
+const (
+	// DecimalNMOS is behavior of original 6502, N, V and Z flags don't match BCD result
+	DecimalNMOS DecimalMode = iota
+	// DecimalCMOS is behavior of 65C02, where flags are valid for BCD result, at cost of one more cycle
+	DecimalCMOS
+	// DecimalDisabled is behavior of Ricoh 2A03, decimal flag can be set, but ADC and SBC are binary
+	DecimalDisabled
+)
+
 func (cpu *CPU) String() string {
 	return fmt.Sprintf("A: %02X\tX: %02X\tY: %02X\tS: %02X\nPC: %04X\tFlags: %s",
 		cpu.A, cpu.X, cpu.Y, cpu.S, cpu.PC, cpu.Flags)
 }
 
+// Initialize powers CPU on, stack pointer starts at 0 and reset sequence is run at once.
+// Other registers keep their values, as they are undefined on hardware.
 func (cpu *CPU) Initialize() {
-	resetVector := uint16(cpu.Memory.Get(ResetVectorH))<<8 + uint16(cpu.Memory.Get(ResetVectorL))
-	cpu.PC = resetVector
-	cpu.S = 0xFF
+	cpu.S = 0
+	cpu.Reset()
+	cpu.reset()
+}
+
+// Instructions returns instruction set executed by CPU
+func (cpu *CPU) Instructions() *InstructionSet {
+	if cpu.Undocumented && cpu.Variant.nmos() {
+		return &NMOS6502Undocumented
+	}
+	return cpu.Variant.Instructions()
 }
 
+// Advance executes whole instruction at once, or finishes the one started by Tick
 func (cpu *CPU) Advance() {
-	// Timings will be taken care of later
+	if cpu.notReady {
+		return
+	}
+	if cpu.bus.active {
+		cpu.finishInstruction()
+		return
+	}
+	if cpu.resetting {
+		cpu.reset()
+		return
+	}
+	if cpu.State != Running {
+		return
+	}
+	// History records interrupt requests before they are taken
+	if cpu.History != nil {
+		cpu.History.begin()
+		defer cpu.History.end()
+	}
+	cpu.interrupting = cpu.nextInterrupt()
+	if cpu.Tracer != nil && cpu.interrupting == 0 {
+		cpu.Tracer.Trace(cpu)
+	}
+	cpu.execute()
+}
+
+// execute runs the next instruction, or reset or interrupt sequence
+func (cpu *CPU) execute() {
+	if cpu.resetting {
+		cpu.reset()
+		return
+	}
+	if cpu.interrupting != 0 {
+		cpu.enterInterrupt()
+		return
+	}
 	instruction := cpu.getNextInstruction()
+	if cpu.Strict && !cpu.Instructions()[instruction].Defined() {
+		cpu.PC--
+		cpu.State = Faulted
+		cpu.Err = &UndefinedOpcodeError{Opcode: instruction, Address: cpu.PC}
+		return
+	}
+	if cpu.bus.active && instruction != OpBRK {
+		// Instructions without operand read the next byte anyway
+		if i := cpu.Instructions()[instruction]; i.Defined() && (i.Mode == Implied || i.Mode == Accumulator) {
+			cpu.dummyRead(cpu.PC)
+		}
+	}
+	if cpu.Variant == WDC65C02 && cpu.advance65C02(instruction) {
+		return
+	}
+	if cpu.Undocumented && cpu.Variant.nmos() && cpu.advanceUndocumented(instruction) {
+		return
+	}
 	switch instruction {
 	case OpNOOP:
 		//Noop
@@ -131,9 +404,35 @@ func (cpu *CPU) Advance() {
 	case OpSED:
 		cpu.sed()
 
-	//Compare Accumulator
+	//Compare
 	case OpCMP_imm:
 		cpu.cmp_imm()
+	case OpCMP_zeropage:
+		cpu.cmp(cpu.zeropageAddress())
+	case OpCMP_zeropage_x:
+		cpu.cmp(cpu.zeropageXAddress())
+	case OpCMP_absolute:
+		cpu.cmp(cpu.absoluteAddress())
+	case OpCMP_absolute_x:
+		cpu.cmp(cpu.absoluteXAddress())
+	case OpCMP_absolute_y:
+		cpu.cmp(cpu.absoluteYAddress())
+	case OpCMP_indexed_indirect:
+		cpu.cmp(cpu.indexedIndirectAddress())
+	case OpCMP_indirect_indexed:
+		cpu.cmp(cpu.indirectIndexedAddress())
+	case OpCPX_imm:
+		cpu.cpx(cpu.immediateAddress())
+	case OpCPX_zeropage:
+		cpu.cpx(cpu.zeropageAddress())
+	case OpCPX_absolute:
+		cpu.cpx(cpu.absoluteAddress())
+	case OpCPY_imm:
+		cpu.cpy(cpu.immediateAddress())
+	case OpCPY_zeropage:
+		cpu.cpy(cpu.zeropageAddress())
+	case OpCPY_absolute:
+		cpu.cpy(cpu.absoluteAddress())
 
 	//LDA
 	case OpLDA_imm:
@@ -148,7 +447,10 @@ func (cpu *CPU) Advance() {
 		cpu.lda_absolute_x()
 	case OpLDA_absolute_y:
 		cpu.lda_absolute_y()
-	//TODO: indirect addressing modes
+	case OpLDA_indexed_indirect:
+		cpu.lda(cpu.indexedIndirectAddress())
+	case OpLDA_indirect_indexed:
+		cpu.lda(cpu.indirectIndexedAddress())
 	//STA
 	case OpSTA_zeropage:
 		cpu.sta_zeropage()
@@ -156,20 +458,216 @@ func (cpu *CPU) Advance() {
 		cpu.sta_absolute()
 	case OpSTA_zeropage_x:
 		cpu.sta_zeropage_x()
-	//TODO: other addressing modes
+	case OpSTA_absolute_x:
+		cpu.sta(cpu.absoluteXWriteAddress())
+	case OpSTA_absolute_y:
+		cpu.sta(cpu.absoluteYWriteAddress())
+	case OpSTA_indexed_indirect:
+		cpu.sta(cpu.indexedIndirectAddress())
+	case OpSTA_indirect_indexed:
+		cpu.sta(cpu.indirectIndexedWriteAddress())
 
 	//LDX
 	case OpLDX_imm:
 		cpu.ldx_imm()
 	case OpLDX_zeropage:
 		cpu.ldx_zeropage()
-	//TODO: other addressing modes
+	case OpLDX_zeropage_y:
+		cpu.ldx(cpu.zeropageYAddress())
+	case OpLDX_absolute:
+		cpu.ldx(cpu.absoluteAddress())
+	case OpLDX_absolute_y:
+		cpu.ldx(cpu.absoluteYAddress())
+	case OpSTX_zeropage:
+		cpu.stx(cpu.zeropageAddress())
+	case OpSTX_zeropage_y:
+		cpu.stx(cpu.zeropageYAddress())
+	case OpSTX_absolute:
+		cpu.stx(cpu.absoluteAddress())
 
 	//LDY
 	case OpLDY_imm:
 		cpu.ldy_imm()
 	case OpLDY_zeropage:
 		cpu.ldy_zeropage()
+	case OpLDY_zeropage_x:
+		cpu.ldy(cpu.zeropageXAddress())
+	case OpLDY_absolute:
+		cpu.ldy(cpu.absoluteAddress())
+	case OpLDY_absolute_x:
+		cpu.ldy(cpu.absoluteXAddress())
+	case OpSTY_zeropage:
+		cpu.sty(cpu.zeropageAddress())
+	case OpSTY_zeropage_x:
+		cpu.sty(cpu.zeropageXAddress())
+	case OpSTY_absolute:
+		cpu.sty(cpu.absoluteAddress())
+
+	//Arithmetic
+	case OpADC_imm:
+		cpu.adc(cpu.immediateAddress())
+	case OpADC_zeropage:
+		cpu.adc(cpu.zeropageAddress())
+	case OpADC_zeropage_x:
+		cpu.adc(cpu.zeropageXAddress())
+	case OpADC_absolute:
+		cpu.adc(cpu.absoluteAddress())
+	case OpADC_absolute_x:
+		cpu.adc(cpu.absoluteXAddress())
+	case OpADC_absolute_y:
+		cpu.adc(cpu.absoluteYAddress())
+	case OpADC_indexed_indirect:
+		cpu.adc(cpu.indexedIndirectAddress())
+	case OpADC_indirect_indexed:
+		cpu.adc(cpu.indirectIndexedAddress())
+	case OpSBC_imm:
+		cpu.sbc(cpu.immediateAddress())
+	case OpSBC_zeropage:
+		cpu.sbc(cpu.zeropageAddress())
+	case OpSBC_zeropage_x:
+		cpu.sbc(cpu.zeropageXAddress())
+	case OpSBC_absolute:
+		cpu.sbc(cpu.absoluteAddress())
+	case OpSBC_absolute_x:
+		cpu.sbc(cpu.absoluteXAddress())
+	case OpSBC_absolute_y:
+		cpu.sbc(cpu.absoluteYAddress())
+	case OpSBC_indexed_indirect:
+		cpu.sbc(cpu.indexedIndirectAddress())
+	case OpSBC_indirect_indexed:
+		cpu.sbc(cpu.indirectIndexedAddress())
+
+	//Logic
+	case OpAND_imm:
+		cpu.and(cpu.immediateAddress())
+	case OpAND_zeropage:
+		cpu.and(cpu.zeropageAddress())
+	case OpAND_zeropage_x:
+		cpu.and(cpu.zeropageXAddress())
+	case OpAND_absolute:
+		cpu.and(cpu.absoluteAddress())
+	case OpAND_absolute_x:
+		cpu.and(cpu.absoluteXAddress())
+	case OpAND_absolute_y:
+		cpu.and(cpu.absoluteYAddress())
+	case OpAND_indexed_indirect:
+		cpu.and(cpu.indexedIndirectAddress())
+	case OpAND_indirect_indexed:
+		cpu.and(cpu.indirectIndexedAddress())
+	case OpORA_imm:
+		cpu.ora(cpu.immediateAddress())
+	case OpORA_zeropage:
+		cpu.ora(cpu.zeropageAddress())
+	case OpORA_zeropage_x:
+		cpu.ora(cpu.zeropageXAddress())
+	case OpORA_absolute:
+		cpu.ora(cpu.absoluteAddress())
+	case OpORA_absolute_x:
+		cpu.ora(cpu.absoluteXAddress())
+	case OpORA_absolute_y:
+		cpu.ora(cpu.absoluteYAddress())
+	case OpORA_indexed_indirect:
+		cpu.ora(cpu.indexedIndirectAddress())
+	case OpORA_indirect_indexed:
+		cpu.ora(cpu.indirectIndexedAddress())
+	case OpEOR_imm:
+		cpu.eor(cpu.immediateAddress())
+	case OpEOR_zeropage:
+		cpu.eor(cpu.zeropageAddress())
+	case OpEOR_zeropage_x:
+		cpu.eor(cpu.zeropageXAddress())
+	case OpEOR_absolute:
+		cpu.eor(cpu.absoluteAddress())
+	case OpEOR_absolute_x:
+		cpu.eor(cpu.absoluteXAddress())
+	case OpEOR_absolute_y:
+		cpu.eor(cpu.absoluteYAddress())
+	case OpEOR_indexed_indirect:
+		cpu.eor(cpu.indexedIndirectAddress())
+	case OpEOR_indirect_indexed:
+		cpu.eor(cpu.indirectIndexedAddress())
+	case OpBIT_zeropage:
+		cpu.bit(cpu.zeropageAddress())
+	case OpBIT_absolute:
+		cpu.bit(cpu.absoluteAddress())
+
+	//Shifts and rotations
+	case OpASL_accumulator:
+		cpu.A = cpu.asl(cpu.A)
+	case OpASL_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.asl)
+	case OpASL_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.asl)
+	case OpASL_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.asl)
+	case OpASL_absolute_x:
+		cpu.modify(cpu.shiftAbsoluteXAddress(), cpu.asl)
+	case OpLSR_accumulator:
+		cpu.A = cpu.lsr(cpu.A)
+	case OpLSR_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.lsr)
+	case OpLSR_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.lsr)
+	case OpLSR_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.lsr)
+	case OpLSR_absolute_x:
+		cpu.modify(cpu.shiftAbsoluteXAddress(), cpu.lsr)
+	case OpROL_accumulator:
+		cpu.A = cpu.rol(cpu.A)
+	case OpROL_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.rol)
+	case OpROL_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.rol)
+	case OpROL_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.rol)
+	case OpROL_absolute_x:
+		cpu.modify(cpu.shiftAbsoluteXAddress(), cpu.rol)
+	case OpROR_accumulator:
+		cpu.A = cpu.ror(cpu.A)
+	case OpROR_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.ror)
+	case OpROR_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.ror)
+	case OpROR_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.ror)
+	case OpROR_absolute_x:
+		cpu.modify(cpu.shiftAbsoluteXAddress(), cpu.ror)
+
+	//Increments and decrements
+	case OpINC_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.inc)
+	case OpINC_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.inc)
+	case OpINC_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.inc)
+	case OpINC_absolute_x:
+		cpu.modify(cpu.absoluteXWriteAddress(), cpu.inc)
+	case OpDEC_zeropage:
+		cpu.modify(cpu.zeropageAddress(), cpu.dec)
+	case OpDEC_zeropage_x:
+		cpu.modify(cpu.zeropageXAddress(), cpu.dec)
+	case OpDEC_absolute:
+		cpu.modify(cpu.absoluteAddress(), cpu.dec)
+	case OpDEC_absolute_x:
+		cpu.modify(cpu.absoluteXWriteAddress(), cpu.dec)
+
+	//Branches
+	case OpBPL:
+		cpu.branch(!cpu.Flags.HasNegative())
+	case OpBMI:
+		cpu.branch(cpu.Flags.HasNegative())
+	case OpBVC:
+		cpu.branch(!cpu.Flags.HasOverflow())
+	case OpBVS:
+		cpu.branch(cpu.Flags.HasOverflow())
+	case OpBCC:
+		cpu.branch(!cpu.Flags.HasCarry())
+	case OpBCS:
+		cpu.branch(cpu.Flags.HasCarry())
+	case OpBNE:
+		cpu.branch(!cpu.Flags.HasZero())
+	case OpBEQ:
+		cpu.branch(cpu.Flags.HasZero())
 
 	//Jumps
 	case OpJMP_absolute:
@@ -182,6 +680,12 @@ func (cpu *CPU) Advance() {
 	case OpRTS:
 		cpu.rts()
 
+	//Interrupts
+	case OpBRK:
+		cpu.brk()
+	case OpRTI:
+		cpu.rti()
+
 	//Stack Ops
 	case OpPHA:
 		cpu.pha()
@@ -191,13 +695,15 @@ func (cpu *CPU) Advance() {
 		cpu.pla()
 	case OpPLP:
 		cpu.plp()
+	case OpTSX:
+		cpu.tsx()
+	case OpTXS:
+		cpu.txs()
 	}
-
-	println(cpu.String())
 }
 
 func (cpu *CPU) getNextInstruction() uint8 {
-	opcode := cpu.Memory.Get(cpu.PC)
+	opcode := cpu.read(cpu.PC)
 	cpu.PC += 1
 	return opcode
 }
@@ -276,9 +782,24 @@ func (cpu *CPU) sed() {
 }
 
 func (cpu *CPU) cmp_imm() {
-	imm := cpu.getNextInstruction()
-	cpu.Flags.SetCarry(imm <= cpu.A)
-	cpu.updateNZ(cpu.A)
+	cpu.compare(cpu.A, cpu.getNextInstruction())
+}
+
+func (cpu *CPU) cmp(address uint16) {
+	cpu.compare(cpu.A, cpu.read(address))
+}
+
+func (cpu *CPU) cpx(address uint16) {
+	cpu.compare(cpu.X, cpu.read(address))
+}
+
+func (cpu *CPU) cpy(address uint16) {
+	cpu.compare(cpu.Y, cpu.read(address))
+}
+
+func (cpu *CPU) compare(register uint8, value uint8) {
+	cpu.Flags.SetCarry(value <= register)
+	cpu.updateNZ(register - value)
 }
 
 func (cpu *CPU) lda_imm() {
@@ -288,47 +809,40 @@ func (cpu *CPU) lda_imm() {
 
 func (cpu *CPU) lda_zeropage() {
 	location := cpu.getNextInstruction()
-	cpu.A = cpu.Memory.Get(uint16(location))
+	cpu.A = cpu.read(uint16(location))
 	cpu.updateNZ(cpu.A)
 }
 
 func (cpu *CPU) lda_zeropage_x() {
-	location := (uint16(cpu.getNextInstruction()) + uint16(cpu.X)) & 0xFF
-	cpu.A = cpu.Memory.Get(location)
-	cpu.updateNZ(cpu.A)
+	cpu.lda(cpu.zeropageXAddress())
 }
 
 func (cpu *CPU) lda_absolute() {
 	location := uint16(cpu.getNextInstruction()) + uint16(cpu.getNextInstruction())<<8
-	cpu.A = cpu.Memory.Get(location)
+	cpu.A = cpu.read(location)
 	cpu.updateNZ(cpu.A)
 }
 
 func (cpu *CPU) lda_absolute_x() {
-	location := uint16(cpu.getNextInstruction()) + uint16(cpu.getNextInstruction())<<8 + uint16(cpu.X)
-	cpu.A = cpu.Memory.Get(location)
-	cpu.updateNZ(cpu.A)
+	cpu.lda(cpu.absoluteXAddress())
 }
 
 func (cpu *CPU) lda_absolute_y() {
-	location := uint16(cpu.getNextInstruction()) + uint16(cpu.getNextInstruction())<<8 + uint16(cpu.Y)
-	cpu.A = cpu.Memory.Get(location)
-	cpu.updateNZ(cpu.A)
+	cpu.lda(cpu.absoluteYAddress())
 }
 
 func (cpu *CPU) sta_zeropage() {
 	location := cpu.getNextInstruction()
-	cpu.Memory.Set(uint16(location), cpu.A)
+	cpu.write(uint16(location), cpu.A)
 }
 
 func (cpu *CPU) sta_zeropage_x() {
-	location := (uint16(cpu.getNextInstruction()) + uint16(cpu.X)) & 0xFF
-	cpu.Memory.Set(location, cpu.A)
+	cpu.sta(cpu.zeropageXAddress())
 }
 
 func (cpu *CPU) sta_absolute() {
 	location := uint16(cpu.getNextInstruction()) + (uint16(cpu.getNextInstruction()) << 8)
-	cpu.Memory.Set(location, cpu.A)
+	cpu.write(location, cpu.A)
 }
 
 func (cpu *CPU) ldx_imm() {
@@ -338,7 +852,7 @@ func (cpu *CPU) ldx_imm() {
 
 func (cpu *CPU) ldx_zeropage() {
 	location := cpu.getNextInstruction()
-	cpu.X = cpu.Memory.Get(uint16(location))
+	cpu.X = cpu.read(uint16(location))
 	cpu.updateNZ(cpu.X)
 }
 
@@ -349,35 +863,363 @@ func (cpu *CPU) ldy_imm() {
 
 func (cpu *CPU) ldy_zeropage() {
 	location := cpu.getNextInstruction()
-	cpu.Y = cpu.Memory.Get(uint16(location))
+	cpu.Y = cpu.read(uint16(location))
+	cpu.updateNZ(cpu.Y)
+}
+
+func (cpu *CPU) lda(address uint16) {
+	cpu.A = cpu.read(address)
+	cpu.updateNZ(cpu.A)
+}
+
+func (cpu *CPU) ldx(address uint16) {
+	cpu.X = cpu.read(address)
+	cpu.updateNZ(cpu.X)
+}
+
+func (cpu *CPU) ldy(address uint16) {
+	cpu.Y = cpu.read(address)
 	cpu.updateNZ(cpu.Y)
 }
 
+func (cpu *CPU) sta(address uint16) {
+	cpu.write(address, cpu.A)
+}
+
+func (cpu *CPU) stx(address uint16) {
+	cpu.write(address, cpu.X)
+}
+
+func (cpu *CPU) sty(address uint16) {
+	cpu.write(address, cpu.Y)
+}
+
+func (cpu *CPU) adc(address uint16) {
+	cpu.add(cpu.read(address))
+	cpu.decimalCycle()
+}
+
+func (cpu *CPU) add(value uint8) {
+	if cpu.decimal() {
+		cpu.addDecimal(value)
+		return
+	}
+	cpu.addWithCarry(value)
+}
+
+// sbc is addition of inverted value, borrow is inverted carry
+func (cpu *CPU) sbc(address uint16) {
+	cpu.subtract(cpu.read(address))
+	cpu.decimalCycle()
+}
+
+func (cpu *CPU) subtract(value uint8) {
+	if cpu.decimal() {
+		cpu.subtractDecimal(value)
+		return
+	}
+	cpu.addWithCarry(^value)
+}
+
+// decimalCycle is one more cycle taken by 65C02 to fix flags of BCD result
+func (cpu *CPU) decimalCycle() {
+	if cpu.decimal() && cpu.DecimalMode == DecimalCMOS {
+		cpu.dummyRead(cpu.PC)
+	}
+}
+
+// decimal tells if ADC and SBC use BCD arithmetic
+func (cpu *CPU) decimal() bool {
+	return cpu.Flags.HasDecimal() && cpu.DecimalMode != DecimalDisabled
+}
+
+func (cpu *CPU) addWithCarry(value uint8) {
+	sum := uint16(cpu.A) + uint16(value)
+	if cpu.Flags.HasCarry() {
+		sum++
+	}
+	result := uint8(sum)
+	// Overflow is set when both operands have the same sign, different from result
+	cpu.Flags.SetOverflow((cpu.A^result)&(value^result)&0x80 != 0)
+	cpu.Flags.SetCarry(sum > 0xFF)
+	cpu.A = result
+	cpu.updateNZ(cpu.A)
+}
+
+func (cpu *CPU) carry() int {
+	if cpu.Flags.HasCarry() {
+		return 1
+	}
+	return 0
+}
+
+// addDecimal adds digits separately, also for invalid BCD values. Overflow and NMOS
+// negative flag come from sum before high digit is adjusted, NMOS zero flag from binary sum.
+func (cpu *CPU) addDecimal(value uint8) {
+	carry := cpu.carry()
+	low := int(cpu.A&0x0F) + int(value&0x0F) + carry
+	if low >= 0x0A {
+		low = ((low + 0x06) & 0x0F) + 0x10
+	}
+	sum := int(cpu.A&0xF0) + int(value&0xF0) + low
+	signed := int(int8(cpu.A&0xF0)) + int(int8(value&0xF0)) + low
+	zero := cpu.A+value+uint8(carry) == 0
+	if sum >= 0xA0 {
+		sum += 0x60
+	}
+	cpu.A = uint8(sum)
+	cpu.Flags.SetCarry(sum >= 0x100)
+	cpu.Flags.SetOverflow(signed < -128 || signed > 127)
+	if cpu.DecimalMode == DecimalCMOS {
+		cpu.updateNZ(cpu.A)
+		return
+	}
+	cpu.Flags.SetZero(zero)
+	cpu.Flags.SetNegative(signed&0x80 != 0)
+}
+
+// subtractDecimal sets flags as binary subtraction, 65C02 then updates N and Z for BCD result
+func (cpu *CPU) subtractDecimal(value uint8) {
+	borrow := 1 - cpu.carry()
+	low := int(cpu.A&0x0F) - int(value&0x0F) - borrow
+	var difference int
+	if cpu.DecimalMode == DecimalCMOS {
+		difference = int(cpu.A) - int(value) - borrow
+		if difference < 0 {
+			difference -= 0x60
+		}
+		if low < 0 {
+			difference -= 0x06
+		}
+	} else {
+		if low < 0 {
+			low = ((low - 0x06) & 0x0F) - 0x10
+		}
+		difference = int(cpu.A&0xF0) - int(value&0xF0) + low
+		if difference < 0 {
+			difference -= 0x60
+		}
+	}
+	cpu.addWithCarry(^value)
+	cpu.A = uint8(difference)
+	if cpu.DecimalMode == DecimalCMOS {
+		cpu.updateNZ(cpu.A)
+	}
+}
+
+func (cpu *CPU) and(address uint16) {
+	cpu.A &= cpu.read(address)
+	cpu.updateNZ(cpu.A)
+}
+
+func (cpu *CPU) ora(address uint16) {
+	cpu.A |= cpu.read(address)
+	cpu.updateNZ(cpu.A)
+}
+
+func (cpu *CPU) eor(address uint16) {
+	cpu.A ^= cpu.read(address)
+	cpu.updateNZ(cpu.A)
+}
+
+func (cpu *CPU) bit(address uint16) {
+	value := cpu.read(address)
+	cpu.Flags.SetZero(cpu.A&value == 0)
+	cpu.Flags.SetNegative(value&(1<<7) > 0)
+	cpu.Flags.SetOverflow(value&(1<<6) > 0)
+}
+
+// modify replaces value at address with result of read-modify-write operation and returns it.
+// NMOS 6502 writes unmodified value back while it computes the result, 65C02 reads it again.
+func (cpu *CPU) modify(address uint16, operation func(value uint8) uint8) uint8 {
+	value := cpu.read(address)
+	if cpu.Variant.nmos() {
+		cpu.dummyWrite(address, value)
+	} else {
+		cpu.dummyRead(address)
+	}
+	value = operation(value)
+	cpu.write(address, value)
+	return value
+}
+
+func (cpu *CPU) asl(value uint8) uint8 {
+	cpu.Flags.SetCarry(value&(1<<7) > 0)
+	value <<= 1
+	cpu.updateNZ(value)
+	return value
+}
+
+func (cpu *CPU) lsr(value uint8) uint8 {
+	cpu.Flags.SetCarry(value&1 > 0)
+	value >>= 1
+	cpu.updateNZ(value)
+	return value
+}
+
+func (cpu *CPU) rol(value uint8) uint8 {
+	carry := cpu.Flags.HasCarry()
+	cpu.Flags.SetCarry(value&(1<<7) > 0)
+	value <<= 1
+	if carry {
+		value |= 1
+	}
+	cpu.updateNZ(value)
+	return value
+}
+
+func (cpu *CPU) ror(value uint8) uint8 {
+	carry := cpu.Flags.HasCarry()
+	cpu.Flags.SetCarry(value&1 > 0)
+	value >>= 1
+	if carry {
+		value |= 1 << 7
+	}
+	cpu.updateNZ(value)
+	return value
+}
+
+func (cpu *CPU) inc(value uint8) uint8 {
+	value++
+	cpu.updateNZ(value)
+	return value
+}
+
+func (cpu *CPU) dec(value uint8) uint8 {
+	value--
+	cpu.updateNZ(value)
+	return value
+}
+
+// branch reads relative offset and jumps when condition is met
+func (cpu *CPU) branch(condition bool) {
+	offset := int8(cpu.getNextInstruction())
+	if condition {
+		// Taken branch reads the next opcode, and address with wrong high byte when page is crossed
+		cpu.dummyRead(cpu.PC)
+		target := cpu.PC + uint16(offset)
+		if target&0xFF00 != cpu.PC&0xFF00 {
+			cpu.dummyRead(cpu.PC&0xFF00 | target&0x00FF)
+		}
+		cpu.PC = target
+	}
+}
+
 func (cpu *CPU) jmp_absolute() {
 	lowerBytes := uint16(cpu.getNextInstruction())
 	higherBytes := uint16(cpu.getNextInstruction()) << 8
 	cpu.PC = higherBytes + lowerBytes
 }
 
+// jmp_indirect on NMOS 6502 doesn't carry into high byte of pointer, so pointer at $xxFF
+// reads its high byte from $xx00. 65C02 fixes that at cost of one more cycle,
+// which reads last byte of instruction again.
 func (cpu *CPU) jmp_indirect() {
 	lowerLocationBytes := uint16(cpu.getNextInstruction())
 	higherLocationBytes := uint16(cpu.getNextInstruction()) << 8
 	location := higherLocationBytes + lowerLocationBytes
-	lowerBytes := uint16(cpu.Memory.Get(location))
-	higherBytes := uint16(cpu.Memory.Get(location+1)) << 8
+	higherLocation := location + 1
+	if cpu.Variant.nmos() {
+		higherLocation = higherLocationBytes + uint16(uint8(lowerLocationBytes+1))
+	} else {
+		cpu.dummyRead(cpu.PC - 1)
+	}
+	lowerBytes := uint16(cpu.read(location))
+	higherBytes := uint16(cpu.read(higherLocation)) << 8
 	cpu.PC = higherBytes + lowerBytes
-
 }
 
+// jsr_absolute pushes address of its last byte, high byte of target is read after that as on hardware
 func (cpu *CPU) jsr_absolute() {
 	lowerBytes := uint16(cpu.getNextInstruction())
+	cpu.dummyRead(cpu.stackPointerAddress())
 	cpu.push(uint8(cpu.PC >> 8))
 	cpu.push(uint8(cpu.PC))
 	higherBytes := uint16(cpu.getNextInstruction()) << 8
 	cpu.PC = higherBytes + lowerBytes
 }
 
+// rts continues after address pulled from stack, which points to the last byte of JSR
 func (cpu *CPU) rts() {
+	cpu.dummyRead(cpu.stackPointerAddress())
+	lowerBytes := uint16(cpu.pop())
+	higherBytes := uint16(cpu.pop()) << 8
+	cpu.PC = higherBytes + lowerBytes
+	cpu.dummyRead(cpu.PC)
+	cpu.PC++
+}
+
+// brk pushes address after its padding byte and flags with break bit, then jumps through IRQ vector
+func (cpu *CPU) brk() {
+	cpu.dummyRead(cpu.PC)
+	cpu.PC++
+	cpu.interrupt(IRQVectorL, true)
+}
+
+// IRQ requests interrupt, which is taken by the next Advance or Tick instead of instruction,
+// unless interrupts are disabled by then. It also ends WAI, which continues with the next
+// instruction when interrupts are disabled.
+func (cpu *CPU) IRQ() {
+	if cpu.State.Halted() {
+		return
+	}
+	cpu.State = Running
+	cpu.irqPending = true
+}
+
+// NMI requests non-maskable interrupt, which is taken by the next Advance or Tick
+func (cpu *CPU) NMI() {
+	if cpu.State.Halted() {
+		return
+	}
+	cpu.State = Running
+	cpu.nmiPending = true
+}
+
+// nextInterrupt returns vector of interrupt to be taken at instruction boundary, 0 if there is none,
+// and clears its request. NMI goes first, IRQ is dropped when interrupts are disabled.
+func (cpu *CPU) nextInterrupt() uint16 {
+	switch {
+	case cpu.nmiPending:
+		cpu.nmiPending = false
+		return NMIVectorL
+	case cpu.irqPending:
+		cpu.irqPending = false
+		if !cpu.Flags.HasInterruptDisable() {
+			return IRQVectorL
+		}
+	}
+	return 0
+}
+
+// enterInterrupt takes 7 cycles like BRK, but opcode and padding byte reads don't move PC
+func (cpu *CPU) enterInterrupt() {
+	cpu.dummyRead(cpu.PC)
+	cpu.dummyRead(cpu.PC)
+	cpu.interrupt(cpu.interrupting, false)
+	cpu.interrupting = 0
+}
+
+// interrupt pushes PC and flags, break bit tells software interrupt from hardware one
+func (cpu *CPU) interrupt(vector uint16, brk bool) {
+	cpu.push(uint8(cpu.PC >> 8))
+	cpu.push(uint8(cpu.PC))
+	status := cpu.Flags.Value()
+	if brk {
+		status |= BreakBit
+	}
+	cpu.push(status)
+	cpu.Flags.SetInterruptDisable(true)
+	if cpu.Variant == WDC65C02 {
+		cpu.Flags.SetDecimal(false)
+	}
+	lowerBytes := uint16(cpu.read(vector))
+	cpu.PC = uint16(cpu.read(vector+1))<<8 + lowerBytes
+}
+
+func (cpu *CPU) rti() {
+	cpu.dummyRead(cpu.stackPointerAddress())
+	cpu.Flags.SetValue(cpu.pop())
 	lowerBytes := uint16(cpu.pop())
 	higherBytes := uint16(cpu.pop()) << 8
 	cpu.PC = higherBytes + lowerBytes
@@ -385,11 +1227,11 @@ func (cpu *CPU) rts() {
 
 func (cpu *CPU) pop() uint8 {
 	cpu.S++
-	return cpu.Memory.Get(cpu.stackPointerAddress())
+	return cpu.read(cpu.stackPointerAddress())
 }
 
 func (cpu *CPU) push(value uint8) {
-	cpu.Memory.Set(cpu.stackPointerAddress(), value)
+	cpu.write(cpu.stackPointerAddress(), value)
 	cpu.S--
 }
 
@@ -402,32 +1244,112 @@ func (cpu *CPU) pha() {
 }
 
 func (cpu *CPU) php() {
-	cpu.push(cpu.Flags.val)
+	cpu.push(cpu.Flags.Value() | BreakBit)
 }
 
 func (cpu *CPU) pla() {
+	cpu.dummyRead(cpu.stackPointerAddress())
 	cpu.A = cpu.pop()
 	cpu.updateNZ(cpu.A)
 }
 
 func (cpu *CPU) plp() {
-	cpu.Flags.val = cpu.pop()
+	cpu.dummyRead(cpu.stackPointerAddress())
+	cpu.Flags.SetValue(cpu.pop())
 }
 
-func NewDefaultMemoryCPU() *CPU {
-	return &CPU{
-		A:      0,
-		Y:      0,
-		X:      0,
-		PC:     0,
-		S:      0xFF,
-		Flags:  &Flags{0},
-		Memory: DefaultMemory(),
+func (cpu *CPU) tsx() {
+	cpu.X = cpu.S
+	cpu.updateNZ(cpu.X)
+}
+
+func (cpu *CPU) txs() {
+	cpu.S = cpu.X
+}
+
+func (cpu *CPU) immediateAddress() uint16 {
+	address := cpu.PC
+	cpu.PC++
+	return address
+}
+
+func (cpu *CPU) zeropageAddress() uint16 {
+	return uint16(cpu.getNextInstruction())
+}
+
+// Zero page indexed addresses wrap around within zero page, base address is read while index is added
+func (cpu *CPU) zeropageXAddress() uint16 {
+	base := cpu.getNextInstruction()
+	cpu.dummyRead(uint16(base))
+	return uint16(base + cpu.X)
+}
+
+func (cpu *CPU) zeropageYAddress() uint16 {
+	base := cpu.getNextInstruction()
+	cpu.dummyRead(uint16(base))
+	return uint16(base + cpu.Y)
+}
+
+func (cpu *CPU) absoluteAddress() uint16 {
+	return uint16(cpu.getNextInstruction()) + uint16(cpu.getNextInstruction())<<8
+}
+
+func (cpu *CPU) absoluteXAddress() uint16 {
+	return cpu.indexed(cpu.absoluteAddress(), cpu.X, false)
+}
+
+func (cpu *CPU) absoluteYAddress() uint16 {
+	return cpu.indexed(cpu.absoluteAddress(), cpu.Y, false)
+}
+
+// Write addresses are used by stores and read-modify-write instructions,
+// which always take the cycle to carry into high byte
+func (cpu *CPU) absoluteXWriteAddress() uint16 {
+	return cpu.indexed(cpu.absoluteAddress(), cpu.X, true)
+}
+
+func (cpu *CPU) absoluteYWriteAddress() uint16 {
+	return cpu.indexed(cpu.absoluteAddress(), cpu.Y, true)
+}
+
+// shiftAbsoluteXAddress is address of shifts and rotations, which 65C02 does faster within page
+func (cpu *CPU) shiftAbsoluteXAddress() uint16 {
+	return cpu.indexed(cpu.absoluteAddress(), cpu.X, cpu.Variant.nmos())
+}
+
+// indexed adds index to base address. Carry into high byte takes one more cycle,
+// which reads address with high byte of base.
+func (cpu *CPU) indexed(base uint16, index uint8, write bool) uint16 {
+	address := base + uint16(index)
+	if write || address&0xFF00 != base&0xFF00 {
+		cpu.dummyRead(base&0xFF00 | address&0x00FF)
 	}
+	return address
+}
+
+// indexedIndirectAddress reads pointer at (zp,X), pointer itself wraps within zero page
+func (cpu *CPU) indexedIndirectAddress() uint16 {
+	base := cpu.getNextInstruction()
+	cpu.dummyRead(uint16(base))
+	pointer := base + cpu.X
+	return uint16(cpu.read(uint16(pointer))) + uint16(cpu.read(uint16(pointer+1)))<<8
+}
+
+// indirectIndexedAddress reads pointer at (zp) and adds Y to it
+func (cpu *CPU) indirectIndexedAddress() uint16 {
+	return cpu.indexed(cpu.zeropageIndirectAddress(), cpu.Y, false)
 }
 
-func NewCPU(memory *Memory) *CPU {
-	return &CPU{
+func (cpu *CPU) indirectIndexedWriteAddress() uint16 {
+	return cpu.indexed(cpu.zeropageIndirectAddress(), cpu.Y, true)
+}
+
+func NewDefaultMemoryCPU(options ...Option) *CPU {
+	return NewCPU(DefaultMemory(), options...)
+}
+
+func NewCPU(memory *Memory, options ...Option) *CPU {
+	cpu := &CPU{
 		A:      0,
 		Y:      0,
 		X:      0,
@@ -436,4 +1358,11 @@ func NewCPU(memory *Memory) *CPU {
 		Flags:  &Flags{0},
 		Memory: memory,
 	}
+	for _, option := range options {
+		option(cpu)
+	}
+	if cpu.Variant == MOS6510 {
+		cpu.Port = memory.port6510()
+	}
+	return cpu
 }
@@ -0,0 +1,789 @@
+package go6502
+
+import "fmt"
+
+// Native mode vectors of 65816, emulation mode uses the 6502 ones and COPVector
+const (
+	COPVector       = 0xFFF4
+	NativeCOPVector = 0xFFE4
+	NativeBRKVector = 0xFFE6
+	NativeNMIVector = 0xFFEA
+	NativeIRQVector = 0xFFEE
+)
+
+// Bits of 65816 status register in native mode, they are break and unused bits in emulation mode
+const (
+	IndexSelectBit  = 1 << 4
+	MemorySelectBit = 1 << 5
+)
+
+// CPU65816 is WDC 65C816 core with 16 bit registers and 24 bit addresses. After reset it's
+// in emulation mode, which works like 65C02 with stack in page 1, XCE switches to native mode.
+type CPU65816 struct {
+	// C is 16 bit accumulator, its low byte is A and high byte is B
+	C uint16
+	X uint16
+	Y uint16
+	S uint16
+	// D is start of direct page, which replaces zero page
+	D uint16
+	// DBR is bank of data addresses, PBR is bank of PC
+	DBR   uint8
+	PBR   uint8
+	PC    uint16
+	Flags *Flags
+	// MemorySelect (M flag) makes accumulator and memory operations 8 bit
+	MemorySelect bool
+	// IndexSelect (X flag) makes index registers 8 bit, their high bytes are cleared
+	IndexSelect bool
+	// Emulation mode (E flag) keeps M and X flags set and stack in page 1
+	Emulation bool
+	Memory    *Memory24
+	State     RunState
+	// irqPending and nmiPending latch interrupts requested until the next instruction boundary
+	irqPending bool
+	nmiPending bool
+}
+
+// operand65816 is effective address of operand. Mask tells which address bits change when
+// operand continues in the next byte, e.g. direct page and stack operands wrap within bank 0.
+type operand65816 struct {
+	address uint32
+	mask    uint32
+}
+
+func (o operand65816) next() operand65816 {
+	return operand65816{o.address&^o.mask | (o.address+1)&o.mask, o.mask}
+}
+
+func (cpu *CPU65816) String() string {
+	return fmt.Sprintf("C: %04X\tX: %04X\tY: %04X\tS: %04X\tD: %04X\tDBR: %02X\nPC: %02X:%04X\tFlags: %s",
+		cpu.C, cpu.X, cpu.Y, cpu.S, cpu.D, cpu.DBR, cpu.PBR, cpu.PC, cpu.flagsString())
+}
+
+// flagsString shows flags as NVMXDIZC, followed by E in emulation mode
+func (cpu *CPU65816) flagsString() string {
+	value := cpu.P()
+	flags := []byte("NVMXDIZC")
+	for i := range flags {
+		if value&(0x80>>i) == 0 {
+			flags[i] += 'a' - 'A'
+		}
+	}
+	if cpu.Emulation {
+		return string(flags) + " E"
+	}
+	return string(flags)
+}
+
+// P returns status register, M and X bits read as 1 in emulation mode
+func (cpu *CPU65816) P() uint8 {
+	value := cpu.Flags.val
+	if cpu.MemorySelect {
+		value |= MemorySelectBit
+	}
+	if cpu.IndexSelect {
+		value |= IndexSelectBit
+	}
+	return value
+}
+
+// SetP sets status register, as PLP, REP or SEP. M and X stay set in emulation mode.
+func (cpu *CPU65816) SetP(value uint8) {
+	cpu.Flags.SetValue(value)
+	if cpu.Emulation {
+		return
+	}
+	cpu.MemorySelect = value&MemorySelectBit != 0
+	cpu.IndexSelect = value&IndexSelectBit != 0
+	if cpu.IndexSelect {
+		cpu.X &= 0xFF
+		cpu.Y &= 0xFF
+	}
+}
+
+// SetEmulation switches mode as XCE, entering emulation mode makes registers 8 bit
+func (cpu *CPU65816) SetEmulation(emulation bool) {
+	cpu.Emulation = emulation
+	if emulation {
+		cpu.MemorySelect = true
+		cpu.IndexSelect = true
+		cpu.X &= 0xFF
+		cpu.Y &= 0xFF
+		cpu.S = 0x0100 | cpu.S&0xFF
+	}
+}
+
+// Initialize resets CPU to emulation mode and jumps through reset vector in bank 0
+func (cpu *CPU65816) Initialize() {
+	cpu.SetEmulation(true)
+	cpu.D, cpu.DBR, cpu.PBR = 0, 0, 0
+	cpu.S = 0x01FF
+	cpu.Flags.SetDecimal(false)
+	cpu.Flags.SetInterruptDisable(true)
+	cpu.PC = cpu.read16(operand65816{ResetVectorL, 0xFFFF})
+	cpu.State = Running
+	cpu.irqPending, cpu.nmiPending = false, false
+}
+
+func (cpu *CPU65816) Advance() {
+	if cpu.State != Running {
+		return
+	}
+	if vector, nativeVector := cpu.nextInterrupt(); vector != 0 {
+		cpu.interrupt(vector, nativeVector, false)
+		return
+	}
+	instruction := WDC65816[cpu.fetch()]
+	// Width of accumulator and memory operands, index instructions use their own
+	wide := !cpu.MemorySelect
+	indexWide := !cpu.IndexSelect
+	switch instruction.Mnemonic {
+	case "NOP":
+	case "WDM":
+		cpu.fetch()
+
+	case "LDA":
+		cpu.setA(cpu.load(cpu.operand(instruction.Mode, wide), wide))
+		cpu.updateNZ(cpu.a(), wide)
+	case "STA":
+		cpu.store(cpu.operand(instruction.Mode, wide), cpu.a(), wide)
+	case "STZ":
+		cpu.store(cpu.operand(instruction.Mode, wide), 0, wide)
+	case "ORA":
+		cpu.setA(cpu.a() | cpu.load(cpu.operand(instruction.Mode, wide), wide))
+		cpu.updateNZ(cpu.a(), wide)
+	case "AND":
+		cpu.setA(cpu.a() & cpu.load(cpu.operand(instruction.Mode, wide), wide))
+		cpu.updateNZ(cpu.a(), wide)
+	case "EOR":
+		cpu.setA(cpu.a() ^ cpu.load(cpu.operand(instruction.Mode, wide), wide))
+		cpu.updateNZ(cpu.a(), wide)
+	case "ADC":
+		cpu.add(cpu.load(cpu.operand(instruction.Mode, wide), wide), wide)
+	case "SBC":
+		cpu.subtract(cpu.load(cpu.operand(instruction.Mode, wide), wide), wide)
+	case "CMP":
+		cpu.compare(cpu.a(), cpu.load(cpu.operand(instruction.Mode, wide), wide), wide)
+	case "BIT":
+		value := cpu.load(cpu.operand(instruction.Mode, wide), wide)
+		cpu.Flags.SetZero(cpu.a()&value == 0)
+		if instruction.Mode != Immediate {
+			sign := signBit(wide)
+			cpu.Flags.SetNegative(value&sign != 0)
+			cpu.Flags.SetOverflow(value&(sign>>1) != 0)
+		}
+	case "TSB", "TRB":
+		operand := cpu.operand(instruction.Mode, wide)
+		value := cpu.load(operand, wide)
+		cpu.Flags.SetZero(cpu.a()&value == 0)
+		if instruction.Mnemonic == "TSB" {
+			cpu.store(operand, value|cpu.a(), wide)
+		} else {
+			cpu.store(operand, value&^cpu.a(), wide)
+		}
+
+	case "ASL", "LSR", "ROL", "ROR", "INC", "DEC":
+		if instruction.Mode == Accumulator {
+			cpu.setA(cpu.shift(instruction.Mnemonic, cpu.a(), wide))
+			break
+		}
+		operand := cpu.operand(instruction.Mode, wide)
+		cpu.store(operand, cpu.shift(instruction.Mnemonic, cpu.load(operand, wide), wide), wide)
+
+	case "LDX":
+		cpu.X = cpu.load(cpu.operand(instruction.Mode, indexWide), indexWide)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "LDY":
+		cpu.Y = cpu.load(cpu.operand(instruction.Mode, indexWide), indexWide)
+		cpu.updateNZ(cpu.Y, indexWide)
+	case "STX":
+		cpu.store(cpu.operand(instruction.Mode, indexWide), cpu.X, indexWide)
+	case "STY":
+		cpu.store(cpu.operand(instruction.Mode, indexWide), cpu.Y, indexWide)
+	case "CPX":
+		cpu.compare(cpu.X, cpu.load(cpu.operand(instruction.Mode, indexWide), indexWide), indexWide)
+	case "CPY":
+		cpu.compare(cpu.Y, cpu.load(cpu.operand(instruction.Mode, indexWide), indexWide), indexWide)
+	case "INX":
+		cpu.X = cpu.index(cpu.X + 1)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "INY":
+		cpu.Y = cpu.index(cpu.Y + 1)
+		cpu.updateNZ(cpu.Y, indexWide)
+	case "DEX":
+		cpu.X = cpu.index(cpu.X - 1)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "DEY":
+		cpu.Y = cpu.index(cpu.Y - 1)
+		cpu.updateNZ(cpu.Y, indexWide)
+
+	case "TAX":
+		cpu.X = cpu.index(cpu.C)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "TAY":
+		cpu.Y = cpu.index(cpu.C)
+		cpu.updateNZ(cpu.Y, indexWide)
+	case "TXA":
+		cpu.setA(cpu.X)
+		cpu.updateNZ(cpu.a(), wide)
+	case "TYA":
+		cpu.setA(cpu.Y)
+		cpu.updateNZ(cpu.a(), wide)
+	case "TXY":
+		cpu.Y = cpu.X
+		cpu.updateNZ(cpu.Y, indexWide)
+	case "TYX":
+		cpu.X = cpu.Y
+		cpu.updateNZ(cpu.X, indexWide)
+	case "TSX":
+		cpu.X = cpu.index(cpu.S)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "TXS":
+		cpu.setS(cpu.X)
+	case "TCS":
+		cpu.setS(cpu.C)
+	case "TSC":
+		cpu.C = cpu.S
+		cpu.updateNZ(cpu.C, true)
+	case "TCD":
+		cpu.D = cpu.C
+		cpu.updateNZ(cpu.D, true)
+	case "TDC":
+		cpu.C = cpu.D
+		cpu.updateNZ(cpu.C, true)
+	case "XBA":
+		cpu.C = cpu.C<<8 | cpu.C>>8
+		cpu.updateNZ(cpu.C&0xFF, false)
+
+	case "CLC":
+		cpu.Flags.SetCarry(false)
+	case "SEC":
+		cpu.Flags.SetCarry(true)
+	case "CLI":
+		cpu.Flags.SetInterruptDisable(false)
+	case "SEI":
+		cpu.Flags.SetInterruptDisable(true)
+	case "CLD":
+		cpu.Flags.SetDecimal(false)
+	case "SED":
+		cpu.Flags.SetDecimal(true)
+	case "CLV":
+		cpu.Flags.SetOverflow(false)
+	case "REP":
+		cpu.SetP(cpu.P() &^ cpu.fetch())
+	case "SEP":
+		cpu.SetP(cpu.P() | cpu.fetch())
+	case "XCE":
+		carry := cpu.Flags.HasCarry()
+		cpu.Flags.SetCarry(cpu.Emulation)
+		cpu.SetEmulation(carry)
+
+	case "BPL":
+		cpu.branch(!cpu.Flags.HasNegative())
+	case "BMI":
+		cpu.branch(cpu.Flags.HasNegative())
+	case "BVC":
+		cpu.branch(!cpu.Flags.HasOverflow())
+	case "BVS":
+		cpu.branch(cpu.Flags.HasOverflow())
+	case "BCC":
+		cpu.branch(!cpu.Flags.HasCarry())
+	case "BCS":
+		cpu.branch(cpu.Flags.HasCarry())
+	case "BNE":
+		cpu.branch(!cpu.Flags.HasZero())
+	case "BEQ":
+		cpu.branch(cpu.Flags.HasZero())
+	case "BRA":
+		cpu.branch(true)
+	case "BRL":
+		offset := cpu.fetch16()
+		cpu.PC += offset
+
+	case "JMP", "JML", "JSR", "JSL":
+		cpu.jump(instruction)
+	case "RTS":
+		cpu.PC = cpu.pull16() + 1
+	case "RTL":
+		cpu.PC = cpu.pull16() + 1
+		cpu.PBR = cpu.pull()
+	case "RTI":
+		cpu.SetP(cpu.pull())
+		cpu.PC = cpu.pull16()
+		if !cpu.Emulation {
+			cpu.PBR = cpu.pull()
+		}
+	case "BRK":
+		cpu.PC++
+		cpu.interrupt(IRQVectorL, NativeBRKVector, true)
+	case "COP":
+		cpu.PC++
+		cpu.interrupt(COPVector, NativeCOPVector, false)
+
+	case "PHA":
+		cpu.pushValue(cpu.a(), wide)
+	case "PLA":
+		cpu.setA(cpu.pullValue(wide))
+		cpu.updateNZ(cpu.a(), wide)
+	case "PHX":
+		cpu.pushValue(cpu.X, indexWide)
+	case "PLX":
+		cpu.X = cpu.pullValue(indexWide)
+		cpu.updateNZ(cpu.X, indexWide)
+	case "PHY":
+		cpu.pushValue(cpu.Y, indexWide)
+	case "PLY":
+		cpu.Y = cpu.pullValue(indexWide)
+		cpu.updateNZ(cpu.Y, indexWide)
+	case "PHP":
+		if cpu.Emulation {
+			cpu.push(cpu.P() | BreakBit | UnusedBit)
+		} else {
+			cpu.push(cpu.P())
+		}
+	case "PLP":
+		cpu.SetP(cpu.pull())
+	case "PHB":
+		cpu.push(cpu.DBR)
+	case "PLB":
+		cpu.DBR = cpu.pull()
+		cpu.updateNZ(uint16(cpu.DBR), false)
+	case "PHK":
+		cpu.push(cpu.PBR)
+	case "PHD":
+		cpu.push16(cpu.D)
+	case "PLD":
+		cpu.D = cpu.pull16()
+		cpu.updateNZ(cpu.D, true)
+	case "PEA":
+		cpu.push16(cpu.fetch16())
+	case "PEI":
+		cpu.push16(cpu.read16(cpu.direct(cpu.fetch(), 0)))
+	case "PER":
+		offset := cpu.fetch16()
+		cpu.push16(cpu.PC + offset)
+
+	case "MVN", "MVP":
+		cpu.blockMove(instruction.Mnemonic == "MVN")
+
+	case "WAI":
+		cpu.State = Waiting
+	case "STP":
+		cpu.State = Stopped
+	}
+}
+
+// IRQ requests interrupt, which is taken by the next Advance unless interrupts are disabled. It also ends WAI.
+func (cpu *CPU65816) IRQ() {
+	if cpu.State.Halted() {
+		return
+	}
+	cpu.State = Running
+	cpu.irqPending = true
+}
+
+// NMI requests non-maskable interrupt, which is taken by the next Advance
+func (cpu *CPU65816) NMI() {
+	if cpu.State.Halted() {
+		return
+	}
+	cpu.State = Running
+	cpu.nmiPending = true
+}
+
+// nextInterrupt returns vectors of interrupt to be taken at instruction boundary, 0 if there is none,
+// and clears its request. NMI goes first, IRQ is dropped when interrupts are disabled.
+func (cpu *CPU65816) nextInterrupt() (uint16, uint16) {
+	switch {
+	case cpu.nmiPending:
+		cpu.nmiPending = false
+		return NMIVectorL, NativeNMIVector
+	case cpu.irqPending:
+		cpu.irqPending = false
+		if !cpu.Flags.HasInterruptDisable() {
+			return IRQVectorL, NativeIRQVector
+		}
+	}
+	return 0, 0
+}
+
+// interrupt pushes program bank in native mode, PC and flags, then jumps through vector in bank 0
+func (cpu *CPU65816) interrupt(vector uint16, nativeVector uint16, brk bool) {
+	if cpu.Emulation {
+		cpu.push16(cpu.PC)
+		status := cpu.P() | UnusedBit
+		if brk {
+			status |= BreakBit
+		} else {
+			status &^= BreakBit
+		}
+		cpu.push(status)
+	} else {
+		cpu.push(cpu.PBR)
+		cpu.push16(cpu.PC)
+		cpu.push(cpu.P())
+		vector = nativeVector
+	}
+	cpu.Flags.SetInterruptDisable(true)
+	cpu.Flags.SetDecimal(false)
+	cpu.PBR = 0
+	cpu.PC = cpu.read16(operand65816{uint32(vector), 0xFFFF})
+}
+
+// jump handles JMP, JML, JSR and JSL. Indirect pointers are in bank 0, indexed ones in program bank.
+func (cpu *CPU65816) jump(instruction Instruction) {
+	var target uint16
+	bank := cpu.PBR
+	switch instruction.Mode {
+	case Absolute:
+		target = cpu.fetch16()
+	case AbsoluteLong:
+		target = cpu.fetch16()
+		bank = cpu.fetch()
+	case Indirect:
+		target = cpu.read16(operand65816{uint32(cpu.fetch16()), 0xFFFF})
+	case AbsoluteIndexedIndirect:
+		pointer := uint32(cpu.PBR)<<16 | uint32(cpu.fetch16()+cpu.X)
+		target = cpu.read16(operand65816{pointer, 0xFFFF})
+	case AbsoluteIndirectLong:
+		pointer := operand65816{uint32(cpu.fetch16()), 0xFFFF}
+		target = cpu.read16(pointer)
+		bank = cpu.read(pointer.next().next())
+	}
+	switch instruction.Mnemonic {
+	case "JSL":
+		cpu.push(cpu.PBR)
+		fallthrough
+	case "JSR":
+		// Return address is the last byte of instruction, as on 6502
+		cpu.push16(cpu.PC - 1)
+	}
+	cpu.PBR = bank
+	cpu.PC = target
+}
+
+// blockMove copies one byte and repeats instruction until C underflows, so it can be interrupted
+func (cpu *CPU65816) blockMove(increment bool) {
+	cpu.DBR = cpu.fetch()
+	source := cpu.fetch()
+	value := cpu.read(operand65816{uint32(source)<<16 | uint32(cpu.X), 0})
+	cpu.write(operand65816{uint32(cpu.DBR)<<16 | uint32(cpu.Y), 0}, value)
+	if increment {
+		cpu.X, cpu.Y = cpu.index(cpu.X+1), cpu.index(cpu.Y+1)
+	} else {
+		cpu.X, cpu.Y = cpu.index(cpu.X-1), cpu.index(cpu.Y-1)
+	}
+	cpu.C--
+	if cpu.C != 0xFFFF {
+		cpu.PC -= 3
+	}
+}
+
+func (cpu *CPU65816) branch(condition bool) {
+	offset := int8(cpu.fetch())
+	if condition {
+		cpu.PC += uint16(offset)
+	}
+}
+
+// shift executes read-modify-write operation of given width
+func (cpu *CPU65816) shift(mnemonic string, value uint16, wide bool) uint16 {
+	sign := signBit(wide)
+	switch mnemonic {
+	case "ASL":
+		cpu.Flags.SetCarry(value&sign != 0)
+		value <<= 1
+	case "LSR":
+		cpu.Flags.SetCarry(value&1 != 0)
+		value >>= 1
+	case "ROL":
+		carry := cpu.Flags.HasCarry()
+		cpu.Flags.SetCarry(value&sign != 0)
+		value <<= 1
+		if carry {
+			value |= 1
+		}
+	case "ROR":
+		carry := cpu.Flags.HasCarry()
+		cpu.Flags.SetCarry(value&1 != 0)
+		value >>= 1
+		if carry {
+			value |= sign
+		}
+	case "INC":
+		value++
+	case "DEC":
+		value--
+	}
+	value &= sign<<1 - 1
+	cpu.updateNZ(value, wide)
+	return value
+}
+
+// add is ADC, decimal mode adds digit by digit with valid flags, like 65C02
+func (cpu *CPU65816) add(value uint16, wide bool) {
+	sign := uint32(signBit(wide))
+	mask := sign<<1 - 1
+	a, v := uint32(cpu.a()), uint32(value)
+	carry := uint32(cpu.carry())
+	var result uint32
+	if cpu.Flags.HasDecimal() {
+		for shift := 0; mask>>shift != 0; shift += 4 {
+			digit := a>>shift&0xF + v>>shift&0xF + carry
+			if mask>>shift == 0xF {
+				// Overflow comes from sum before the highest digit is adjusted
+				unadjusted := result | digit<<shift
+				cpu.Flags.SetOverflow((a^unadjusted)&(v^unadjusted)&sign != 0)
+			}
+			carry = 0
+			if digit > 9 {
+				digit += 6
+				carry = 1
+			}
+			result |= digit & 0xF << shift
+		}
+		cpu.Flags.SetCarry(carry != 0)
+	} else {
+		result = a + v + carry
+		cpu.Flags.SetOverflow((a^result)&(v^result)&sign != 0)
+		cpu.Flags.SetCarry(result > mask)
+	}
+	cpu.setA(uint16(result))
+	cpu.updateNZ(cpu.a(), wide)
+}
+
+// subtract is SBC, flags are set by binary subtraction, decimal mode then adjusts digits
+func (cpu *CPU65816) subtract(value uint16, wide bool) {
+	sign := signBit(wide)
+	a := cpu.a()
+	borrow := 1 - cpu.carry()
+	result := a - value - uint16(borrow)
+	cpu.Flags.SetOverflow((a^value)&(a^result)&sign != 0)
+	cpu.Flags.SetCarry(int(a)-int(value)-borrow >= 0)
+	if cpu.Flags.HasDecimal() {
+		result = 0
+		for shift := 0; sign>>shift != 0; shift += 4 {
+			digit := int(a>>shift&0xF) - int(value>>shift&0xF) - borrow
+			borrow = 0
+			if digit < 0 {
+				digit -= 6
+				borrow = 1
+			}
+			result |= uint16(digit&0xF) << shift
+		}
+	}
+	cpu.setA(result)
+	cpu.updateNZ(cpu.a(), wide)
+}
+
+func (cpu *CPU65816) compare(register uint16, value uint16, wide bool) {
+	if !wide {
+		register &= 0xFF
+	}
+	cpu.Flags.SetCarry(value <= register)
+	cpu.updateNZ(register-value, wide)
+}
+
+func (cpu *CPU65816) carry() int {
+	if cpu.Flags.HasCarry() {
+		return 1
+	}
+	return 0
+}
+
+// signBit returns the highest bit of 8 or 16 bit value
+func signBit(wide bool) uint16 {
+	if wide {
+		return 0x8000
+	}
+	return 0x80
+}
+
+func (cpu *CPU65816) updateNZ(value uint16, wide bool) {
+	sign := signBit(wide)
+	cpu.Flags.SetZero(value&(sign<<1-1) == 0)
+	cpu.Flags.SetNegative(value&sign != 0)
+}
+
+// a returns accumulator of current width, B is kept when it's 8 bit
+func (cpu *CPU65816) a() uint16 {
+	if cpu.MemorySelect {
+		return cpu.C & 0xFF
+	}
+	return cpu.C
+}
+
+func (cpu *CPU65816) setA(value uint16) {
+	if cpu.MemorySelect {
+		cpu.C = cpu.C&0xFF00 | value&0xFF
+	} else {
+		cpu.C = value
+	}
+}
+
+// index cuts value for index register of current width
+func (cpu *CPU65816) index(value uint16) uint16 {
+	if cpu.IndexSelect {
+		return value & 0xFF
+	}
+	return value
+}
+
+// setS sets stack pointer, which stays in page 1 in emulation mode
+func (cpu *CPU65816) setS(value uint16) {
+	if cpu.Emulation {
+		value = 0x0100 | value&0xFF
+	}
+	cpu.S = value
+}
+
+func (cpu *CPU65816) push(value uint8) {
+	cpu.write(operand65816{uint32(cpu.S), 0}, value)
+	cpu.setS(cpu.S - 1)
+}
+
+func (cpu *CPU65816) pull() uint8 {
+	cpu.setS(cpu.S + 1)
+	return cpu.read(operand65816{uint32(cpu.S), 0})
+}
+
+func (cpu *CPU65816) push16(value uint16) {
+	cpu.push(uint8(value >> 8))
+	cpu.push(uint8(value))
+}
+
+func (cpu *CPU65816) pull16() uint16 {
+	low := uint16(cpu.pull())
+	return uint16(cpu.pull())<<8 | low
+}
+
+func (cpu *CPU65816) pushValue(value uint16, wide bool) {
+	if wide {
+		cpu.push16(value)
+	} else {
+		cpu.push(uint8(value))
+	}
+}
+
+func (cpu *CPU65816) pullValue(wide bool) uint16 {
+	if wide {
+		return cpu.pull16()
+	}
+	return uint16(cpu.pull())
+}
+
+func (cpu *CPU65816) read(operand operand65816) uint8 {
+	return cpu.Memory.Get(operand.address)
+}
+
+func (cpu *CPU65816) write(operand operand65816, value uint8) {
+	cpu.Memory.Set(operand.address, value)
+}
+
+func (cpu *CPU65816) read16(operand operand65816) uint16 {
+	return uint16(cpu.read(operand)) | uint16(cpu.read(operand.next()))<<8
+}
+
+func (cpu *CPU65816) load(operand operand65816, wide bool) uint16 {
+	if wide {
+		return cpu.read16(operand)
+	}
+	return uint16(cpu.read(operand))
+}
+
+func (cpu *CPU65816) store(operand operand65816, value uint16, wide bool) {
+	cpu.write(operand, uint8(value))
+	if wide {
+		cpu.write(operand.next(), uint8(value>>8))
+	}
+}
+
+// fetch reads next byte of instruction, PC wraps within program bank
+func (cpu *CPU65816) fetch() uint8 {
+	value := cpu.read(operand65816{uint32(cpu.PBR)<<16 | uint32(cpu.PC), 0})
+	cpu.PC++
+	return value
+}
+
+func (cpu *CPU65816) fetch16() uint16 {
+	low := uint16(cpu.fetch())
+	return uint16(cpu.fetch())<<8 | low
+}
+
+// direct returns address in direct page. In emulation mode direct page starting at page boundary
+// works like zero page, so indexed addresses and pointers wrap within the page.
+func (cpu *CPU65816) direct(offset uint8, index uint16) operand65816 {
+	if cpu.Emulation && cpu.D&0xFF == 0 {
+		return operand65816{uint32(cpu.D | uint16(offset+uint8(index))), 0xFF}
+	}
+	return operand65816{uint32(cpu.D + uint16(offset) + index), 0xFFFF}
+}
+
+// data returns address in data bank, indexed addresses can cross to the next bank
+func (cpu *CPU65816) data(address uint16, index uint16) operand65816 {
+	return operand65816{(uint32(cpu.DBR)<<16 + uint32(address) + uint32(index)) & 0xFFFFFF, 0xFFFFFF}
+}
+
+// operand reads operand of instruction in given addressing mode and returns its effective address.
+// Immediate operand is 16 bit when wide.
+func (cpu *CPU65816) operand(mode AddressingMode, wide bool) operand65816 {
+	switch mode {
+	case Immediate:
+		operand := operand65816{uint32(cpu.PBR)<<16 | uint32(cpu.PC), 0xFFFF}
+		cpu.PC++
+		if wide {
+			cpu.PC++
+		}
+		return operand
+	case ZeroPage:
+		return cpu.direct(cpu.fetch(), 0)
+	case ZeroPageX:
+		return cpu.direct(cpu.fetch(), cpu.X)
+	case ZeroPageY:
+		return cpu.direct(cpu.fetch(), cpu.Y)
+	case Absolute:
+		return cpu.data(cpu.fetch16(), 0)
+	case AbsoluteX:
+		return cpu.data(cpu.fetch16(), cpu.X)
+	case AbsoluteY:
+		return cpu.data(cpu.fetch16(), cpu.Y)
+	case AbsoluteLong, AbsoluteLongX:
+		address := uint32(cpu.fetch16())
+		address |= uint32(cpu.fetch()) << 16
+		if mode == AbsoluteLongX {
+			address += uint32(cpu.X)
+		}
+		return operand65816{address & 0xFFFFFF, 0xFFFFFF}
+	case ZeroPageIndirect:
+		return cpu.data(cpu.read16(cpu.direct(cpu.fetch(), 0)), 0)
+	case IndexedIndirect:
+		return cpu.data(cpu.read16(cpu.direct(cpu.fetch(), cpu.X)), 0)
+	case IndirectIndexed:
+		return cpu.data(cpu.read16(cpu.direct(cpu.fetch(), 0)), cpu.Y)
+	case IndirectLong, IndirectLongIndexed:
+		pointer := cpu.direct(cpu.fetch(), 0)
+		address := uint32(cpu.read16(pointer)) | uint32(cpu.read(pointer.next().next()))<<16
+		if mode == IndirectLongIndexed {
+			address += uint32(cpu.Y)
+		}
+		return operand65816{address & 0xFFFFFF, 0xFFFFFF}
+	case StackRelative:
+		return operand65816{uint32(cpu.S + uint16(cpu.fetch())), 0xFFFF}
+	case StackRelativeIndirectIndexed:
+		pointer := operand65816{uint32(cpu.S + uint16(cpu.fetch())), 0xFFFF}
+		return cpu.data(cpu.read16(pointer), cpu.Y)
+	}
+	panic(fmt.Sprintf("addressing mode %d has no operand address", mode))
+}
+
+// NewCPU65816 creates CPU in emulation mode, call Initialize to start it from reset vector
+func NewCPU65816(memory *Memory24) *CPU65816 {
+	cpu := &CPU65816{
+		Flags:  &Flags{},
+		Memory: memory,
+	}
+	cpu.SetEmulation(true)
+	cpu.S = 0x01FF
+	return cpu
+}
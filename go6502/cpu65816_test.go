@@ -0,0 +1,224 @@
+package go6502
+
+import "testing"
+
+// new65816 creates CPU in native mode with 16 bit registers, 2 banks of RAM and program at 0x0200 of bank 0
+func new65816(program ...uint8) *CPU65816 {
+	cpu := NewCPU65816(NewMemory24(DefaultMemory(), DefaultMemory()))
+	cpu.Memory.Set(0x0200, program...)
+	cpu.PC = 0x0200
+	cpu.SetEmulation(false)
+	cpu.SetP(0)
+	return cpu
+}
+
+func TestMemory24(t *testing.T) {
+	bank := DefaultMemory()
+	memory := NewMemory24(DefaultMemory(), bank, bank)
+	memory.Set(0x01FFFF, 0x12, 0x34)
+	if memory.Get(0x01FFFF) != 0x12 || memory.Get(0x020000) != 0x34 {
+		t.Fatalf("Values should be written across banks. Expected %x %x, got %x %x",
+			0x12, 0x34, memory.Get(0x01FFFF), memory.Get(0x020000))
+	}
+	if memory.Get(0x010000) != 0x34 || memory.Get(0x000000) != 0 {
+		t.Fatalf("The same memory should be mirrored in its banks only")
+	}
+	memory.Set(0x030000, 0x56)
+	if memory.Get(0x030000) != 0xFF || memory.Bank(3) != nil {
+		t.Fatalf("Missing bank should read as %x, got %x", 0xFF, memory.Get(0x030000))
+	}
+}
+
+func TestInstructionSet65816(t *testing.T) {
+	for opcode, instruction := range WDC65816 {
+		if !instruction.Defined() {
+			t.Fatalf("All 65816 opcodes should be defined, %02X isn't", opcode)
+		}
+	}
+	if WDC65816[0x22].Mnemonic != "JSL" || WDC65816[0x22].Size() != 4 {
+		t.Fatalf("Wrong JSL instruction, got %v", WDC65816[0x22])
+	}
+}
+
+func TestEmulationMode65816(t *testing.T) {
+	cpu := NewCPU65816(NewMemory24(DefaultMemory()))
+	cpu.Memory.Set(ResetVectorL, 0x00, 0x02)
+	cpu.Memory.Set(0x0200, OpLDA_imm, 0x42, OpLDX_imm, 0xFF, OpTXS, OpPHA)
+	cpu.Initialize()
+	if !cpu.Emulation || !cpu.MemorySelect || !cpu.IndexSelect || cpu.S != 0x01FF || cpu.PC != 0x0200 {
+		t.Fatalf("Reset should start in emulation mode, got\n%v", cpu)
+	}
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if cpu.C != 0x42 || cpu.PC != 0x0206 || cpu.S != 0x01FE || cpu.Memory.Get(0x01FF) != 0x42 {
+		t.Fatalf("Emulation mode should use 8 bit registers and stack in page 1, got\n%v", cpu)
+	}
+	cpu.Memory.Set(0x0206, 0xFB) // XCE
+	cpu.Advance()
+	if cpu.Emulation || !cpu.Flags.HasCarry() || !cpu.MemorySelect {
+		t.Fatalf("XCE should switch to native mode with 8 bit registers, got\n%v", cpu)
+	}
+}
+
+func TestNativeMode16Bit(t *testing.T) {
+	cpu := new65816(
+		OpLDA_imm, 0x34, 0x12,
+		OpLDX_imm, 0xFF, 0xFF,
+		OpSTA_absolute, 0x00, 0x20,
+		OpINX,
+		0xEB,       // XBA
+		0xE2, 0x20, // SEP #$20
+		OpADC_imm, 0xEE)
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if cpu.C != 0x1234 || cpu.X != 0 || !cpu.Flags.HasZero() {
+		t.Fatalf("Registers should be 16 bit, got\n%v", cpu)
+	}
+	if cpu.Memory.Get(0x2000) != 0x34 || cpu.Memory.Get(0x2001) != 0x12 {
+		t.Fatalf("STA should store 16 bit value. Expected %x, got %x", 0x1234,
+			uint16(cpu.Memory.Get(0x2001))<<8|uint16(cpu.Memory.Get(0x2000)))
+	}
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.C != 0x3400 || !cpu.MemorySelect || !cpu.Flags.HasCarry() || !cpu.Flags.HasZero() {
+		t.Fatalf("8 bit accumulator should keep B. Expected C %x, got\n%v", 0x3400, cpu)
+	}
+}
+
+func TestIndexSelectClearsHighBytes(t *testing.T) {
+	cpu := new65816(0xE2, 0x10) // SEP #$10
+	cpu.X, cpu.Y = 0x1234, 0x5678
+	cpu.Advance()
+	if cpu.X != 0x34 || cpu.Y != 0x78 {
+		t.Fatalf("Setting X flag should clear high bytes of index registers. Expected %x %x, got %x %x",
+			0x34, 0x78, cpu.X, cpu.Y)
+	}
+}
+
+func TestLongAddressing(t *testing.T) {
+	cpu := new65816(
+		OpLDA_absolute+2, 0x45, 0x23, 0x01, // LDA $012345
+		0x97, 0x10, // STA [$10],Y
+		0x22, 0x00, 0x80, 0x01, // JSL $018000
+		OpINX)
+	cpu.Memory.Set(0x012345, 0xCD, 0xAB)
+	cpu.Memory.Set(0x0010, 0x00, 0x30, 0x01)
+	cpu.Memory.Set(0x018000, OpINY, 0x6B) // INY, RTL
+	cpu.Y = 0x02
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.C != 0xABCD || cpu.Memory.Get(0x013002) != 0xCD || cpu.Memory.Get(0x013003) != 0xAB {
+		t.Fatalf("Long addresses should reach other banks. Expected %x, got\n%v", 0xABCD, cpu)
+	}
+	cpu.Advance()
+	if cpu.PBR != 0x01 || cpu.PC != 0x8000 || cpu.S != 0x01FC {
+		t.Fatalf("JSL should jump to other bank. Expected PC %x, got %02X:%04X", 0x018000, cpu.PBR, cpu.PC)
+	}
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.PBR != 0 || cpu.PC != 0x020B || cpu.X != 1 || cpu.Y != 3 {
+		t.Fatalf("RTL should return to calling bank. Expected PC %x, got %02X:%04X", 0x020B, cpu.PBR, cpu.PC)
+	}
+}
+
+func TestDirectPageAndDataBank(t *testing.T) {
+	cpu := new65816(
+		OpLDA_imm, 0x00, 0x10,
+		0x5B, // TCD
+		OpLDA_zeropage, 0x10,
+		0xF4, 0x01, 0x01, // PEA $0101
+		0xAB,                       // PLB
+		OpLDA_absolute, 0x00, 0x40, // LDA $4000 in bank 1
+		OpLDA_absolute_y, 0xFF, 0xFF) // LDA $FFFF,Y crosses to bank 2
+	cpu.Memory.Set(0x1010, 0x11, 0x22)
+	cpu.Memory.Set(0x014000, 0x33, 0x44)
+	cpu.Y = 0x0001
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.D != 0x1000 || cpu.C != 0x2211 {
+		t.Fatalf("Direct page should be moved by TCD. Expected C %x, got\n%v", 0x2211, cpu)
+	}
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.DBR != 0x01 || cpu.C != 0x4433 || cpu.S != 0x01FE {
+		t.Fatalf("Data bank should be set by PLB. Expected C %x, got\n%v", 0x4433, cpu)
+	}
+	cpu.Advance()
+	if cpu.C != 0xFFFF {
+		t.Fatalf("Indexed address in missing bank 2 should read %x, got %x", 0xFFFF, cpu.C)
+	}
+}
+
+func TestBlockMove(t *testing.T) {
+	cpu := new65816(0x54, 0x00, 0x01) // MVN $01,$00
+	cpu.Memory.Set(0x011000, 1, 2, 3)
+	cpu.C, cpu.X, cpu.Y = 2, 0x1000, 0x3000
+	for i := 0; i < 3; i++ {
+		cpu.Advance()
+	}
+	if cpu.Memory.Get(0x3000) != 1 || cpu.Memory.Get(0x3002) != 3 || cpu.PC != 0x0203 || cpu.C != 0xFFFF {
+		t.Fatalf("MVN should copy C+1 bytes and continue. Expected PC %x, got\n%v", 0x0203, cpu)
+	}
+	if cpu.X != 0x1003 || cpu.Y != 0x3003 || cpu.DBR != 0 {
+		t.Fatalf("MVN should advance indexes. Expected %x %x, got %x %x", 0x1003, 0x3003, cpu.X, cpu.Y)
+	}
+}
+
+func TestDecimalMode65816(t *testing.T) {
+	cpu := new65816(OpSED, OpADC_imm, 0x66, 0x87, OpSBC_imm, 0x01, 0x00)
+	cpu.C = 0x1234
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.C != 0x0000 || !cpu.Flags.HasCarry() || !cpu.Flags.HasZero() {
+		t.Fatalf("16 bit decimal addition should carry out. Expected %x, got\n%v", 0, cpu)
+	}
+	cpu.Advance()
+	if cpu.C != 0x9999 || cpu.Flags.HasCarry() || !cpu.Flags.HasNegative() {
+		t.Fatalf("Decimal subtraction should borrow. Expected %x, got\n%v", 0x9999, cpu)
+	}
+	cpu.C = 0x1000
+	cpu.Flags.SetCarry(true)
+	cpu.PC -= 3
+	cpu.Advance()
+	if cpu.C != 0x0999 || !cpu.Flags.HasCarry() {
+		t.Fatalf("Decimal subtraction should borrow from higher digits. Expected %x, got\n%v", 0x0999, cpu)
+	}
+}
+
+func TestNativeInterrupts(t *testing.T) {
+	cpu := new65816(OpINX)
+	cpu.Memory.Set(NativeIRQVector, 0x00, 0x30)
+	cpu.Memory.Set(0x3000, OpRTI)
+	cpu.PBR = 0x01
+	cpu.Memory.Set(0x010200, OpINX)
+	cpu.Flags.SetDecimal(true)
+	cpu.IRQ()
+	if cpu.PBR != 0x01 || cpu.PC != 0x0200 {
+		t.Fatalf("IRQ should wait for the next instruction. Expected PC %x, got\n%v", 0x010200, cpu)
+	}
+	cpu.Advance()
+	if cpu.PBR != 0 || cpu.PC != 0x3000 || cpu.Flags.HasDecimal() || cpu.S != 0x01FB {
+		t.Fatalf("Native IRQ should push bank, PC and flags. Expected PC %x, got\n%v", 0x3000, cpu)
+	}
+	cpu.Advance()
+	if cpu.PBR != 0x01 || cpu.PC != 0x0200 || !cpu.Flags.HasDecimal() {
+		t.Fatalf("RTI should restore bank, PC and flags. Expected PC %x, got\n%v", 0x010200, cpu)
+	}
+
+	cpu.Memory.Set(NativeNMIVector, 0x00, 0x40)
+	cpu.Memory.Set(0x4000, OpSEI, OpINX)
+	cpu.NMI()
+	cpu.Advance()
+	cpu.Advance()
+	cpu.IRQ()
+	cpu.Advance()
+	if cpu.PBR != 0 || cpu.PC != 0x4002 || cpu.X != 1 {
+		t.Fatalf("NMI should be taken and IRQ ignored when interrupts are disabled. Expected PC %x, got\n%v", 0x4002, cpu)
+	}
+}
@@ -0,0 +1,186 @@
+package go6502
+
+// Opcodes added by 65C02
+const (
+	OpBRA = 0x80
+
+	OpPHX = 0xDA
+	OpPHY = 0x5A
+	OpPLX = 0xFA
+	OpPLY = 0x7A
+
+	OpSTZ_zeropage   = 0x64
+	OpSTZ_zeropage_x = 0x74
+	OpSTZ_absolute   = 0x9C
+	OpSTZ_absolute_x = 0x9E
+
+	OpTRB_zeropage = 0x14
+	OpTRB_absolute = 0x1C
+	OpTSB_zeropage = 0x04
+	OpTSB_absolute = 0x0C
+
+	OpINC_accumulator = 0x1A
+	OpDEC_accumulator = 0x3A
+
+	OpBIT_imm        = 0x89
+	OpBIT_zeropage_x = 0x34
+	OpBIT_absolute_x = 0x3C
+
+	OpJMP_indexed_indirect = 0x7C
+
+	OpORA_zeropage_indirect = 0x12
+	OpAND_zeropage_indirect = 0x32
+	OpEOR_zeropage_indirect = 0x52
+	OpADC_zeropage_indirect = 0x72
+	OpSTA_zeropage_indirect = 0x92
+	OpLDA_zeropage_indirect = 0xB2
+	OpCMP_zeropage_indirect = 0xD2
+	OpSBC_zeropage_indirect = 0xF2
+
+	// Bit instructions, bit number is in high nibble, e.g. SMB3 is OpSMB0 + 3<<4
+	OpRMB0 = 0x07
+	OpSMB0 = 0x87
+	OpBBR0 = 0x0F
+	OpBBS0 = 0x8F
+
+	OpWAI = 0xCB
+	OpSTP = 0xDB
+)
+
+// advance65C02 executes instructions added by 65C02, it returns false for ones shared with NMOS 6502
+func (cpu *CPU) advance65C02(instruction uint8) bool {
+	switch instruction {
+	case OpBRA:
+		cpu.branch(true)
+
+	case OpPHX:
+		cpu.push(cpu.X)
+	case OpPHY:
+		cpu.push(cpu.Y)
+	case OpPLX:
+		cpu.dummyRead(cpu.stackPointerAddress())
+		cpu.X = cpu.pop()
+		cpu.updateNZ(cpu.X)
+	case OpPLY:
+		cpu.dummyRead(cpu.stackPointerAddress())
+		cpu.Y = cpu.pop()
+		cpu.updateNZ(cpu.Y)
+
+	case OpSTZ_zeropage:
+		cpu.write(cpu.zeropageAddress(), 0)
+	case OpSTZ_zeropage_x:
+		cpu.write(cpu.zeropageXAddress(), 0)
+	case OpSTZ_absolute:
+		cpu.write(cpu.absoluteAddress(), 0)
+	case OpSTZ_absolute_x:
+		cpu.write(cpu.absoluteXWriteAddress(), 0)
+
+	case OpTRB_zeropage:
+		cpu.trb(cpu.zeropageAddress())
+	case OpTRB_absolute:
+		cpu.trb(cpu.absoluteAddress())
+	case OpTSB_zeropage:
+		cpu.tsb(cpu.zeropageAddress())
+	case OpTSB_absolute:
+		cpu.tsb(cpu.absoluteAddress())
+
+	case OpINC_accumulator:
+		cpu.A = cpu.inc(cpu.A)
+	case OpDEC_accumulator:
+		cpu.A = cpu.dec(cpu.A)
+
+	case OpBIT_imm:
+		// There is no memory operand, so only Z is changed
+		cpu.Flags.SetZero(cpu.A&cpu.read(cpu.immediateAddress()) == 0)
+	case OpBIT_zeropage_x:
+		cpu.bit(cpu.zeropageXAddress())
+	case OpBIT_absolute_x:
+		cpu.bit(cpu.absoluteXAddress())
+
+	case OpJMP_indexed_indirect:
+		// Adding X takes one cycle reading last byte of instruction again, also within page
+		location := cpu.absoluteAddress()
+		cpu.dummyRead(cpu.PC - 1)
+		location += uint16(cpu.X)
+		cpu.PC = uint16(cpu.read(location+1))<<8 + uint16(cpu.read(location))
+
+	case OpORA_zeropage_indirect:
+		cpu.ora(cpu.zeropageIndirectAddress())
+	case OpAND_zeropage_indirect:
+		cpu.and(cpu.zeropageIndirectAddress())
+	case OpEOR_zeropage_indirect:
+		cpu.eor(cpu.zeropageIndirectAddress())
+	case OpADC_zeropage_indirect:
+		cpu.adc(cpu.zeropageIndirectAddress())
+	case OpSTA_zeropage_indirect:
+		cpu.sta(cpu.zeropageIndirectAddress())
+	case OpLDA_zeropage_indirect:
+		cpu.lda(cpu.zeropageIndirectAddress())
+	case OpCMP_zeropage_indirect:
+		cpu.cmp(cpu.zeropageIndirectAddress())
+	case OpSBC_zeropage_indirect:
+		cpu.sbc(cpu.zeropageIndirectAddress())
+
+	case OpWAI:
+		cpu.dummyRead(cpu.PC)
+		cpu.State = Waiting
+	case OpSTP:
+		cpu.dummyRead(cpu.PC)
+		cpu.State = Stopped
+
+	default:
+		bit := uint8(1) << (instruction >> 4 & 0x07)
+		column := instruction & 0x0F
+		switch {
+		case column == OpRMB0 && instruction < OpSMB0:
+			cpu.modify(cpu.zeropageAddress(), func(value uint8) uint8 { return value &^ bit })
+		case column == OpSMB0&0x0F:
+			cpu.modify(cpu.zeropageAddress(), func(value uint8) uint8 { return value | bit })
+		case column == OpBBR0:
+			address := cpu.zeropageAddress()
+			isSet := cpu.read(address)&bit != 0
+			cpu.dummyRead(address)
+			cpu.branch(isSet == (instruction >= OpBBS0))
+		case !CMOS65C02[instruction].Defined():
+			// Undefined opcodes are NOPs of various lengths
+			for i := 1; i < nop65C02Size(instruction); i++ {
+				cpu.getNextInstruction()
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func nop65C02Size(opcode uint8) int {
+	switch {
+	case opcode&0x0F == 0x02, opcode == 0x44, opcode == 0x54, opcode == 0xD4, opcode == 0xF4:
+		return 2
+	case opcode == 0x5C, opcode == 0xDC, opcode == 0xFC:
+		return 3
+	}
+	return 1
+}
+
+// trb clears bits of A in memory, Z is set as by BIT
+func (cpu *CPU) trb(address uint16) {
+	value := cpu.read(address)
+	cpu.dummyRead(address)
+	cpu.Flags.SetZero(cpu.A&value == 0)
+	cpu.write(address, value&^cpu.A)
+}
+
+// tsb sets bits of A in memory, Z is set as by BIT
+func (cpu *CPU) tsb(address uint16) {
+	value := cpu.read(address)
+	cpu.dummyRead(address)
+	cpu.Flags.SetZero(cpu.A&value == 0)
+	cpu.write(address, value|cpu.A)
+}
+
+// zeropageIndirectAddress reads pointer at (zp), which wraps within zero page
+func (cpu *CPU) zeropageIndirectAddress() uint16 {
+	pointer := cpu.getNextInstruction()
+	return uint16(cpu.read(uint16(pointer))) + uint16(cpu.read(uint16(pointer+1)))<<8
+}
@@ -0,0 +1,153 @@
+package go6502
+
+import "testing"
+
+func newCMOSCPU(program ...uint8) *CPU {
+	cpu := NewDefaultMemoryCPU(WithVariant(WDC65C02))
+	cpu.Memory.Set(0x0200, program...)
+	cpu.PC = 0x0200
+	return cpu
+}
+
+func TestCMOSStackAndStore(t *testing.T) {
+	cpu := newCMOSCPU(OpPHX, OpPHY, OpPLX, OpPLY, OpSTZ_absolute, 0x00, 0x03, OpSTZ_zeropage_x, 0x10)
+	cpu.X, cpu.Y = 0x01, 0x80
+	cpu.Memory.Set(0x0300, 0xFF)
+	cpu.Memory.Set(0x0090, 0xFF)
+	for i := 0; i < 6; i++ {
+		cpu.Advance()
+	}
+	if cpu.X != 0x80 || cpu.Y != 0x01 || cpu.S != 0xFF {
+		t.Fatalf("X and Y should be swapped through stack. Expected %x %x, got %x %x", 0x80, 0x01, cpu.X, cpu.Y)
+	}
+	if cpu.Flags.HasNegative() {
+		t.Fatalf("PLY should update flags")
+	}
+	if cpu.Memory.Get(0x0300) != 0 || cpu.Memory.Get(0x0090) != 0 {
+		t.Fatalf("STZ should store zero. Expected %x, got %x %x", 0, cpu.Memory.Get(0x0300), cpu.Memory.Get(0x0090))
+	}
+}
+
+func TestCMOSBitInstructions(t *testing.T) {
+	cpu := newCMOSCPU(
+		OpTRB_zeropage, 0x10, OpTSB_absolute, 0x11, 0x00,
+		OpBIT_imm, 0x00, OpRMB0+7<<4, 0x12, OpSMB0+1<<4, 0x12)
+	cpu.A = 0x0F
+	cpu.Memory.Set(0x0010, 0xFF, 0x30, 0x80)
+	cpu.Flags.SetNegative(true)
+	cpu.Advance()
+	if cpu.Memory.Get(0x0010) != 0xF0 || cpu.Flags.HasZero() {
+		t.Fatalf("TRB should clear bits of A. Expected %x, got %x", 0xF0, cpu.Memory.Get(0x0010))
+	}
+	cpu.Advance()
+	if cpu.Memory.Get(0x0011) != 0x3F || !cpu.Flags.HasZero() {
+		t.Fatalf("TSB should set bits of A. Expected %x, got %x", 0x3F, cpu.Memory.Get(0x0011))
+	}
+	cpu.Advance()
+	if !cpu.Flags.HasZero() || !cpu.Flags.HasNegative() {
+		t.Fatalf("BIT immediate should change only Z, got %v", cpu.Flags)
+	}
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.Memory.Get(0x0012) != 0x02 {
+		t.Fatalf("RMB7 and SMB1 should change single bits. Expected %x, got %x", 0x02, cpu.Memory.Get(0x0012))
+	}
+}
+
+func TestCMOSBranches(t *testing.T) {
+	cpu := newCMOSCPU(OpBRA, 0x02, 0x00, 0x00, OpBBS0+1<<4, 0x10, 0x03, OpBBR0+1<<4, 0x10, 0x10)
+	cpu.Memory.Set(0x0010, 0x01)
+	cpu.Advance()
+	if cpu.PC != 0x0204 {
+		t.Fatalf("BRA should always branch. Expected %x, got %x", 0x0204, cpu.PC)
+	}
+	cpu.Advance()
+	if cpu.PC != 0x0207 {
+		t.Fatalf("BBS1 shouldn't branch when bit is clear. Expected %x, got %x", 0x0207, cpu.PC)
+	}
+	cpu.Advance()
+	if cpu.PC != 0x021A {
+		t.Fatalf("BBR1 should branch when bit is clear. Expected %x, got %x", 0x021A, cpu.PC)
+	}
+}
+
+func TestCMOSAddressing(t *testing.T) {
+	cpu := newCMOSCPU(OpLDA_zeropage_indirect, 0xFF, OpINC_accumulator, OpSTA_zeropage_indirect, 0x20,
+		OpJMP_indexed_indirect, 0x00, 0x04)
+	cpu.Memory.Set(0x00FF, 0x00)
+	cpu.Memory.Set(0x0000, 0x03) // Pointer wraps within zero page
+	cpu.Memory.Set(0x0300, 0x41)
+	cpu.Memory.Set(0x0020, 0x00, 0x05)
+	cpu.Memory.Set(0x0402, 0x34, 0x12)
+	cpu.X = 0x02
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if cpu.Memory.Get(0x0500) != 0x42 {
+		t.Fatalf("Value should be loaded and stored through (zp). Expected %x, got %x", 0x42, cpu.Memory.Get(0x0500))
+	}
+	if cpu.PC != 0x1234 {
+		t.Fatalf("JMP (abs,X) should jump through indexed pointer. Expected %x, got %x", 0x1234, cpu.PC)
+	}
+}
+
+func TestCMOSUndefinedOpcodes(t *testing.T) {
+	cpu := newCMOSCPU(0x02, 0xFF, 0x5C, 0xFF, 0xFF, 0x03, OpINX)
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if cpu.PC != 0x0207 || cpu.X != 1 || cpu.A != 0 {
+		t.Fatalf("Undefined opcodes should be NOPs of right size. Expected PC %x, got %x", 0x0207, cpu.PC)
+	}
+
+	nmos := NewDefaultMemoryCPU()
+	nmos.Memory.Set(0x0200, OpPHX)
+	nmos.PC = 0x0200
+	nmos.Advance()
+	if nmos.S != 0xFF {
+		t.Fatalf("65C02 instructions shouldn't be executed by NMOS 6502. Expected S %x, got %x", 0xFF, nmos.S)
+	}
+}
+
+func TestWAIAndSTP(t *testing.T) {
+	cpu := newCMOSCPU(OpSEI, OpWAI, OpINX, OpSTP, OpINX)
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.State != Waiting || cpu.X != 0 {
+		t.Fatalf("WAI should wait for interrupt. Expected state %v, got %v", Waiting, cpu.State)
+	}
+	cpu.IRQ()
+	cpu.Advance()
+	if cpu.State != Running || cpu.X != 1 {
+		t.Fatalf("IRQ should end WAI and continue after it, when interrupts are disabled. Expected X %x, got %x", 1, cpu.X)
+	}
+	cpu.Advance()
+	cpu.IRQ()
+	cpu.NMI()
+	cpu.Advance()
+	if cpu.State != Stopped || cpu.X != 1 {
+		t.Fatalf("STP should stop CPU until reset. Expected state %v, got %v", Stopped, cpu.State)
+	}
+	cpu.Initialize()
+	if cpu.State != Running {
+		t.Fatalf("Reset should start stopped CPU. Expected state %v, got %v", Running, cpu.State)
+	}
+}
+
+func TestCMOSBRKClearsDecimal(t *testing.T) {
+	for _, c := range []struct {
+		variant Variant
+		decimal bool
+	}{{MOS6502, true}, {WDC65C02, false}} {
+		cpu := NewDefaultMemoryCPU(WithVariant(c.variant))
+		cpu.Memory.Set(0x0200, OpBRK, 0x00)
+		cpu.PC = 0x0200
+		cpu.Flags.SetDecimal(true)
+		cpu.Advance()
+		if cpu.Flags.HasDecimal() != c.decimal {
+			t.Fatalf("Decimal flag after BRK on %v. Expected %v, got %v", c.variant, c.decimal, cpu.Flags.HasDecimal())
+		}
+	}
+}
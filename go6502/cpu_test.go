@@ -11,12 +11,12 @@ func TestPHA(t *testing.T) {
 	cpu.A = 0xF1 // Value 0xF1 should be pushed to stack
 
 	cpu.Advance()
-	if cpu.S != 0xFE {
-		t.Fatalf("Stack pointer should be decreased after PHA. Expected %x, got %x", 0xFE, cpu.S)
+	if cpu.S != 0xFC { // Reset leaves S at 0xFD
+		t.Fatalf("Stack pointer should be decreased after PHA. Expected %x, got %x", 0xFC, cpu.S)
 	}
 
-	if cpu.Memory.Get(0x01FF) != 0xF1 {
-		t.Fatalf("A register value should be pushed to stack. Expected %x, got %x", 0xF1, cpu.Memory.Get(0x01FF))
+	if cpu.Memory.Get(0x01FD) != 0xF1 {
+		t.Fatalf("A register value should be pushed to stack. Expected %x, got %x", 0xF1, cpu.Memory.Get(0x01FD))
 	}
 }
 func TestPHP(t *testing.T) {
@@ -30,12 +30,12 @@ func TestPHP(t *testing.T) {
 	cpu.Flags.SetCarry(true)
 
 	cpu.Advance()
-	if cpu.S != 0xFE {
-		t.Fatalf("Stack pointer should be decreased after PHP. Expected %x, got %x", 0xFE, cpu.S)
+	if cpu.S != 0xFC {
+		t.Fatalf("Stack pointer should be decreased after PHP. Expected %x, got %x", 0xFC, cpu.S)
 	}
 
-	if cpu.Memory.Get(0x01FF) != 0b10000001 { //Nv--dizC
-		t.Fatalf("Flags should be pushed to stack. Expected %x, got %x", 0b10000001, cpu.Memory.Get(0x01FF))
+	if cpu.Memory.Get(0x01FD) != 0b10110101 { //Nv1BdIzC, reset sets interrupt disable
+		t.Fatalf("Flags should be pushed to stack with break bit. Expected %x, got %x", 0b10110101, cpu.Memory.Get(0x01FD))
 	}
 }
 
@@ -62,7 +62,7 @@ func TestPLA(t *testing.T) {
 func TestPLP(t *testing.T) {
 	cpu := NewDefaultMemoryCPU()
 	cpu.Memory.Set(ResetVectorL, 0x00, 0x00)
-	cpu.Memory.Set(0x01FF, 0b10000001) // Nv--dizC
+	cpu.Memory.Set(0x01FF, 0b10010001) // Nv0BdizC, break bit isn't pulled
 
 	cpu.Memory.Set(0x0000, OpPLP)
 	cpu.Initialize()
@@ -74,8 +74,8 @@ func TestPLP(t *testing.T) {
 		t.Fatalf("Stack pointer should be increased after PLP. Expected %x, got %x", 0xFF, cpu.S)
 	}
 
-	if cpu.Flags.String() != "Nv--dizC" {
-		t.Fatalf("Flags should be pulled from stack. Expected %v, got %v", "Nv--dizC", cpu.Flags.String())
+	if cpu.Flags.String() != "Nv1bdizC" {
+		t.Fatalf("Flags should be pulled from stack. Expected %v, got %v", "Nv1bdizC", cpu.Flags.String())
 	}
 }
 
@@ -83,10 +83,10 @@ func TestJMP(t *testing.T) {
 	cpu := NewDefaultMemoryCPU()
 	cpu.Memory.Set(ResetVectorL, 0x00, 0x00)
 
-	cpu.Memory.Set(0x23FF, 0xCA, 0x11) // Store jump address which will be used by Indirect JMP
+	cpu.Memory.Set(0x2380, 0xCA, 0x11) // Store jump address which will be used by Indirect JMP
 
 	cpu.Memory.Set(0x0000, OpJMP_absolute, 0x40, 0x20)
-	cpu.Memory.Set(0x2040, OpJMP_indirect, 0xFF, 0x23)
+	cpu.Memory.Set(0x2040, OpJMP_indirect, 0x80, 0x23)
 	cpu.Initialize()
 	cpu.Advance()
 
@@ -101,6 +101,26 @@ func TestJMP(t *testing.T) {
 	}
 }
 
+func TestJMPIndirectPageBoundary(t *testing.T) {
+	for _, c := range []struct {
+		variant  Variant
+		expected uint16
+	}{
+		{MOS6502, 0x33CA}, // High byte is read from start of the same page
+		{WDC65C02, 0x11CA},
+	} {
+		cpu := NewDefaultMemoryCPU(WithVariant(c.variant))
+		cpu.Memory.Set(0x23FF, 0xCA, 0x11)
+		cpu.Memory.Set(0x2300, 0x33)
+		cpu.Memory.Set(0x0200, OpJMP_indirect, 0xFF, 0x23)
+		cpu.PC = 0x0200
+		cpu.Advance()
+		if cpu.PC != c.expected {
+			t.Fatalf("Indirect JMP through pointer at page end on %v. Expected %x, got %x", c.variant, c.expected, cpu.PC)
+		}
+	}
+}
+
 func TestJSR(t *testing.T) {
 	cpu := NewDefaultMemoryCPU()
 	cpu.Memory.Set(ResetVectorL, 0xC0, 0x01) // Starting at address 0x01C0
@@ -113,16 +133,16 @@ func TestJSR(t *testing.T) {
 		t.Fatalf("PC after JSR wasn't changed. Expected %x, got %x", 0x2040, cpu.PC)
 	}
 
-	if cpu.S != 0xFD {
-		t.Fatalf("S after JSR wasn't changed. Expceted %x, got %x", 0xFD, cpu.S)
+	if cpu.S != 0xFB {
+		t.Fatalf("S after JSR wasn't changed. Expceted %x, got %x", 0xFB, cpu.S)
 	}
 
-	if cpu.Memory.Get(0x01FF) != 0x01 {
-		t.Fatalf("PCH wasn't pushed to stack. Expected %x, got %x", 0x01, cpu.Memory.Get(0x1FF))
+	if cpu.Memory.Get(0x01FD) != 0x01 {
+		t.Fatalf("PCH wasn't pushed to stack. Expected %x, got %x", 0x01, cpu.Memory.Get(0x1FD))
 	}
 
-	if cpu.Memory.Get(0x01FE) != 0xC2 {
-		t.Fatalf("PCH wasn't pushed to stack. Expected %x, got %x", 0xC2, cpu.Memory.Get(0x1FE))
+	if cpu.Memory.Get(0x01FC) != 0xC2 {
+		t.Fatalf("PCH wasn't pushed to stack. Expected %x, got %x", 0xC2, cpu.Memory.Get(0x1FC))
 	}
 }
 
@@ -138,8 +158,68 @@ func TestRTS(t *testing.T) {
 	cpu.S = 0xFD // 2 values on stack
 	cpu.Advance()
 
-	if cpu.PC != 0xC230 {
-		t.Fatalf("PC wasn't loaded from stack. Expected %x, got %x", 0xC230, cpu.PC)
+	if cpu.PC != 0xC231 {
+		t.Fatalf("PC should be loaded from stack and incremented. Expected %x, got %x", 0xC231, cpu.PC)
+	}
+}
+
+func TestJSRAndRTS(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpJSR_absolute, 0x00, 0x03, OpINX)
+	cpu.Memory.Set(0x0300, OpRTS)
+	cpu.PC = 0x0200
+
+	cpu.Advance()
+	if cpu.Memory.Get(0x01FF) != 0x02 || cpu.Memory.Get(0x01FE) != 0x02 {
+		t.Fatalf("JSR should push address of its last byte. Expected %x, got %02x%02x", 0x0202, cpu.Memory.Get(0x01FF), cpu.Memory.Get(0x01FE))
+	}
+	cpu.Advance()
+	if cpu.PC != 0x0203 || cpu.S != 0xFF {
+		t.Fatalf("RTS should return after JSR. Expected %x, got %x", 0x0203, cpu.PC)
+	}
+}
+
+// Jump table, where RTS jumps to address pushed by program, which is target address - 1
+func TestRTSDispatch(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0300, 0xFF, 0x0F, 0xFF, 0x1F) // Table of 0x1000 - 1, 0x2000 - 1
+	cpu.Memory.Set(0x0200,
+		OpLDX_imm, 0x02, // Second entry
+		OpLDA_absolute_x, 0x01, 0x03, OpPHA,
+		OpLDA_absolute_x, 0x00, 0x03, OpPHA,
+		OpRTS)
+	cpu.PC = 0x0200
+	for i := 0; i < 6; i++ {
+		cpu.Advance()
+	}
+	if cpu.PC != 0x2000 || cpu.S != 0xFF {
+		t.Fatalf("RTS should jump to address after pushed one. Expected %x, got %x", 0x2000, cpu.PC)
+	}
+}
+
+// Subroutine reads inline argument after JSR and skips it by adjusting return address
+func TestJSRInlineArgument(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpJSR_absolute, 0x00, 0x03, 0x42, OpINX)
+	cpu.Memory.Set(0x0300,
+		OpTSX,
+		OpLDA_absolute_x, 0x01, 0x01, // Low byte of return address
+		OpSTA_zeropage, 0x10,
+		OpLDA_absolute_x, 0x02, 0x01,
+		OpSTA_zeropage, 0x11,
+		OpLDY_imm, 0x01,
+		OpLDA_indirect_indexed, 0x10, // Argument is right after return address
+		OpINC_absolute_x, 0x01, 0x01, // Skip it, no carry to high byte in this test
+		OpRTS)
+	cpu.PC = 0x0200
+	for i := 0; i < 10; i++ {
+		cpu.Advance()
+	}
+	if cpu.A != 0x42 {
+		t.Fatalf("Argument should be read through return address. Expected %x, got %x", 0x42, cpu.A)
+	}
+	if cpu.PC != 0x0204 {
+		t.Fatalf("RTS should return after argument. Expected %x, got %x", 0x0204, cpu.PC)
 	}
 }
 
@@ -225,3 +305,242 @@ func TestAccumulator(t *testing.T) {
 		t.Fatalf("Carry flag was not set after CMP with equal number")
 	}
 }
+
+func TestADCAndSBC(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0000,
+		OpLDA_imm, 0x50,
+		OpADC_imm, 0x50, // 0x50 + 0x50 = 0xA0, signed overflow
+		OpADC_imm, 0x70, // 0xA0 + 0x70 = 0x110, carry
+		OpSBC_imm, 0x20, // 0x10 - 0x20 with carry set = 0xF0, borrow
+	)
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.A != 0xA0 || !cpu.Flags.HasOverflow() || !cpu.Flags.HasNegative() || cpu.Flags.HasCarry() {
+		t.Fatalf("ADC should set overflow. Expected %x, got %x, flags %v", 0xA0, cpu.A, cpu.Flags)
+	}
+	cpu.Advance()
+	if cpu.A != 0x10 || cpu.Flags.HasOverflow() || !cpu.Flags.HasCarry() {
+		t.Fatalf("ADC should set carry. Expected %x, got %x, flags %v", 0x10, cpu.A, cpu.Flags)
+	}
+	cpu.Advance()
+	if cpu.A != 0xF0 || cpu.Flags.HasCarry() || !cpu.Flags.HasNegative() {
+		t.Fatalf("SBC should clear carry on borrow. Expected %x, got %x, flags %v", 0xF0, cpu.A, cpu.Flags)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0000, OpCMP_imm, 0x42, OpCPX_imm, 0x01, OpCPY_zeropage, 0x10)
+	cpu.Memory.Set(0x0010, 0x80)
+	cpu.A, cpu.X, cpu.Y = 0x42, 0x00, 0x7F
+
+	cpu.Advance()
+	if !cpu.Flags.HasZero() || !cpu.Flags.HasCarry() || cpu.Flags.HasNegative() {
+		t.Fatalf("CMP with equal value should set Z and C, got %v", cpu.Flags)
+	}
+	cpu.Advance()
+	if cpu.Flags.HasZero() || cpu.Flags.HasCarry() || !cpu.Flags.HasNegative() {
+		t.Fatalf("CPX with higher value should set N only, got %v", cpu.Flags)
+	}
+	cpu.Advance()
+	if cpu.Flags.HasZero() || cpu.Flags.HasCarry() || !cpu.Flags.HasNegative() {
+		t.Fatalf("CPY should compare with memory, got %v", cpu.Flags)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpBNE, 0x10, OpBEQ, 0xFC)
+	cpu.PC = 0x0200
+	cpu.Flags.SetZero(true)
+
+	cpu.Advance()
+	if cpu.PC != 0x0202 {
+		t.Fatalf("Branch shouldn't be taken. Expected PC %x, got %x", 0x0202, cpu.PC)
+	}
+	cpu.Advance()
+	if cpu.PC != 0x0200 {
+		t.Fatalf("Branch backwards should be taken. Expected PC %x, got %x", 0x0200, cpu.PC)
+	}
+}
+
+func TestShiftsAndRotations(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0000, OpASL_accumulator, OpROL_zeropage, 0x10, OpROR_absolute, 0x10, 0x00, OpLSR_zeropage_x, 0x0F)
+	cpu.Memory.Set(0x0010, 0x40)
+	cpu.A = 0x81
+	cpu.X = 0x01
+
+	cpu.Advance()
+	if cpu.A != 0x02 || !cpu.Flags.HasCarry() {
+		t.Fatalf("ASL should shift bit 7 to carry. Expected %x, got %x", 0x02, cpu.A)
+	}
+	cpu.Advance()
+	if cpu.Memory.Get(0x10) != 0x81 || cpu.Flags.HasCarry() || !cpu.Flags.HasNegative() {
+		t.Fatalf("ROL should rotate carry into bit 0. Expected %x, got %x", 0x81, cpu.Memory.Get(0x10))
+	}
+	cpu.Advance()
+	if cpu.Memory.Get(0x10) != 0x40 || !cpu.Flags.HasCarry() {
+		t.Fatalf("ROR should rotate bit 0 to carry. Expected %x, got %x", 0x40, cpu.Memory.Get(0x10))
+	}
+	cpu.Advance()
+	if cpu.Memory.Get(0x10) != 0x20 || cpu.Flags.HasCarry() {
+		t.Fatalf("LSR should shift right. Expected %x, got %x", 0x20, cpu.Memory.Get(0x10))
+	}
+}
+
+func TestBIT(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0000, OpBIT_zeropage, 0x10)
+	cpu.Memory.Set(0x0010, 0xC0)
+	cpu.A = 0x3F
+
+	cpu.Advance()
+	if !cpu.Flags.HasZero() || !cpu.Flags.HasNegative() || !cpu.Flags.HasOverflow() || cpu.A != 0x3F {
+		t.Fatalf("BIT should copy bits 7 and 6 and set Z, got %v", cpu.Flags)
+	}
+}
+
+func TestIndirectAddressing(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpLDA_indexed_indirect, 0xFE, OpSTA_indirect_indexed, 0x20)
+	cpu.Memory.Set(0x00FF, 0x00)
+	cpu.Memory.Set(0x0000, 0x34) // Pointer at $FF wraps to $00 for high byte
+	cpu.Memory.Set(0x0020, 0x00, 0x30)
+	cpu.Memory.Set(0x3400, 0x42)
+	cpu.PC = 0x0200
+	cpu.X = 0x01
+	cpu.Y = 0x05
+
+	cpu.Advance()
+	if cpu.A != 0x42 {
+		t.Fatalf("LDA (zp,X) should read through zero page pointer. Expected %x, got %x", 0x42, cpu.A)
+	}
+	cpu.Advance()
+	if cpu.Memory.Get(0x3005) != 0x42 {
+		t.Fatalf("STA (zp),Y should write to pointer plus Y. Expected %x, got %x", 0x42, cpu.Memory.Get(0x3005))
+	}
+}
+
+func TestBRKAndRTI(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Memory.Set(0x0200, OpBRK, 0x00)
+	cpu.Memory.Set(0x3000, OpRTI)
+	cpu.PC = 0x0200
+	cpu.Flags.SetCarry(true)
+
+	cpu.Advance()
+	if cpu.PC != 0x3000 || !cpu.Flags.HasInterruptDisable() || cpu.S != 0xFC {
+		t.Fatalf("BRK should jump through IRQ vector. Expected PC %x, got %x", 0x3000, cpu.PC)
+	}
+	if cpu.Memory.Get(0x01FF) != 0x02 || cpu.Memory.Get(0x01FE) != 0x02 {
+		t.Fatalf("BRK should push address after padding byte. Expected %x, got %x%x", 0x0202, cpu.Memory.Get(0x01FF), cpu.Memory.Get(0x01FE))
+	}
+	if cpu.Memory.Get(0x01FD) != 0b00110001 {
+		t.Fatalf("BRK should push flags with break bit. Expected %x, got %x", 0b00110001, cpu.Memory.Get(0x01FD))
+	}
+	cpu.Advance()
+	if cpu.PC != 0x0202 || cpu.Flags.HasInterruptDisable() || !cpu.Flags.HasCarry() || cpu.S != 0xFF {
+		t.Fatalf("RTI should restore flags and PC. Expected PC %x, got %x", 0x0202, cpu.PC)
+	}
+	if cpu.Flags.Value() != 0b00100001 {
+		t.Fatalf("RTI shouldn't restore break bit. Expected %x, got %x", 0b00100001, cpu.Flags.Value())
+	}
+}
+
+func TestIRQAndNMI(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Memory.Set(NMIVectorL, 0x00, 0x40)
+	cpu.Memory.Set(0x4000, OpNOOP)
+	cpu.PC = 0x1234
+	cpu.Flags.SetCarry(true)
+
+	cpu.IRQ()
+	if cpu.PC != 0x1234 {
+		t.Fatalf("IRQ should wait for the next instruction. Expected PC %x, got %x", 0x1234, cpu.PC)
+	}
+	cpu.Advance()
+	if cpu.PC != 0x3000 || !cpu.Flags.HasInterruptDisable() || cpu.S != 0xFC {
+		t.Fatalf("IRQ should jump through IRQ vector. Expected PC %x, got %x", 0x3000, cpu.PC)
+	}
+	if cpu.Memory.Get(0x01FF) != 0x12 || cpu.Memory.Get(0x01FE) != 0x34 {
+		t.Fatalf("IRQ should push PC. Expected %x, got %x%x", 0x1234, cpu.Memory.Get(0x01FF), cpu.Memory.Get(0x01FE))
+	}
+	if cpu.Memory.Get(0x01FD) != 0b00100001 {
+		t.Fatalf("IRQ should push flags without break bit. Expected %x, got %x", 0b00100001, cpu.Memory.Get(0x01FD))
+	}
+
+	cpu.IRQ()
+	cpu.NMI()
+	cpu.Advance()
+	if cpu.PC != 0x4000 || cpu.S != 0xF9 {
+		t.Fatalf("NMI should jump through NMI vector even when interrupts are disabled. Expected PC %x, got %x", 0x4000, cpu.PC)
+	}
+	if cpu.Memory.Get(0x01FA) != 0b00100101 {
+		t.Fatalf("NMI should push flags without break bit. Expected %x, got %x", 0b00100101, cpu.Memory.Get(0x01FA))
+	}
+	cpu.Advance()
+	if cpu.PC != 0x4001 || cpu.S != 0xF9 {
+		t.Fatalf("IRQ should be ignored when interrupts are disabled. Expected PC %x, got %x", 0x4001, cpu.PC)
+	}
+}
+
+func TestDecimalMode(t *testing.T) {
+	cases := []struct {
+		mode             DecimalMode
+		opcode, a, value uint8
+		carry            bool
+		result           uint8
+		c, z, n, v       bool
+	}{
+		{DecimalNMOS, OpADC_imm, 0x99, 0x01, false, 0x00, true, false, true, false},
+		{DecimalCMOS, OpADC_imm, 0x99, 0x01, false, 0x00, true, true, false, false},
+		{DecimalNMOS, OpADC_imm, 0x58, 0x46, true, 0x05, true, false, true, true},
+		{DecimalCMOS, OpADC_imm, 0x58, 0x46, true, 0x05, true, false, false, true},
+		{DecimalNMOS, OpADC_imm, 0x0F, 0x01, false, 0x16, false, false, false, false},
+		{DecimalNMOS, OpSBC_imm, 0x00, 0x01, true, 0x99, false, false, true, false},
+		{DecimalCMOS, OpSBC_imm, 0x00, 0x01, true, 0x99, false, false, true, false},
+		{DecimalNMOS, OpSBC_imm, 0x46, 0x12, true, 0x34, true, false, false, false},
+		{DecimalNMOS, OpSBC_imm, 0x40, 0x40, true, 0x00, true, true, false, false},
+		{DecimalCMOS, OpSBC_imm, 0x20, 0x0F, false, 0x0A, true, false, false, false},
+	}
+	for _, c := range cases {
+		cpu := NewDefaultMemoryCPU()
+		cpu.DecimalMode = c.mode
+		cpu.Memory.Set(0x0200, c.opcode, c.value)
+		cpu.PC = 0x0200
+		cpu.A = c.a
+		cpu.Flags.SetDecimal(true)
+		cpu.Flags.SetCarry(c.carry)
+		cpu.Advance()
+		if cpu.A != c.result || cpu.Flags.HasCarry() != c.c || cpu.Flags.HasZero() != c.z ||
+			cpu.Flags.HasNegative() != c.n || cpu.Flags.HasOverflow() != c.v {
+			t.Fatalf("%02X %02X with A %02X (mode %d). Expected %x (C %v Z %v N %v V %v), got %x (%v)",
+				c.opcode, c.value, c.a, c.mode, c.result, c.c, c.z, c.n, c.v, cpu.A, cpu.Flags)
+		}
+	}
+}
+
+func TestDecimalModeMatchesReference(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	reference := &referenceCPU{written: map[uint16]bool{}}
+	for _, opcode := range []uint8{OpADC_imm, OpSBC_imm} {
+		for input := 0; input < 0x20000; input++ {
+			a, value, carry := uint8(input), uint8(input>>8), uint8(input>>16)
+			cpu.Memory.Set(0x0200, opcode, value)
+			cpu.PC, cpu.A = 0x0200, a
+			cpu.Flags.SetValue(0x08 | carry)
+			reference.memory[0x0200], reference.memory[0x0201] = opcode, value
+			reference.pc, reference.a, reference.p = 0x0200, a, 0x28|carry
+			cpu.Advance()
+			reference.step()
+			if cpu.A != reference.a || cpu.Flags.Value() != reference.p {
+				t.Fatalf("%02X %02X with A %02X and carry %d. Expected %x (flags %02X), got %x (flags %02X)",
+					opcode, value, a, carry, reference.a, reference.p, cpu.A, cpu.Flags.Value())
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package go6502
+
+// BusCycle is memory access made by CPU in single clock cycle
+type BusCycle struct {
+	Address uint16
+	Value   uint8
+	Write   bool
+}
+
+// bus keeps instruction executed by Tick. Every tick runs the instruction again from registers
+// saved at its start: accesses done by previous ticks are replayed from cycles, the next one
+// goes to Memory and the rest are skipped. The instruction is done when no access is skipped.
+type bus struct {
+	active   bool
+	start    busRegisters
+	cycles   []BusCycle
+	position int
+	// replayed is number of cycles done by previous ticks
+	replayed int
+	last     BusCycle
+	// wait is set when RDY is low, read cycle then doesn't happen
+	wait bool
+}
+
+type busRegisters struct {
+	A, X, Y, S   uint8
+	P            uint8
+	PC           uint16
+	State        RunState
+	Err          error
+	Resetting    bool
+	Interrupting uint16
+}
+
+func (cpu *CPU) saveRegisters() busRegisters {
+	return busRegisters{A: cpu.A, X: cpu.X, Y: cpu.Y, S: cpu.S, P: cpu.Flags.Value(), PC: cpu.PC,
+		State: cpu.State, Err: cpu.Err, Resetting: cpu.resetting, Interrupting: cpu.interrupting}
+}
+
+func (cpu *CPU) restoreRegisters(r busRegisters) {
+	cpu.A, cpu.X, cpu.Y, cpu.S, cpu.PC = r.A, r.X, r.Y, r.S, r.PC
+	cpu.Flags.SetValue(r.P)
+	cpu.State, cpu.Err, cpu.resetting, cpu.interrupting = r.State, r.Err, r.Resetting, r.Interrupting
+}
+
+// Tick runs one clock cycle, so devices can be clocked in lock-step with CPU. Every cycle makes
+// single memory access, including dummy reads and writes done by hardware, e.g. read-modify-write
+// instructions write unmodified value before the result. Tick returns true when the cycle finished
+// instruction, interrupt or reset sequence. Registers keep their values from the start of instruction until then.
+// CPU which isn't running idles, but still counts cycles. While RDY is low, read cycles are repeated.
+func (cpu *CPU) Tick() bool {
+	return cpu.tick(cpu.notReady)
+}
+
+func (cpu *CPU) tick(wait bool) bool {
+	cpu.Cycles++
+	b := &cpu.bus
+	if !b.active {
+		if cpu.State != Running && !cpu.resetting {
+			return true
+		}
+		if cpu.History != nil && !cpu.resetting {
+			cpu.History.begin()
+		}
+		if !cpu.resetting {
+			cpu.interrupting = cpu.nextInterrupt()
+		}
+		if cpu.Tracer != nil && !cpu.resetting && cpu.interrupting == 0 {
+			cpu.Tracer.Trace(cpu)
+		}
+		b.active = true
+		b.start = cpu.saveRegisters()
+		b.cycles = b.cycles[:0]
+	}
+	b.position = 0
+	b.replayed = len(b.cycles)
+	b.wait = wait
+	cpu.execute()
+	if b.position == len(b.cycles) {
+		cpu.endInstruction()
+		return true
+	}
+	cpu.restoreRegisters(b.start)
+	return false
+}
+
+// Busy tells if Tick is in the middle of instruction
+func (cpu *CPU) Busy() bool {
+	return cpu.bus.active
+}
+
+// LastCycle returns the most recent memory access made by Tick
+func (cpu *CPU) LastCycle() BusCycle {
+	return cpu.bus.last
+}
+
+// finishInstruction runs the rest of instruction started by Tick, as Advance can only happen
+// between instructions. It doesn't wait for RDY.
+func (cpu *CPU) finishInstruction() {
+	for cpu.bus.active && !cpu.tick(false) {
+	}
+}
+
+func (cpu *CPU) endInstruction() {
+	cpu.bus.active = false
+	if cpu.History != nil {
+		cpu.History.end()
+	}
+}
+
+func (cpu *CPU) read(address uint16) uint8 {
+	if !cpu.bus.active {
+		return cpu.Memory.Get(address)
+	}
+	return cpu.bus.access(cpu.Memory, address, 0, false)
+}
+
+func (cpu *CPU) write(address uint16, value uint8) {
+	if !cpu.bus.active {
+		cpu.Memory.Set(address, value)
+		return
+	}
+	cpu.bus.access(cpu.Memory, address, value, true)
+}
+
+// dummyRead and dummyWrite are accesses, whose result isn't used by CPU.
+// They are only made by Tick, Advance skips them.
+func (cpu *CPU) dummyRead(address uint16) {
+	if cpu.bus.active {
+		cpu.bus.access(cpu.Memory, address, 0, false)
+	}
+}
+
+func (cpu *CPU) dummyWrite(address uint16, value uint8) {
+	if cpu.bus.active {
+		cpu.bus.access(cpu.Memory, address, value, true)
+	}
+}
+
+func (b *bus) access(memory *Memory, address uint16, value uint8, write bool) uint8 {
+	position := b.position
+	b.position++
+	switch {
+	case position < b.replayed:
+		return b.cycles[position].Value
+	case position > b.replayed, b.wait && !write:
+		return 0
+	}
+	if write {
+		memory.Set(address, value)
+	} else {
+		value = memory.Get(address)
+	}
+	b.last = BusCycle{Address: address, Value: value, Write: write}
+	b.cycles = append(b.cycles, b.last)
+	return value
+}
@@ -0,0 +1,310 @@
+package go6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type SourceLine struct {
+	File string
+	Line int
+}
+
+func (l SourceLine) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// DebugInfo keeps symbols and source line information of loaded program.
+// It can be filled by hand, or parsed from ld65 debug (.dbg) or map (.map) files.
+type DebugInfo struct {
+	symbols map[string]uint16
+	labels  map[uint16]string
+	lines   map[uint16]SourceLine
+}
+
+func (d *DebugInfo) AddSymbol(name string, address uint16) {
+	d.symbols[name] = address
+}
+
+// AddLabel adds symbol which marks code or data location, so it can be also found by address.
+func (d *DebugInfo) AddLabel(name string, address uint16) {
+	d.symbols[name] = address
+	if _, ok := d.labels[address]; !ok {
+		d.labels[address] = name
+	}
+}
+
+func (d *DebugInfo) AddLine(address uint16, file string, line int) {
+	if _, ok := d.lines[address]; !ok {
+		d.lines[address] = SourceLine{File: file, Line: line}
+	}
+}
+
+func (d *DebugInfo) Symbol(name string) (uint16, bool) {
+	address, ok := d.symbols[name]
+	return address, ok
+}
+
+func (d *DebugInfo) Label(address uint16) (string, bool) {
+	name, ok := d.labels[address]
+	return name, ok
+}
+
+func (d *DebugInfo) Line(address uint16) (SourceLine, bool) {
+	line, ok := d.lines[address]
+	return line, ok
+}
+
+// LineAddress returns lowest address generated from source line. File name is compared
+// exactly first, then only by base name, as debuggers usually refer to sources by full path.
+func (d *DebugInfo) LineAddress(file string, line int) (uint16, bool) {
+	var exact, base []uint16
+	for address, source := range d.lines {
+		if source.Line != line {
+			continue
+		}
+		if source.File == file {
+			exact = append(exact, address)
+		} else if filepath.Base(source.File) == filepath.Base(file) {
+			base = append(base, address)
+		}
+	}
+	if len(exact) == 0 {
+		exact = base
+	}
+	if len(exact) == 0 {
+		return 0, false
+	}
+	sort.Slice(exact, func(i, j int) bool { return exact[i] < exact[j] })
+	return exact[0], true
+}
+
+// Symbols returns all symbol names sorted alphabetically.
+func (d *DebugInfo) Symbols() []string {
+	names := make([]string, 0, len(d.symbols))
+	for name := range d.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Merge copies all symbols and lines from other, without overwriting existing entries.
+func (d *DebugInfo) Merge(other *DebugInfo) {
+	for name, address := range other.symbols {
+		if _, ok := d.symbols[name]; !ok {
+			d.symbols[name] = address
+		}
+	}
+	for address, name := range other.labels {
+		if _, ok := d.labels[address]; !ok {
+			d.labels[address] = name
+		}
+	}
+	for address, line := range other.lines {
+		d.AddLine(address, line.File, line.Line)
+	}
+}
+
+func NewDebugInfo() *DebugInfo {
+	return &DebugInfo{
+		symbols: map[string]uint16{},
+		labels:  map[uint16]string{},
+		lines:   map[uint16]SourceLine{},
+	}
+}
+
+/*
+ld65 debug file (--dbgfile) is a text file, where each line is a record type
+followed by comma separated key=value attributes, e.g.:
+	file	id=0,name="hello.s",size=402,mtime=0x5F5E1000,mod=0
+	line	id=3,file=0,line=12,span=4
+	seg	id=0,name="CODE",start=0x000801,size=0x0040,addrsize=absolute,type=ro
+	span	id=4,seg=0,start=2,size=3
+	sym	id=1,name="main",addrsize=absolute,scope=0,def=2,val=0x801,seg=0,type=lab
+*/
+
+type ld65Record map[string]string
+
+func (r ld65Record) number(key string) (int, bool) {
+	value, ok := r[key]
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(number), true
+}
+
+func parseLd65Record(attributes string) ld65Record {
+	record := ld65Record{}
+	for _, attribute := range splitLd65Attributes(attributes) {
+		key, value, found := strings.Cut(attribute, "=")
+		if !found {
+			continue
+		}
+		record[key] = strings.Trim(value, "\"")
+	}
+	return record
+}
+
+type ld65Span struct {
+	segment int
+	start   int // offset from segment start
+}
+
+// splitLd65Attributes splits on commas which are not inside quoted file names
+func splitLd65Attributes(attributes string) []string {
+	var result []string
+	quoted := false
+	start := 0
+	for i, c := range attributes {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			result = append(result, attributes[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, attributes[start:])
+}
+
+// ParseLd65Debug reads debug information file generated by ld65 --dbgfile.
+func ParseLd65Debug(r io.Reader) (*DebugInfo, error) {
+	files := map[int]string{}
+	segments := map[int]int{} // segment id -> start address
+	spans := map[int]ld65Span{}
+	var lines, symbols []ld65Record
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		kind, attributes, found := strings.Cut(text, "\t")
+		if !found {
+			return nil, fmt.Errorf("dbg: line %d: malformed record %q", lineNumber, text)
+		}
+		record := parseLd65Record(attributes)
+		id, _ := record.number("id")
+		switch kind {
+		case "file":
+			files[id] = record["name"]
+		case "seg":
+			start, _ := record.number("start")
+			segments[id] = start
+		case "span":
+			// Segments can be declared after spans, so resolve them later
+			seg, _ := record.number("seg")
+			start, _ := record.number("start")
+			spans[id] = ld65Span{segment: seg, start: start}
+		case "line":
+			lines = append(lines, record)
+		case "sym":
+			symbols = append(symbols, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	spanAddress := func(id int) uint16 {
+		span := spans[id]
+		return uint16(segments[span.segment] + span.start)
+	}
+
+	info := NewDebugInfo()
+	for _, record := range lines {
+		// Type 2 lines come from macro expansion, they would point into macro definition
+		if lineType, _ := record.number("type"); lineType == 2 {
+			continue
+		}
+		file, _ := record.number("file")
+		line, _ := record.number("line")
+		spanList, ok := record["span"]
+		if !ok {
+			continue
+		}
+		for _, span := range strings.Split(spanList, "+") {
+			id, err := strconv.Atoi(span)
+			if err != nil {
+				return nil, fmt.Errorf("dbg: invalid span %q", span)
+			}
+			info.AddLine(spanAddress(id), files[file], line)
+		}
+	}
+	for _, record := range symbols {
+		value, ok := record.number("val")
+		if !ok {
+			continue // imports don't have value
+		}
+		switch record["type"] {
+		case "lab":
+			info.AddLabel(record["name"], uint16(value))
+		case "equ":
+			info.AddSymbol(record["name"], uint16(value))
+		}
+	}
+	return info, nil
+}
+
+/*
+ld65 map file (--mapfile) contains exports in section:
+	Exports list by name:
+	---------------------
+	main                      000801 RLA    __BSS_SIZE__              000000 REA
+Each entry is name, value and flags (L - label, E - equate).
+*/
+
+// ParseLd65Map reads symbols from map file generated by ld65 --mapfile. Map files don't have line info.
+func ParseLd65Map(r io.Reader) (*DebugInfo, error) {
+	info := NewDebugInfo()
+	scanner := bufio.NewScanner(r)
+	inExports := false
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if !inExports {
+			inExports = text == "Exports list by name:"
+			continue
+		}
+		if strings.HasPrefix(text, "---") {
+			continue
+		}
+		if text == "" {
+			break
+		}
+		fields := strings.Fields(text)
+		if len(fields)%3 != 0 {
+			return nil, fmt.Errorf("map: malformed export line %q", text)
+		}
+		for i := 0; i < len(fields); i += 3 {
+			value, err := strconv.ParseUint(fields[i+1], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("map: invalid value of %s: %w", fields[i], err)
+			}
+			if strings.Contains(fields[i+2], "L") {
+				info.AddLabel(fields[i], uint16(value))
+			} else {
+				info.AddSymbol(fields[i], uint16(value))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !inExports {
+		return nil, fmt.Errorf("map: no exports list found")
+	}
+	return info, nil
+}
@@ -0,0 +1,107 @@
+package go6502
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testLd65Debug = `version	major=2,minor=0
+info	csym=0,file=1,lib=0,line=3,mod=1,scope=1,seg=1,span=2,sym=3,type=1
+file	id=0,name="hello, world.s",size=120,mtime=0x5F5E1000,mod=0
+line	id=0,file=0,line=4,span=1
+line	id=1,file=0,line=5,span=0
+line	id=2,file=0,line=9,type=2,span=0
+seg	id=0,name="CODE",start=0x000801,size=0x0005,addrsize=absolute,type=ro,oname="hello.prg",ooffs=2
+span	id=0,seg=0,start=2,size=3
+span	id=1,seg=0,start=0,size=2
+sym	id=0,name="main",addrsize=absolute,scope=0,def=0,val=0x801,seg=0,type=lab
+sym	id=1,name="loop",addrsize=absolute,scope=0,def=1,val=0x803,seg=0,type=lab
+sym	id=2,name="SCREEN",addrsize=absolute,scope=0,def=2,val=0xD000,type=equ
+sym	id=3,name="chrout",addrsize=absolute,scope=0,ref=1,type=imp
+`
+
+func TestParseLd65Debug(t *testing.T) {
+	info, err := ParseLd65Debug(strings.NewReader(testLd65Debug))
+	if err != nil {
+		t.Fatalf("Debug file should be parsed, got error: %v", err)
+	}
+
+	if address, ok := info.Symbol("loop"); !ok || address != 0x0803 {
+		t.Fatalf("Label address is wrong. Expected %x, got %x", 0x0803, address)
+	}
+	if address, ok := info.Symbol("SCREEN"); !ok || address != 0xD000 {
+		t.Fatalf("Equate value is wrong. Expected %x, got %x", 0xD000, address)
+	}
+	if _, ok := info.Label(0xD000); ok {
+		t.Fatalf("Equates shouldn't be used as labels")
+	}
+	if _, ok := info.Symbol("chrout"); ok {
+		t.Fatalf("Imports shouldn't be added as symbols")
+	}
+	if label, _ := info.Label(0x0801); label != "main" {
+		t.Fatalf("Label for address is wrong. Expected %v, got %v", "main", label)
+	}
+
+	line, ok := info.Line(0x0803)
+	if !ok || line.String() != "hello, world.s:5" {
+		t.Fatalf("Line info is wrong. Expected %v, got %v", "hello, world.s:5", line)
+	}
+	if line, _ := info.Line(0x0801); line.Line != 4 {
+		t.Fatalf("Line info is wrong. Expected %v, got %v", 4, line.Line)
+	}
+	if address, ok := info.LineAddress("/home/user/src/hello, world.s", 5); !ok || address != 0x0803 {
+		t.Fatalf("Line address is wrong. Expected %x, got %x", 0x0803, address)
+	}
+	if _, ok := info.LineAddress("hello, world.s", 9); ok {
+		t.Fatalf("Lines without code shouldn't have address")
+	}
+}
+
+const testLd65Map = `Modules list:
+-------------
+hello.o:
+    CODE              Offs=000000  Size=000005  Align=00001  Fill=0000
+
+Exports list by name:
+---------------------
+SCREEN                    00D000 REA    main                      000801 RLA    
+loop                      000803 RLA    
+
+Exports list by value:
+----------------------
+main                      000801 RLA    
+`
+
+func TestParseLd65Map(t *testing.T) {
+	info, err := ParseLd65Map(strings.NewReader(testLd65Map))
+	if err != nil {
+		t.Fatalf("Map file should be parsed, got error: %v", err)
+	}
+	if address, ok := info.Symbol("SCREEN"); !ok || address != 0xD000 {
+		t.Fatalf("Equate value is wrong. Expected %x, got %x", 0xD000, address)
+	}
+	if label, _ := info.Label(0x0803); label != "loop" {
+		t.Fatalf("Label for address is wrong. Expected %v, got %v", "loop", label)
+	}
+	if _, ok := info.Label(0xD000); ok {
+		t.Fatalf("Equates shouldn't be used as labels")
+	}
+}
+
+func TestTracerUsesDebugInfo(t *testing.T) {
+	info, _ := ParseLd65Debug(strings.NewReader(testLd65Debug))
+	output := bytes.Buffer{}
+
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(ResetVectorL, 0x01, 0x08)
+	cpu.Memory.Set(0x0801, OpJMP_absolute, 0x03, 0x08)
+	cpu.Tracer = NewPrintTracer(&output, info)
+	cpu.Initialize()
+	cpu.Advance()
+
+	expected := "0801  4C 03 08  main:      JMP loop          A: 00  X: 00  Y: 00  S: FD  nv1bdIzc  ; hello, world.s:4\n"
+	if output.String() != expected {
+		t.Fatalf("Trace should contain symbols and source line. Expected %q, got %q", expected, output.String())
+	}
+}
@@ -0,0 +1,183 @@
+package go6502
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DisassembledLine struct {
+	Address  uint16
+	Bytes    []uint8
+	Mnemonic string
+	Operand  string
+	Label    string // symbol or generated label, if some branch or jump points here
+	// Target is address used by branch or jump, valid when HasTarget is set
+	Target    uint16
+	HasTarget bool
+}
+
+func (l DisassembledLine) String() string {
+	hex := make([]string, len(l.Bytes))
+	for i, b := range l.Bytes {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	label := ""
+	if l.Label != "" {
+		label = l.Label + ":"
+	}
+	return strings.TrimRight(fmt.Sprintf("%04X  %-8s  %-10s %s %s", l.Address, strings.Join(hex, " "), label, l.Mnemonic, l.Operand), " ")
+}
+
+type Disassembler struct {
+	Instructions *InstructionSet
+	Debug        *DebugInfo
+}
+
+// DisassembleOne decodes single instruction at address. Undefined opcodes are shown as .byte
+func (d *Disassembler) DisassembleOne(memory *Memory, address uint16) DisassembledLine {
+	opcode := memory.Get(address)
+	instruction := d.Instructions[opcode]
+	if !instruction.Defined() {
+		return DisassembledLine{
+			Address:  address,
+			Bytes:    []uint8{opcode},
+			Mnemonic: ".byte",
+			Operand:  fmt.Sprintf("$%02X", opcode),
+		}
+	}
+
+	line := DisassembledLine{
+		Address:  address,
+		Bytes:    make([]uint8, instruction.Size()),
+		Mnemonic: instruction.Mnemonic,
+	}
+	for i := range line.Bytes {
+		line.Bytes[i] = memory.Get(address + uint16(i))
+	}
+	var value uint16
+	if len(line.Bytes) > 1 {
+		value = uint16(line.Bytes[1])
+	}
+	if len(line.Bytes) > 2 {
+		value += uint16(line.Bytes[2]) << 8
+	}
+
+	switch instruction.Mode {
+	case Accumulator:
+		line.Operand = "A"
+	case Immediate:
+		line.Operand = fmt.Sprintf("#$%02X", value)
+	case ZeroPage:
+		line.Operand = d.symbol(value, "$%02X")
+	case ZeroPageX:
+		line.Operand = d.symbol(value, "$%02X") + ",X"
+	case ZeroPageY:
+		line.Operand = d.symbol(value, "$%02X") + ",Y"
+	case Absolute:
+		line.Operand = d.symbol(value, "$%04X")
+	case AbsoluteX:
+		line.Operand = d.symbol(value, "$%04X") + ",X"
+	case AbsoluteY:
+		line.Operand = d.symbol(value, "$%04X") + ",Y"
+	case Indirect:
+		line.Operand = "(" + d.symbol(value, "$%04X") + ")"
+	case IndexedIndirect:
+		line.Operand = "(" + d.symbol(value, "$%02X") + ",X)"
+	case IndirectIndexed:
+		line.Operand = "(" + d.symbol(value, "$%02X") + "),Y"
+	case Relative:
+		line.Target = address + 2 + uint16(int8(value))
+		line.HasTarget = true
+		line.Operand = d.symbol(line.Target, "$%04X")
+	case ZeroPageIndirect:
+		line.Operand = "(" + d.symbol(value, "$%02X") + ")"
+	case AbsoluteIndexedIndirect:
+		line.Operand = "(" + d.symbol(value, "$%04X") + ",X)"
+	case ZeroPageRelative:
+		line.Target = address + 3 + uint16(int8(line.Bytes[2]))
+		line.HasTarget = true
+		line.Operand = d.symbol(uint16(line.Bytes[1]), "$%02X") + "," + d.symbol(line.Target, "$%04X")
+	case AbsoluteLong:
+		line.Operand = fmt.Sprintf("$%02X%04X", line.Bytes[3], value)
+	case AbsoluteLongX:
+		line.Operand = fmt.Sprintf("$%02X%04X,X", line.Bytes[3], value)
+	case IndirectLong:
+		line.Operand = "[" + d.symbol(value, "$%02X") + "]"
+	case IndirectLongIndexed:
+		line.Operand = "[" + d.symbol(value, "$%02X") + "],Y"
+	case AbsoluteIndirectLong:
+		line.Operand = "[" + d.symbol(value, "$%04X") + "]"
+	case StackRelative:
+		line.Operand = fmt.Sprintf("$%02X,S", value)
+	case StackRelativeIndirectIndexed:
+		line.Operand = fmt.Sprintf("($%02X,S),Y", value)
+	case RelativeLong:
+		line.Target = address + 3 + value
+		line.HasTarget = true
+		line.Operand = d.symbol(line.Target, "$%04X")
+	case BlockMove:
+		// Source bank is written first, though it's the second byte
+		line.Operand = fmt.Sprintf("$%02X,$%02X", line.Bytes[2], line.Bytes[1])
+	}
+	if (instruction.Mnemonic == "JMP" || instruction.Mnemonic == "JSR") && instruction.Mode == Absolute {
+		line.Target = value
+		line.HasTarget = true
+	}
+	if label, ok := d.label(address); ok {
+		line.Label = label
+	}
+	return line
+}
+
+// Disassemble decodes instructions from start up to end (inclusive). Addresses which are targets
+// of branches and jumps within the range get labels, generated ones if there is no known symbol.
+func (d *Disassembler) Disassemble(memory *Memory, start uint16, end uint16) []DisassembledLine {
+	var lines []DisassembledLine
+	for address := int(start); address <= int(end); {
+		line := d.DisassembleOne(memory, uint16(address))
+		lines = append(lines, line)
+		address += len(line.Bytes)
+	}
+
+	lineAt := map[uint16]int{}
+	for i, line := range lines {
+		lineAt[line.Address] = i
+	}
+	for i, line := range lines {
+		if !line.HasTarget {
+			continue
+		}
+		target, ok := lineAt[line.Target]
+		if !ok {
+			continue
+		}
+		if lines[target].Label == "" {
+			lines[target].Label = fmt.Sprintf("L%04X", line.Target)
+		}
+		if _, known := d.label(line.Target); !known {
+			lines[i].Operand = strings.Replace(line.Operand, fmt.Sprintf("$%04X", line.Target), lines[target].Label, 1)
+		}
+	}
+	return lines
+}
+
+func (d *Disassembler) label(address uint16) (string, bool) {
+	if d.Debug == nil {
+		return "", false
+	}
+	return d.Debug.Label(address)
+}
+
+func (d *Disassembler) symbol(address uint16, format string) string {
+	if label, ok := d.label(address); ok {
+		return label
+	}
+	return fmt.Sprintf(format, address)
+}
+
+func NewDisassembler(debug *DebugInfo) *Disassembler {
+	return &Disassembler{
+		Instructions: &NMOS6502,
+		Debug:        debug,
+	}
+}
@@ -0,0 +1,128 @@
+package go6502
+
+import "testing"
+
+func TestDisassembleAddressingModes(t *testing.T) {
+	memory := DefaultMemory()
+	memory.Set(0x1000,
+		OpNOOP,
+		0x0A,
+		OpLDA_imm, 0x10,
+		OpLDA_zeropage_x, 0x10,
+		0xB6, 0x10,
+		OpLDA_absolute_y, 0x34, 0x12,
+		0xA1, 0x10,
+		0xB1, 0x10,
+		OpJMP_indirect, 0x34, 0x12,
+		0xFF,
+	)
+	expected := []string{
+		"NOP ",
+		"ASL A",
+		"LDA #$10",
+		"LDA $10,X",
+		"LDX $10,Y",
+		"LDA $1234,Y",
+		"LDA ($10,X)",
+		"LDA ($10),Y",
+		"JMP ($1234)",
+		".byte $FF",
+	}
+	lines := NewDisassembler(nil).Disassemble(memory, 0x1000, 0x1012)
+	if len(lines) != len(expected) {
+		t.Fatalf("Wrong number of lines. Expected %v, got %v", len(expected), len(lines))
+	}
+	for i, line := range lines {
+		if line.Mnemonic+" "+line.Operand != expected[i] {
+			t.Fatalf("Wrong line at %04X. Expected %q, got %q", line.Address, expected[i], line.Mnemonic+" "+line.Operand)
+		}
+	}
+}
+
+func TestDisassembleLabels(t *testing.T) {
+	memory := DefaultMemory()
+	memory.Set(0x0200,
+		OpDEX,
+		0xD0, 0xFD, // BNE $0200
+		OpJSR_absolute, 0x00, 0x03,
+		OpLDA_absolute, 0x00, 0x04,
+	)
+	debug := NewDebugInfo()
+	debug.AddLabel("print", 0x0300)
+	debug.AddLabel("text", 0x0400)
+
+	lines := NewDisassembler(debug).Disassemble(memory, 0x0200, 0x0208)
+	expected := []string{
+		"0200  CA        L0200:     DEX",
+		"0201  D0 FD                BNE L0200",
+		"0203  20 00 03             JSR print",
+		"0206  AD 00 04             LDA text",
+	}
+	for i, line := range lines {
+		if line.String() != expected[i] {
+			t.Fatalf("Wrong line. Expected %q, got %q", expected[i], line.String())
+		}
+	}
+	if !lines[1].HasTarget || lines[1].Target != 0x0200 {
+		t.Fatalf("Branch target should be decoded. Expected %x, got %x", 0x0200, lines[1].Target)
+	}
+}
+
+func TestDisassemble65C02(t *testing.T) {
+	memory := DefaultMemory()
+	memory.Set(0x1000,
+		OpLDA_zeropage_indirect, 0x10,
+		OpJMP_indexed_indirect, 0x34, 0x12,
+		OpBBR0+3<<4, 0x10, 0xFD,
+	)
+	expected := []string{
+		"LDA ($10)",
+		"JMP ($1234,X)",
+		"BBR3 $10,L1005",
+	}
+	disassembler := NewDisassembler(nil)
+	disassembler.Instructions = &CMOS65C02
+	lines := disassembler.Disassemble(memory, 0x1000, 0x1007)
+	if len(lines) != len(expected) {
+		t.Fatalf("Wrong number of lines. Expected %v, got %v", len(expected), len(lines))
+	}
+	for i, line := range lines {
+		if line.Mnemonic+" "+line.Operand != expected[i] {
+			t.Fatalf("Wrong line at %04X. Expected %q, got %q", line.Address, expected[i], line.Mnemonic+" "+line.Operand)
+		}
+	}
+}
+
+func TestDisassemble65816(t *testing.T) {
+	memory := DefaultMemory()
+	memory.Set(0x1000,
+		0xAF, 0x45, 0x23, 0x01, // LDA $012345
+		0x97, 0x10, // STA [$10],Y
+		0xA3, 0x03, // LDA $03,S
+		0x54, 0x7E, 0x7F, // MVN $7F,$7E
+		0x82, 0xF2, 0xFF, // BRL $1000
+		0xFC, 0x00, 0x20, // JSR ($2000,X)
+	)
+	expected := []string{
+		"LDA $012345",
+		"STA [$10],Y",
+		"LDA $03,S",
+		"MVN $7F,$7E",
+		"BRL L1000",
+		"JSR ($2000,X)",
+	}
+	disassembler := NewDisassembler(nil)
+	disassembler.Instructions = &WDC65816
+	lines := disassembler.Disassemble(memory, 0x1000, 0x1010)
+	if len(lines) != len(expected) {
+		t.Fatalf("Wrong number of lines. Expected %v, got %v", len(expected), len(lines))
+	}
+	for i, line := range lines {
+		if line.Mnemonic+" "+line.Operand != expected[i] {
+			t.Fatalf("Wrong line at %04X. Expected %q, got %q", line.Address, expected[i], line.Mnemonic+" "+line.Operand)
+		}
+	}
+	if lines[5].HasTarget {
+		t.Fatalf("Indirect JSR shouldn't have target, got %04X", lines[5].Target)
+	}
+}
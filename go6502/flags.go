@@ -18,6 +18,24 @@ func (f *Flags) SetFlag(index int, value bool) {
 	}
 }
 
+// Bits of processor status register, which aren't stored as flags
+const (
+	// BreakBit is set in status pushed by PHP and BRK and clear when it's pushed by IRQ or NMI
+	BreakBit = 1 << 4
+	// UnusedBit always reads as 1
+	UnusedBit = 1 << 5
+)
+
+// Value returns all flags as processor status register byte, with unused bit set
+func (f *Flags) Value() uint8 {
+	return f.val | UnusedBit
+}
+
+// SetValue sets flags from status register byte, break and unused bits are ignored, as by PLP
+func (f *Flags) SetValue(value uint8) {
+	f.val = value &^ (BreakBit | UnusedBit)
+}
+
 func (f *Flags) HasCarry() bool {
 	return f.HasFlag(0)
 }
@@ -80,7 +98,14 @@ func (f *Flags) String() string {
 		builder.WriteString("v")
 	}
 
-	builder.WriteString("--")
+	builder.WriteString("1")
+
+	// Break bit is only in status values pushed to stack
+	if f.val&BreakBit != 0 {
+		builder.WriteString("B")
+	} else {
+		builder.WriteString("b")
+	}
 
 	if f.HasDecimal() {
 		builder.WriteString("D")
@@ -43,6 +43,25 @@ func TestFlags(t *testing.T) {
 	}
 }
 
+func TestFlagsValue(t *testing.T) {
+	flags := Flags{}
+	flags.SetValue(0xFF)
+	if flags.Value() != 0xEF {
+		t.Fatalf("Break bit shouldn't be stored. Expected %x, got %x", 0xEF, flags.Value())
+	}
+	flags.SetValue(0x00)
+	if flags.Value() != 0x20 {
+		t.Fatalf("Unused bit should read as 1. Expected %x, got %x", 0x20, flags.Value())
+	}
+	if flags.String() != "nv1bdizc" {
+		t.Fatalf("Flags should be rendered with unused and break bits. Expected %v, got %v", "nv1bdizc", flags.String())
+	}
+	pushed := Flags{val: 0xFF}
+	if pushed.String() != "NV1BDIZC" {
+		t.Fatalf("Pushed status should be rendered with break bit. Expected %v, got %v", "NV1BDIZC", pushed.String())
+	}
+}
+
 func flagShouldBeCleared(t *testing.T, flag string) {
 	t.Fatalf("Should have %v clear, but it is set", flag)
 }
@@ -0,0 +1,113 @@
+package go6502
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/*
+Test images are kept in testdata together with their sources:
+	functional_test.bin   - image of code from $0400 assembled from functional_test.s, which checks
+	                        documented instructions in the manner of Klaus Dormann's functional test
+	                        (https://github.com/Klaus2m5/6502_65C02_functional_tests), started there,
+	                        success trap at $0863, number of current test is kept at $0200
+	6502_decimal_test.bin - image of code from $0200 assembled from 6502_decimal_test.s of the same
+	                        suite, started there, it ends with undefined opcode $DB (65C02 STP)
+	                        and leaves 0 at $000B (ERROR) when all results were correct
+Missing image fails the test. Addresses come from listings (.lst), so they have to be updated
+when source changes.
+*/
+
+const (
+	functionalTestStart   = 0x0400
+	functionalTestSuccess = 0x0863
+	functionalTestCase    = 0x0200
+
+	decimalTestStart = 0x0200
+	decimalTestError = 0x000B
+	decimalTestN1    = 0x0000
+	decimalTestN2    = 0x0001
+
+	// Decimal test finishes in about 18 million instructions, functional test in 18 thousand
+	functionalTestLimit = 100000000
+)
+
+func loadTestImage(t *testing.T, name string, origin uint16) *CPU {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Functional tests take a few seconds")
+	}
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Can't open test image: %v", err)
+	}
+	defer file.Close()
+	cpu := NewCPU(NewMemory(NewRAM(0, 0x10000)))
+	if _, err := LoadBinary(cpu.Memory, origin, file); err != nil {
+		t.Fatalf("Can't load test image: %v", err)
+	}
+	return cpu
+}
+
+// runUntilTrap executes instructions until program gets stuck in a loop jumping to itself
+// or reaches undefined opcode, which suite uses to end tests. It returns PC where it stopped.
+func runUntilTrap(cpu *CPU, limit int) (uint16, error) {
+	for i := 0; i < limit; i++ {
+		pc := cpu.PC
+		if !NMOS6502[cpu.Memory.Get(pc)].Defined() {
+			return pc, nil
+		}
+		cpu.Advance()
+		if cpu.PC == pc {
+			return pc, nil
+		}
+	}
+	return cpu.PC, fmt.Errorf("no trap after %d instructions, PC is %04X", limit, cpu.PC)
+}
+
+func TestFunctional(t *testing.T) {
+	cpu := loadTestImage(t, "functional_test.bin", functionalTestStart)
+	cpu.PC = functionalTestStart
+	trap, err := runUntilTrap(cpu, functionalTestLimit)
+	if err != nil {
+		t.Fatalf("Functional test didn't finish: %v", err)
+	}
+	if trap != functionalTestSuccess {
+		t.Fatalf("Functional test %02X failed, trapped at PC %04X\n%v",
+			cpu.Memory.Get(functionalTestCase), trap, cpu)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	cpu := loadTestImage(t, "6502_decimal_test.bin", decimalTestStart)
+	cpu.PC = decimalTestStart
+	trap, err := runUntilTrap(cpu, functionalTestLimit)
+	if err != nil {
+		t.Fatalf("Decimal test didn't finish: %v", err)
+	}
+	if cpu.Memory.Get(decimalTestError) != 0 {
+		t.Fatalf("Decimal test failed for operands %02X and %02X (carry %d), ended at PC %04X",
+			cpu.Memory.Get(decimalTestN1), cpu.Memory.Get(decimalTestN2), cpu.Y, trap)
+	}
+}
+
+func TestRunUntilTrap(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpLDX_imm, 0x03, OpDEX, OpBNE, 0xFD, OpBEQ, 0xFE)
+	cpu.PC = 0x0200
+	if trap, err := runUntilTrap(cpu, 100); err != nil || trap != 0x0205 {
+		t.Fatalf("Branch to itself should be detected. Expected %x, got %x (%v)", 0x0205, trap, err)
+	}
+	cpu.Memory.Set(0x0300, OpINX, 0xDB)
+	cpu.PC = 0x0300
+	if trap, err := runUntilTrap(cpu, 100); err != nil || trap != 0x0301 {
+		t.Fatalf("Undefined opcode should end test. Expected %x, got %x (%v)", 0x0301, trap, err)
+	}
+	cpu.Memory.Set(0x0400, OpINX, OpJMP_absolute, 0x00, 0x04)
+	cpu.PC = 0x0400
+	if _, err := runUntilTrap(cpu, 100); err == nil {
+		t.Fatalf("Endless loop without trap should be reported")
+	}
+}
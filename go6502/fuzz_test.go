@@ -0,0 +1,135 @@
+package go6502
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/*
+FuzzCPU runs random programs on CPU and referenceCPU and compares their state after every instruction.
+Fuzz input is initial A, X, Y, S and P, followed by program, which is loaded at fuzzProgramStart.
+Program bytes are also repeated through zero page and stack, so indirect pointers and pulled values
+are random too. Run it with:
+	go test ./go6502 -run FuzzCPU -fuzz FuzzCPU
+*/
+
+const (
+	fuzzProgramStart = 0x0200
+	fuzzMaxProgram   = 0x0400
+	fuzzSteps        = 64
+)
+
+// fuzzWrites records addresses written by CPU
+type fuzzWrites map[uint16]bool
+
+func (w fuzzWrites) MemoryRead(address uint16, value uint8) {}
+
+func (w fuzzWrites) MemoryWritten(address uint16, previous uint8, value uint8) {
+	w[address] = true
+}
+
+func newFuzzMachines(data []byte) (*CPU, *referenceCPU) {
+	reference := &referenceCPU{a: data[0], x: data[1], y: data[2], s: data[3], p: data[4]&^referenceBreak | referenceUnused, pc: fuzzProgramStart}
+	reference.written = map[uint16]bool{}
+	program := data[5:]
+	if len(program) > fuzzMaxProgram {
+		program = program[:fuzzMaxProgram]
+	}
+	if len(program) > 0 {
+		for address := 0; address < fuzzProgramStart; address++ {
+			reference.memory[address] = program[address%len(program)]
+		}
+	}
+	copy(reference.memory[fuzzProgramStart:], program)
+	reference.memory[IRQVectorL] = fuzzProgramStart & 0xFF
+	reference.memory[IRQVectorH] = uint8(fuzzProgramStart >> 8)
+
+	cpu := NewCPU(NewMemory(NewRAM(0, 0x10000)))
+	cpu.A, cpu.X, cpu.Y, cpu.S, cpu.PC = reference.a, reference.x, reference.y, reference.s, reference.pc
+	cpu.Flags.SetValue(reference.p)
+	for address, value := range reference.memory {
+		if value != 0 {
+			cpu.Memory.Set(uint16(address), value)
+		}
+	}
+	return cpu, reference
+}
+
+// expected returns state of reference in form used by single step tests, with written memory
+func (r *referenceCPU) expected(written fuzzWrites) singleStepState {
+	state := singleStepState{PC: r.pc, S: r.s, A: r.a, X: r.x, Y: r.y, P: r.p}
+	for address := range r.written {
+		written[address] = true
+	}
+	for address := range written {
+		state.RAM = append(state.RAM, [2]int32{int32(address), int32(r.memory[address])})
+	}
+	sort.Slice(state.RAM, func(i, j int) bool { return state.RAM[i][0] < state.RAM[j][0] })
+	return state
+}
+
+func FuzzCPU(f *testing.F) {
+	f.Add([]byte{0x00, 0x00, 0x00, 0xFF, 0x00, OpLDA_imm, 0x7F, OpADC_imm, 0x01, OpSBC_imm, 0x80, OpINX, OpDEY})
+	f.Add([]byte{0x50, 0x01, 0x02, 0xF0, 0x01, OpADC_zeropage, 0x06, OpROR_accumulator, OpBVS, 0x02, OpCMP_imm, 0x40})
+	f.Add([]byte{0x80, 0x10, 0x20, 0x80, 0xC3, OpPHA, OpPLP, OpBIT_zeropage, 0x05, OpSTA_indexed_indirect, 0x00, OpLDA_indirect_indexed, 0x04})
+	f.Add([]byte{0x99, 0x00, 0x00, 0xFF, 0x08, OpADC_imm, 0x01, OpSBC_imm, 0x02, OpCLD, OpADC_imm, 0x01})
+	f.Add([]byte{0x00, 0x05, 0x00, 0xFF, 0x00, OpJSR_absolute, 0x08, 0x02, OpDEX, OpBNE, 0xFD, OpBEQ, 0xFE, OpASL_zeropage_x, 0x10})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 5 {
+			return
+		}
+		cpu, reference := newFuzzMachines(data)
+		written := fuzzWrites{}
+		cpu.Memory.AddObserver(written)
+		var trace []string
+		for step := 0; step < fuzzSteps; step++ {
+			opcode := reference.read(reference.pc)
+			if !NMOS6502[opcode].Defined() {
+				return
+			}
+			trace = append(trace, fmt.Sprintf("    %04X %s", cpu.PC, NMOS6502[opcode].Mnemonic))
+			cpu.Advance()
+			if !reference.step() {
+				t.Fatalf("Reference doesn't implement opcode %02X", opcode)
+			}
+			expected := reference.expected(written)
+			if diff := expected.diff(cpu); diff != "" {
+				t.Fatalf("CPU differs from reference after %s:\n%s\nexecuted:\n%s",
+					NMOS6502[opcode].Mnemonic, diff, strings.Join(trace, "\n"))
+			}
+		}
+	})
+}
+
+func TestReferenceInstructionSet(t *testing.T) {
+	for opcode := 0; opcode < 0x100; opcode++ {
+		defined := referenceInstructions[opcode].mnemonic != ""
+		if defined != NMOS6502[opcode].Defined() {
+			t.Fatalf("Reference and NMOS6502 should define the same opcodes. Expected %v for %02X, got %v",
+				NMOS6502[opcode].Defined(), opcode, defined)
+		}
+	}
+}
+
+// NMOS 6502 sets N and V from intermediate result in decimal mode, so 58 + 46 + 1 sets both
+func TestReferenceDecimal(t *testing.T) {
+	cases := []struct {
+		opcode, a, value, p, result, flags uint8
+	}{
+		{OpADC_imm, 0x99, 0x01, referenceDecimal, 0x00, referenceDecimal | referenceCarry | referenceNegative},
+		{OpADC_imm, 0x58, 0x46, referenceDecimal | referenceCarry, 0x05, referenceDecimal | referenceCarry | referenceNegative | referenceOverflow},
+		{OpSBC_imm, 0x00, 0x01, referenceDecimal | referenceCarry, 0x99, referenceDecimal | referenceNegative},
+		{OpSBC_imm, 0x46, 0x12, referenceDecimal | referenceCarry, 0x34, referenceDecimal | referenceCarry},
+	}
+	for _, c := range cases {
+		reference := &referenceCPU{a: c.a, p: c.p, written: map[uint16]bool{}}
+		reference.memory[0], reference.memory[1] = c.opcode, c.value
+		reference.step()
+		if reference.a != c.result || reference.p != c.flags {
+			t.Fatalf("%02X %02X with A %02X. Expected %02X (flags %02X), got %02X (flags %02X)",
+				c.opcode, c.value, c.a, c.result, c.flags, reference.a, reference.p)
+		}
+	}
+}
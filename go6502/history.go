@@ -0,0 +1,127 @@
+package go6502
+
+// MemoryWrite is single memory change made by instruction
+type MemoryWrite struct {
+	Address  uint16
+	Previous uint8
+	Value    uint8
+}
+
+// HistoryEntry keeps CPU registers and run state before instruction was executed and memory writes it made
+type HistoryEntry struct {
+	A      uint8
+	X      uint8
+	Y      uint8
+	S      uint8
+	P      uint8
+	PC     uint16
+	State  RunState
+	Err    error
+	Writes []MemoryWrite
+	// Interrupts requested before instruction, entry of interrupt clears them
+	irqPending bool
+	nmiPending bool
+}
+
+// History records executed instructions in ring buffer of given size, so the oldest
+// entries are dropped when it's full. Recorded instructions can be undone with StepBack.
+type History struct {
+	cpu     *CPU
+	entries []HistoryEntry
+	start   int // index of the oldest entry
+	count   int
+	current *HistoryEntry // entry of instruction being executed, it collects memory writes
+}
+
+func (h *History) MemoryRead(address uint16, value uint8) {
+}
+
+func (h *History) MemoryWritten(address uint16, previous uint8, value uint8) {
+	if h.current != nil {
+		h.current.Writes = append(h.current.Writes, MemoryWrite{Address: address, Previous: previous, Value: value})
+	}
+}
+
+// begin is called by CPU before executing instruction
+func (h *History) begin() {
+	if h.count == len(h.entries) {
+		h.start = (h.start + 1) % len(h.entries)
+		h.count--
+	}
+	cpu := h.cpu
+	entry := &h.entries[(h.start+h.count)%len(h.entries)]
+	// Writes slice of dropped entry is reused
+	*entry = HistoryEntry{A: cpu.A, X: cpu.X, Y: cpu.Y, S: cpu.S, P: cpu.Flags.Value(), PC: cpu.PC,
+		State: cpu.State, Err: cpu.Err, Writes: entry.Writes[:0], irqPending: cpu.irqPending, nmiPending: cpu.nmiPending}
+	h.count++
+	h.current = entry
+}
+
+// end is called by CPU after executing instruction
+func (h *History) end() {
+	h.current = nil
+}
+
+// Len returns number of recorded instructions
+func (h *History) Len() int {
+	return h.count
+}
+
+// Entry returns recorded instruction, 0 is the most recent one
+func (h *History) Entry(index int) HistoryEntry {
+	return h.entries[(h.start+h.count-1-index)%len(h.entries)]
+}
+
+func (h *History) Clear() {
+	h.start = 0
+	h.count = 0
+}
+
+// StepBack undoes the most recent instruction, restoring memory, registers and run state,
+// so instruction which halted CPU can be stepped back over. Undone entry of interrupt
+// leaves the interrupt pending again.
+// It returns false when there is no history left.
+func (h *History) StepBack() bool {
+	if h.count == 0 {
+		return false
+	}
+	entry := h.Entry(0)
+	h.count--
+	for i := len(entry.Writes) - 1; i >= 0; i-- {
+		h.cpu.Memory.Set(entry.Writes[i].Address, entry.Writes[i].Previous)
+	}
+	cpu := h.cpu
+	cpu.A = entry.A
+	cpu.X = entry.X
+	cpu.Y = entry.Y
+	cpu.S = entry.S
+	cpu.Flags.SetValue(entry.P)
+	cpu.PC = entry.PC
+	cpu.State = entry.State
+	cpu.Err = entry.Err
+	cpu.irqPending = entry.irqPending
+	cpu.nmiPending = entry.nmiPending
+	return true
+}
+
+// RunBack steps back at least once, until stop returns true for current state.
+// It returns false when history ran out before that.
+func (h *History) RunBack(stop func(cpu *CPU) bool) bool {
+	for h.StepBack() {
+		if stop(h.cpu) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewHistory starts recording instructions executed by CPU, keeping at most size of them
+func NewHistory(cpu *CPU, size int) *History {
+	h := &History{
+		cpu:     cpu,
+		entries: make([]HistoryEntry, size),
+	}
+	cpu.History = h
+	cpu.Memory.AddObserver(h)
+	return h
+}
@@ -0,0 +1,142 @@
+package go6502
+
+import "testing"
+
+func TestHistoryStepBack(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200,
+		OpLDA_imm, 0x42,
+		OpSTA_zeropage, 0x10,
+		OpPHA,
+		OpINX,
+	)
+	cpu.Memory.Set(0x10, 0x99)
+	cpu.PC = 0x0200
+	cpu.S = 0xFF
+	history := NewHistory(cpu, 10)
+
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if history.Len() != 4 || history.Entry(0).PC != 0x0205 || history.Entry(3).PC != 0x0200 {
+		t.Fatalf("All instructions should be recorded, newest first. Got %d entries", history.Len())
+	}
+	if writes := history.Entry(2).Writes; len(writes) != 1 || writes[0] != (MemoryWrite{Address: 0x10, Previous: 0x99, Value: 0x42}) {
+		t.Fatalf("Memory write of STA should be recorded, got %v", writes)
+	}
+
+	history.StepBack()
+	history.StepBack()
+	if cpu.PC != 0x0204 || cpu.S != 0xFF || cpu.X != 0 {
+		t.Fatalf("Registers should be restored. PC: %x, S: %x, X: %x", cpu.PC, cpu.S, cpu.X)
+	}
+	if cpu.Memory.Get(0x01FF) != 0x00 {
+		t.Fatalf("Pushed value should be removed from stack. Expected %x, got %x", 0x00, cpu.Memory.Get(0x01FF))
+	}
+
+	if !history.RunBack(func(cpu *CPU) bool { return cpu.PC == 0x0200 }) {
+		t.Fatalf("Run back should stop at first instruction")
+	}
+	if cpu.A != 0x00 || cpu.Memory.Get(0x10) != 0x99 {
+		t.Fatalf("Memory and registers should be restored. A: %x, $10: %x", cpu.A, cpu.Memory.Get(0x10))
+	}
+	if history.StepBack() {
+		t.Fatalf("There should be no history left")
+	}
+}
+
+func TestHistoryRestoresRunState(t *testing.T) {
+	cpu := NewDefaultMemoryCPU(WithUndocumented())
+	cpu.Memory.Set(0x0200, OpINX, 0x02) // JAM
+	cpu.PC = 0x0200
+	history := NewHistory(cpu, 10)
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.State != Jammed {
+		t.Fatalf("JAM should halt CPU. Expected %v, got %v", Jammed, cpu.State)
+	}
+	history.StepBack()
+	if cpu.State != Running || cpu.PC != 0x0201 {
+		t.Fatalf("Step back should restore run state. Expected %v at %x, got %v at %x", Running, 0x0201, cpu.State, cpu.PC)
+	}
+
+	cpu = NewDefaultMemoryCPU(WithStrict())
+	cpu.Memory.Set(0x0200, 0x02)
+	cpu.PC = 0x0200
+	history = NewHistory(cpu, 10)
+	cpu.Advance()
+	if cpu.State != Faulted || cpu.Err == nil {
+		t.Fatalf("Undefined opcode should fault strict CPU, got %v", cpu.State)
+	}
+	history.StepBack()
+	if cpu.State != Running || cpu.Err != nil {
+		t.Fatalf("Step back should clear fault. Expected %v, got %v (%v)", Running, cpu.State, cpu.Err)
+	}
+}
+
+func TestHistoryRestoresPendingInterrupt(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Memory.Set(0x0200, OpINX)
+	cpu.PC = 0x0200
+	history := NewHistory(cpu, 10)
+	cpu.IRQ()
+	cpu.Advance()
+	history.StepBack()
+	if cpu.PC != 0x0200 || cpu.S != 0xFF {
+		t.Fatalf("Step back should undo entry of interrupt. Expected PC %x, got %x", 0x0200, cpu.PC)
+	}
+	cpu.Advance()
+	if cpu.PC != 0x3000 {
+		t.Fatalf("Interrupt should be pending after step back. Expected PC %x, got %x", 0x3000, cpu.PC)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpINX, OpINX, OpINX, OpINX)
+	cpu.PC = 0x0200
+	history := NewHistory(cpu, 3)
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if history.Len() != 3 || history.Entry(2).PC != 0x0201 {
+		t.Fatalf("Only the newest instructions should be kept. Got %d entries", history.Len())
+	}
+	if history.RunBack(func(cpu *CPU) bool { return cpu.PC == 0x0200 }) {
+		t.Fatalf("Run back should end when history runs out")
+	}
+	if cpu.PC != 0x0201 || cpu.X != 1 {
+		t.Fatalf("State should be at the oldest recorded instruction. PC: %x, X: %x", cpu.PC, cpu.X)
+	}
+}
+
+// ioRegister is device, which counts its reads
+type ioRegister struct {
+	address uint16
+	value   uint8
+	reads   int
+}
+
+func (r *ioRegister) WithinRange(address uint16) bool { return address == r.address }
+func (r *ioRegister) Get(address uint16) uint8 {
+	r.reads++
+	return r.value
+}
+func (r *ioRegister) Set(address uint16, value uint8) { r.value = value }
+
+func TestHistoryDoesNotReadDevices(t *testing.T) {
+	register := &ioRegister{address: 0xD000}
+	cpu := NewCPU(NewMemory(register, NewRAM(0, 0x10000)))
+	cpu.Memory.Set(0x0200, OpLDA_imm, 0x42, OpSTA_absolute, 0x00, 0xD0)
+	cpu.PC = 0x0200
+	history := NewHistory(cpu, 10)
+	cpu.Advance()
+	cpu.Advance()
+	if register.reads != 0 {
+		t.Fatalf("Write to device shouldn't read it. Expected %d reads, got %d", 0, register.reads)
+	}
+	if writes := history.Entry(0).Writes; len(writes) != 1 || writes[0].Previous != 0x42 {
+		t.Fatalf("Write to device should be recorded with written value as previous, got %v", writes)
+	}
+}
@@ -0,0 +1,45 @@
+package go6502
+
+import (
+	"fmt"
+	"io"
+)
+
+// LoadPRG loads a Commodore PRG image into memory. The first two bytes of the
+// file hold the load address (low byte first), the rest is copied from there.
+// It returns the load address.
+func LoadPRG(memory *Memory, r io.Reader) (uint16, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < 2 {
+		return 0, fmt.Errorf("prg: file too short (%d bytes), missing load address", len(data))
+	}
+	loadAddress := uint16(data[0]) + uint16(data[1])<<8
+	if err := loadAt(memory, loadAddress, data[2:]); err != nil {
+		return 0, fmt.Errorf("prg: %w", err)
+	}
+	return loadAddress, nil
+}
+
+// LoadBinary copies a flat binary (like ld65 output without header) into memory
+// starting at origin. It returns number of bytes loaded.
+func LoadBinary(memory *Memory, origin uint16, r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := loadAt(memory, origin, data); err != nil {
+		return 0, fmt.Errorf("bin: %w", err)
+	}
+	return len(data), nil
+}
+
+func loadAt(memory *Memory, address uint16, data []uint8) error {
+	if int(address)+len(data) > 0x10000 {
+		return fmt.Errorf("%d bytes at %04X don't fit in address space", len(data), address)
+	}
+	memory.Set(address, data...)
+	return nil
+}
@@ -0,0 +1,47 @@
+package go6502
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoadPRG(t *testing.T) {
+	memory := DefaultMemory()
+	prg := []uint8{0x01, 0x08, OpLDA_imm, 0x42, OpRTS}
+
+	address, err := LoadPRG(memory, bytes.NewReader(prg))
+	if err != nil {
+		t.Fatalf("PRG should be loaded, got error: %v", err)
+	}
+	if address != 0x0801 {
+		t.Fatalf("Load address should be taken from header. Expected %x, got %x", 0x0801, address)
+	}
+	for i, expected := range prg[2:] {
+		if memory.Get(0x0801+uint16(i)) != expected {
+			t.Fatalf("Wrong value at %x. Expected %x, got %x", 0x0801+i, expected, memory.Get(0x0801+uint16(i)))
+		}
+	}
+}
+
+func TestLoadPRGErrors(t *testing.T) {
+	if _, err := LoadPRG(DefaultMemory(), bytes.NewReader([]uint8{0x01})); err == nil {
+		t.Fatalf("PRG without full load address should fail")
+	}
+	if _, err := LoadPRG(DefaultMemory(), bytes.NewReader([]uint8{0xFF, 0xFF, 0x01, 0x02})); err == nil {
+		t.Fatalf("PRG which doesn't fit in address space should fail")
+	}
+}
+
+func TestLoadBinary(t *testing.T) {
+	memory := DefaultMemory()
+	loaded, err := LoadBinary(memory, 0xC000, bytes.NewReader([]uint8{0xA9, 0x01}))
+	if err != nil {
+		t.Fatalf("Binary should be loaded, got error: %v", err)
+	}
+	if loaded != 2 {
+		t.Fatalf("Wrong number of bytes loaded. Expected %v, got %v", 2, loaded)
+	}
+	if memory.Get(0xC000) != 0xA9 || memory.Get(0xC001) != 0x01 {
+		t.Fatalf("Binary wasn't copied to origin. Got %x %x", memory.Get(0xC000), memory.Get(0xC001))
+	}
+}
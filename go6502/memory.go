@@ -1,5 +1,10 @@
 package go6502
 
+import (
+	"fmt"
+	"math/rand"
+)
+
 type MemoryMapEntry interface {
 	WithinRange(address uint16) bool
 	Get(address uint16) uint8
@@ -9,11 +14,11 @@ type MemoryMapEntry interface {
 type RAM struct {
 	data         []uint8
 	addressStart uint16
-	size         uint16
+	size         uint32
 }
 
 func (R *RAM) WithinRange(address uint16) bool {
-	return address >= R.addressStart && uint32(address) < uint32(R.addressStart)+uint32(R.size)
+	return address >= R.addressStart && uint32(address) < uint32(R.addressStart)+R.size
 }
 
 func (R *RAM) Get(address uint16) uint8 {
@@ -27,19 +32,79 @@ func (R *RAM) Set(address uint16, value uint8) {
 	R.data[address-R.addressStart] = value
 }
 
-func NewRAM(addressStart uint16, size uint16) *RAM {
+func (R *RAM) MarshalBinary() ([]byte, error) {
+	return append([]uint8(nil), R.data...), nil
+}
+
+func (R *RAM) UnmarshalBinary(data []byte) error {
+	if len(data) != len(R.data) {
+		return fmt.Errorf("ram: state has %d bytes, expected %d", len(data), len(R.data))
+	}
+	copy(R.data, data)
+	return nil
+}
+
+// NewRAM creates RAM of size bytes, up to 64 KB, mapped from addressStart
+func NewRAM(addressStart uint16, size int) *RAM {
 	return &RAM{
 		data:         make([]uint8, size),
 		addressStart: addressStart,
-		size:         size,
+		size:         uint32(size),
 	}
 }
 
+// Fill sets every byte of RAM to value given by fill, to emulate state of RAM after power-on
+func (R *RAM) Fill(fill RAMFill) {
+	for i := range R.data {
+		R.data[i] = fill(i)
+	}
+}
+
+// RAMFill gives value of RAM byte at offset from start of RAM
+type RAMFill func(offset int) uint8
+
+// FillZeros clears RAM, which is also content of RAM created by NewRAM
+func FillZeros(offset int) uint8 {
+	return 0
+}
+
+// FillRandom fills RAM with pseudo random values, the same seed gives the same content
+func FillRandom(seed int64) RAMFill {
+	random := rand.New(rand.NewSource(seed))
+	return func(offset int) uint8 {
+		return uint8(random.Intn(0x100))
+	}
+}
+
+// FillPattern repeats pattern over RAM, e.g. FillPattern(0x00, 0xFF) alternates bytes
+func FillPattern(pattern ...uint8) RAMFill {
+	return func(offset int) uint8 {
+		return pattern[offset%len(pattern)]
+	}
+}
+
+// MemoryObserver is notified about every memory access, e.g. by debuggers watching addresses.
+// Previous value of written address is known only for RAM and Screen, as reading other entries
+// may have side effects. For them previous is the written value.
+type MemoryObserver interface {
+	MemoryRead(address uint16, value uint8)
+	MemoryWritten(address uint16, previous uint8, value uint8)
+}
+
 type Memory struct {
-	entries []MemoryMapEntry
+	entries   []MemoryMapEntry
+	observers []MemoryObserver
 }
 
 func (m *Memory) Get(address uint16) uint8 {
+	value := m.get(address)
+	for _, observer := range m.observers {
+		observer.MemoryRead(address, value)
+	}
+	return value
+}
+
+func (m *Memory) get(address uint16) uint8 {
 	for _, entry := range m.entries {
 		// TODO: Refactor memory, so only it is aware about memory mapping
 		// Mapped memory like RAM or Screen should get only "internal" address
@@ -51,22 +116,51 @@ func (m *Memory) Get(address uint16) uint8 {
 	return 0xFF
 }
 
+// peeker is implemented by entries, which can be read without side effects
+type peeker interface {
+	peek(address uint16) uint8
+}
+
+func (R *RAM) peek(address uint16) uint8 {
+	return R.Get(address)
+}
+
 func (m *Memory) Set(address uint16, value ...uint8) {
 	for i := 0; i < len(value); i++ {
 		valueAddress := address + uint16(i)
+		previous := value[i]
 		for _, entry := range m.entries {
 			if entry.WithinRange(valueAddress) {
+				if p, ok := entry.(peeker); ok && len(m.observers) > 0 {
+					previous = p.peek(valueAddress)
+				}
 				entry.Set(valueAddress, value[i])
 				break
 			}
 		}
+		for _, observer := range m.observers {
+			observer.MemoryWritten(valueAddress, previous, value[i])
+		}
+	}
+}
+
+func (m *Memory) AddObserver(observer MemoryObserver) {
+	m.observers = append(m.observers, observer)
+}
+
+func (m *Memory) RemoveObserver(observer MemoryObserver) {
+	for i, o := range m.observers {
+		if o == observer {
+			m.observers = append(m.observers[:i:i], m.observers[i+1:]...)
+			return
+		}
 	}
 }
 
 func DefaultMemory() *Memory {
 	return &Memory{
 		entries: []MemoryMapEntry{
-			NewRAM(0, 65535),
+			NewRAM(0, 0x10000),
 		},
 	}
 }
@@ -76,3 +170,44 @@ func NewMemory(entries ...MemoryMapEntry) *Memory {
 		entries: entries,
 	}
 }
+
+// Memory24 is 16 MB address space of 65816, made of 256 banks of 64 KB. Every bank is Memory
+// with its own map entries, so 16 bit address path stays the same. The same Memory can be
+// mapped to several banks to mirror them. Addresses in missing banks read as $FF.
+type Memory24 struct {
+	banks [256]*Memory
+}
+
+func (m *Memory24) Get(address uint32) uint8 {
+	bank := m.banks[uint8(address>>16)]
+	if bank == nil {
+		return 0xFF
+	}
+	return bank.Get(uint16(address))
+}
+
+// Set writes values from address, crossing banks. Writes to missing banks are ignored.
+func (m *Memory24) Set(address uint32, value ...uint8) {
+	for i := range value {
+		valueAddress := address + uint32(i)
+		if bank := m.banks[uint8(valueAddress>>16)]; bank != nil {
+			bank.Set(uint16(valueAddress), value[i])
+		}
+	}
+}
+
+// Bank returns memory of bank, nil if it's missing
+func (m *Memory24) Bank(bank uint8) *Memory {
+	return m.banks[bank]
+}
+
+func (m *Memory24) SetBank(bank uint8, memory *Memory) {
+	m.banks[bank] = memory
+}
+
+// NewMemory24 maps memories to banks from 0
+func NewMemory24(banks ...*Memory) *Memory24 {
+	m := &Memory24{}
+	copy(m.banks[:], banks)
+	return m
+}
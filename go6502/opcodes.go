@@ -0,0 +1,465 @@
+package go6502
+
+import "fmt"
+
+type AddressingMode int
+
+const (
+	Implied AddressingMode = iota
+	Accumulator
+	Immediate
+	ZeroPage
+	ZeroPageX
+	ZeroPageY
+	Absolute
+	AbsoluteX
+	AbsoluteY
+	Indirect
+	IndexedIndirect // (zp,X)
+	IndirectIndexed // (zp),Y
+	Relative
+	// 65C02 modes
+	ZeroPageIndirect        // (zp)
+	AbsoluteIndexedIndirect // (abs,X)
+	ZeroPageRelative        // zp,rel - bit branches
+	// 65816 modes, zero page ones use direct page
+	AbsoluteLong                 // long
+	AbsoluteLongX                // long,X
+	IndirectLong                 // [dp]
+	IndirectLongIndexed          // [dp],Y
+	AbsoluteIndirectLong         // [abs]
+	StackRelative                // sr,S
+	StackRelativeIndirectIndexed // (sr,S),Y
+	RelativeLong                 // 16 bit offset
+	BlockMove                    // destination and source bank
+)
+
+// OperandSize returns number of bytes following the opcode
+func (m AddressingMode) OperandSize() int {
+	switch m {
+	case Implied, Accumulator:
+		return 0
+	case Absolute, AbsoluteX, AbsoluteY, Indirect, AbsoluteIndexedIndirect, ZeroPageRelative,
+		AbsoluteIndirectLong, RelativeLong, BlockMove:
+		return 2
+	case AbsoluteLong, AbsoluteLongX:
+		return 3
+	default:
+		return 1
+	}
+}
+
+type Instruction struct {
+	Mnemonic string
+	Mode     AddressingMode
+	Cycles   int // Base cycles, without page crossing or branch penalties
+}
+
+// Size returns instruction length in bytes, including opcode
+func (i Instruction) Size() int {
+	return 1 + i.Mode.OperandSize()
+}
+
+func (i Instruction) Defined() bool {
+	return i.Mnemonic != ""
+}
+
+// InstructionSet maps opcodes to instructions. Undefined opcodes have empty mnemonic.
+type InstructionSet [256]Instruction
+
+// Opcode finds opcode of instruction with given mnemonic and addressing mode. Documented opcodes
+// of NMOS6502 come first, as undocumented ones duplicate some of them, e.g. NOP or SBC #.
+func (s *InstructionSet) Opcode(mnemonic string, mode AddressingMode) (uint8, bool) {
+	found, ok := uint8(0), false
+	for opcode, instruction := range s {
+		if instruction.Mnemonic != mnemonic || instruction.Mode != mode {
+			continue
+		}
+		if NMOS6502[opcode] == instruction {
+			return uint8(opcode), true
+		}
+		if !ok {
+			found, ok = uint8(opcode), true
+		}
+	}
+	return found, ok
+}
+
+// HasMnemonic checks if any opcode uses mnemonic
+func (s *InstructionSet) HasMnemonic(mnemonic string) bool {
+	for _, instruction := range s {
+		if instruction.Mnemonic == mnemonic {
+			return true
+		}
+	}
+	return false
+}
+
+// NMOS6502 contains all documented instructions of the original 6502
+var NMOS6502 = InstructionSet{
+	0x69: {"ADC", Immediate, 2},
+	0x65: {"ADC", ZeroPage, 3},
+	0x75: {"ADC", ZeroPageX, 4},
+	0x6D: {"ADC", Absolute, 4},
+	0x7D: {"ADC", AbsoluteX, 4},
+	0x79: {"ADC", AbsoluteY, 4},
+	0x61: {"ADC", IndexedIndirect, 6},
+	0x71: {"ADC", IndirectIndexed, 5},
+
+	0x29: {"AND", Immediate, 2},
+	0x25: {"AND", ZeroPage, 3},
+	0x35: {"AND", ZeroPageX, 4},
+	0x2D: {"AND", Absolute, 4},
+	0x3D: {"AND", AbsoluteX, 4},
+	0x39: {"AND", AbsoluteY, 4},
+	0x21: {"AND", IndexedIndirect, 6},
+	0x31: {"AND", IndirectIndexed, 5},
+
+	0x0A: {"ASL", Accumulator, 2},
+	0x06: {"ASL", ZeroPage, 5},
+	0x16: {"ASL", ZeroPageX, 6},
+	0x0E: {"ASL", Absolute, 6},
+	0x1E: {"ASL", AbsoluteX, 7},
+
+	0x90: {"BCC", Relative, 2},
+	0xB0: {"BCS", Relative, 2},
+	0xF0: {"BEQ", Relative, 2},
+	0x30: {"BMI", Relative, 2},
+	0xD0: {"BNE", Relative, 2},
+	0x10: {"BPL", Relative, 2},
+	0x50: {"BVC", Relative, 2},
+	0x70: {"BVS", Relative, 2},
+
+	0x24: {"BIT", ZeroPage, 3},
+	0x2C: {"BIT", Absolute, 4},
+
+	0x00: {"BRK", Implied, 7},
+
+	0x18: {"CLC", Implied, 2},
+	0xD8: {"CLD", Implied, 2},
+	0x58: {"CLI", Implied, 2},
+	0xB8: {"CLV", Implied, 2},
+
+	0xC9: {"CMP", Immediate, 2},
+	0xC5: {"CMP", ZeroPage, 3},
+	0xD5: {"CMP", ZeroPageX, 4},
+	0xCD: {"CMP", Absolute, 4},
+	0xDD: {"CMP", AbsoluteX, 4},
+	0xD9: {"CMP", AbsoluteY, 4},
+	0xC1: {"CMP", IndexedIndirect, 6},
+	0xD1: {"CMP", IndirectIndexed, 5},
+
+	0xE0: {"CPX", Immediate, 2},
+	0xE4: {"CPX", ZeroPage, 3},
+	0xEC: {"CPX", Absolute, 4},
+
+	0xC0: {"CPY", Immediate, 2},
+	0xC4: {"CPY", ZeroPage, 3},
+	0xCC: {"CPY", Absolute, 4},
+
+	0xC6: {"DEC", ZeroPage, 5},
+	0xD6: {"DEC", ZeroPageX, 6},
+	0xCE: {"DEC", Absolute, 6},
+	0xDE: {"DEC", AbsoluteX, 7},
+
+	0xCA: {"DEX", Implied, 2},
+	0x88: {"DEY", Implied, 2},
+
+	0x49: {"EOR", Immediate, 2},
+	0x45: {"EOR", ZeroPage, 3},
+	0x55: {"EOR", ZeroPageX, 4},
+	0x4D: {"EOR", Absolute, 4},
+	0x5D: {"EOR", AbsoluteX, 4},
+	0x59: {"EOR", AbsoluteY, 4},
+	0x41: {"EOR", IndexedIndirect, 6},
+	0x51: {"EOR", IndirectIndexed, 5},
+
+	0xE6: {"INC", ZeroPage, 5},
+	0xF6: {"INC", ZeroPageX, 6},
+	0xEE: {"INC", Absolute, 6},
+	0xFE: {"INC", AbsoluteX, 7},
+
+	0xE8: {"INX", Implied, 2},
+	0xC8: {"INY", Implied, 2},
+
+	0x4C: {"JMP", Absolute, 3},
+	0x6C: {"JMP", Indirect, 5},
+
+	0x20: {"JSR", Absolute, 6},
+
+	0xA9: {"LDA", Immediate, 2},
+	0xA5: {"LDA", ZeroPage, 3},
+	0xB5: {"LDA", ZeroPageX, 4},
+	0xAD: {"LDA", Absolute, 4},
+	0xBD: {"LDA", AbsoluteX, 4},
+	0xB9: {"LDA", AbsoluteY, 4},
+	0xA1: {"LDA", IndexedIndirect, 6},
+	0xB1: {"LDA", IndirectIndexed, 5},
+
+	0xA2: {"LDX", Immediate, 2},
+	0xA6: {"LDX", ZeroPage, 3},
+	0xB6: {"LDX", ZeroPageY, 4},
+	0xAE: {"LDX", Absolute, 4},
+	0xBE: {"LDX", AbsoluteY, 4},
+
+	0xA0: {"LDY", Immediate, 2},
+	0xA4: {"LDY", ZeroPage, 3},
+	0xB4: {"LDY", ZeroPageX, 4},
+	0xAC: {"LDY", Absolute, 4},
+	0xBC: {"LDY", AbsoluteX, 4},
+
+	0x4A: {"LSR", Accumulator, 2},
+	0x46: {"LSR", ZeroPage, 5},
+	0x56: {"LSR", ZeroPageX, 6},
+	0x4E: {"LSR", Absolute, 6},
+	0x5E: {"LSR", AbsoluteX, 7},
+
+	0xEA: {"NOP", Implied, 2},
+
+	0x09: {"ORA", Immediate, 2},
+	0x05: {"ORA", ZeroPage, 3},
+	0x15: {"ORA", ZeroPageX, 4},
+	0x0D: {"ORA", Absolute, 4},
+	0x1D: {"ORA", AbsoluteX, 4},
+	0x19: {"ORA", AbsoluteY, 4},
+	0x01: {"ORA", IndexedIndirect, 6},
+	0x11: {"ORA", IndirectIndexed, 5},
+
+	0x48: {"PHA", Implied, 3},
+	0x08: {"PHP", Implied, 3},
+	0x68: {"PLA", Implied, 4},
+	0x28: {"PLP", Implied, 4},
+
+	0x2A: {"ROL", Accumulator, 2},
+	0x26: {"ROL", ZeroPage, 5},
+	0x36: {"ROL", ZeroPageX, 6},
+	0x2E: {"ROL", Absolute, 6},
+	0x3E: {"ROL", AbsoluteX, 7},
+
+	0x6A: {"ROR", Accumulator, 2},
+	0x66: {"ROR", ZeroPage, 5},
+	0x76: {"ROR", ZeroPageX, 6},
+	0x6E: {"ROR", Absolute, 6},
+	0x7E: {"ROR", AbsoluteX, 7},
+
+	0x40: {"RTI", Implied, 6},
+	0x60: {"RTS", Implied, 6},
+
+	0xE9: {"SBC", Immediate, 2},
+	0xE5: {"SBC", ZeroPage, 3},
+	0xF5: {"SBC", ZeroPageX, 4},
+	0xED: {"SBC", Absolute, 4},
+	0xFD: {"SBC", AbsoluteX, 4},
+	0xF9: {"SBC", AbsoluteY, 4},
+	0xE1: {"SBC", IndexedIndirect, 6},
+	0xF1: {"SBC", IndirectIndexed, 5},
+
+	0x38: {"SEC", Implied, 2},
+	0xF8: {"SED", Implied, 2},
+	0x78: {"SEI", Implied, 2},
+
+	0x85: {"STA", ZeroPage, 3},
+	0x95: {"STA", ZeroPageX, 4},
+	0x8D: {"STA", Absolute, 4},
+	0x9D: {"STA", AbsoluteX, 5},
+	0x99: {"STA", AbsoluteY, 5},
+	0x81: {"STA", IndexedIndirect, 6},
+	0x91: {"STA", IndirectIndexed, 6},
+
+	0x86: {"STX", ZeroPage, 3},
+	0x96: {"STX", ZeroPageY, 4},
+	0x8E: {"STX", Absolute, 4},
+
+	0x84: {"STY", ZeroPage, 3},
+	0x94: {"STY", ZeroPageX, 4},
+	0x8C: {"STY", Absolute, 4},
+
+	0xAA: {"TAX", Implied, 2},
+	0xA8: {"TAY", Implied, 2},
+	0xBA: {"TSX", Implied, 2},
+	0x8A: {"TXA", Implied, 2},
+	0x9A: {"TXS", Implied, 2},
+	0x98: {"TYA", Implied, 2},
+}
+
+// CMOS65C02 contains instructions of WDC 65C02: NMOS 6502 ones, new instructions and (zp)
+// addressing mode, with Rockwell and WDC bit instructions, WAI and STP
+var CMOS65C02 = func() InstructionSet {
+	set := NMOS6502
+	// Fixed page boundary bug takes one more cycle, shifts and rotations by abs,X one less
+	set[0x6C].Cycles = 6
+	for _, opcode := range []uint8{0x1E, 0x3E, 0x5E, 0x7E} {
+		set[opcode].Cycles = 6
+	}
+	for opcode, instruction := range cmos65C02Additions {
+		set[opcode] = instruction
+	}
+	for bit := 0; bit < 8; bit++ {
+		set[0x07+bit<<4] = Instruction{fmt.Sprintf("RMB%d", bit), ZeroPage, 5}
+		set[0x87+bit<<4] = Instruction{fmt.Sprintf("SMB%d", bit), ZeroPage, 5}
+		set[0x0F+bit<<4] = Instruction{fmt.Sprintf("BBR%d", bit), ZeroPageRelative, 5}
+		set[0x8F+bit<<4] = Instruction{fmt.Sprintf("BBS%d", bit), ZeroPageRelative, 5}
+	}
+	return set
+}()
+
+var cmos65C02Additions = map[uint8]Instruction{
+	0x72: {"ADC", ZeroPageIndirect, 5},
+	0x32: {"AND", ZeroPageIndirect, 5},
+	0xD2: {"CMP", ZeroPageIndirect, 5},
+	0x52: {"EOR", ZeroPageIndirect, 5},
+	0xB2: {"LDA", ZeroPageIndirect, 5},
+	0x12: {"ORA", ZeroPageIndirect, 5},
+	0xF2: {"SBC", ZeroPageIndirect, 5},
+	0x92: {"STA", ZeroPageIndirect, 5},
+
+	0x89: {"BIT", Immediate, 2},
+	0x34: {"BIT", ZeroPageX, 4},
+	0x3C: {"BIT", AbsoluteX, 4},
+
+	0x1A: {"INC", Accumulator, 2},
+	0x3A: {"DEC", Accumulator, 2},
+
+	0x7C: {"JMP", AbsoluteIndexedIndirect, 6},
+	0x80: {"BRA", Relative, 3},
+
+	0xDA: {"PHX", Implied, 3},
+	0x5A: {"PHY", Implied, 3},
+	0xFA: {"PLX", Implied, 4},
+	0x7A: {"PLY", Implied, 4},
+
+	0x64: {"STZ", ZeroPage, 3},
+	0x74: {"STZ", ZeroPageX, 4},
+	0x9C: {"STZ", Absolute, 4},
+	0x9E: {"STZ", AbsoluteX, 5},
+
+	0x14: {"TRB", ZeroPage, 5},
+	0x1C: {"TRB", Absolute, 6},
+	0x04: {"TSB", ZeroPage, 5},
+	0x0C: {"TSB", Absolute, 6},
+
+	0xCB: {"WAI", Implied, 3},
+	0xDB: {"STP", Implied, 3},
+}
+
+// NMOS6502Undocumented contains NMOS 6502 instructions with stable undocumented opcodes,
+// see http://www.oxyron.de/html/opcodes02.html. Unstable ones, like XAA or SHA, stay undefined.
+var NMOS6502Undocumented = func() InstructionSet {
+	set := NMOS6502
+	for opcode, instruction := range nmos6502UndocumentedAdditions {
+		set[opcode] = instruction
+	}
+	// Read-modify-write combinations share addressing modes and opcode columns
+	for i, mnemonic := range []string{"SLO", "RLA", "SRE", "RRA", "", "", "DCP", "ISC"} {
+		if mnemonic == "" {
+			continue
+		}
+		base := uint8(i) << 5
+		set[base+0x03] = Instruction{mnemonic, IndexedIndirect, 8}
+		set[base+0x07] = Instruction{mnemonic, ZeroPage, 5}
+		set[base+0x0F] = Instruction{mnemonic, Absolute, 6}
+		set[base+0x13] = Instruction{mnemonic, IndirectIndexed, 8}
+		set[base+0x17] = Instruction{mnemonic, ZeroPageX, 6}
+		set[base+0x1B] = Instruction{mnemonic, AbsoluteY, 7}
+		set[base+0x1F] = Instruction{mnemonic, AbsoluteX, 7}
+	}
+	for _, opcode := range []uint8{0x1A, 0x3A, 0x5A, 0x7A, 0xDA, 0xFA} {
+		set[opcode] = Instruction{"NOP", Implied, 2}
+	}
+	for _, opcode := range []uint8{0x80, 0x82, 0x89, 0xC2, 0xE2} {
+		set[opcode] = Instruction{"NOP", Immediate, 2}
+	}
+	for _, opcode := range []uint8{0x04, 0x44, 0x64} {
+		set[opcode] = Instruction{"NOP", ZeroPage, 3}
+	}
+	for _, opcode := range []uint8{0x14, 0x34, 0x54, 0x74, 0xD4, 0xF4} {
+		set[opcode] = Instruction{"NOP", ZeroPageX, 4}
+	}
+	for _, opcode := range []uint8{0x1C, 0x3C, 0x5C, 0x7C, 0xDC, 0xFC} {
+		set[opcode] = Instruction{"NOP", AbsoluteX, 4}
+	}
+	// JAM never finishes, so it has no cycle count
+	for _, opcode := range []uint8{0x02, 0x12, 0x22, 0x32, 0x42, 0x52, 0x62, 0x72, 0x92, 0xB2, 0xD2, 0xF2} {
+		set[opcode] = Instruction{"JAM", Implied, 0}
+	}
+	return set
+}()
+
+var nmos6502UndocumentedAdditions = map[uint8]Instruction{
+	0xA3: {"LAX", IndexedIndirect, 6},
+	0xA7: {"LAX", ZeroPage, 3},
+	0xAF: {"LAX", Absolute, 4},
+	0xB3: {"LAX", IndirectIndexed, 5},
+	0xB7: {"LAX", ZeroPageY, 4},
+	0xBF: {"LAX", AbsoluteY, 4},
+
+	0x83: {"SAX", IndexedIndirect, 6},
+	0x87: {"SAX", ZeroPage, 3},
+	0x8F: {"SAX", Absolute, 4},
+	0x97: {"SAX", ZeroPageY, 4},
+
+	0x0B: {"ANC", Immediate, 2},
+	0x2B: {"ANC", Immediate, 2},
+	0x4B: {"ALR", Immediate, 2},
+	0x6B: {"ARR", Immediate, 2},
+	0xCB: {"SBX", Immediate, 2},
+	0xEB: {"SBC", Immediate, 2},
+
+	0x0C: {"NOP", Absolute, 4},
+}
+
+// WDC65816 contains instructions of 65C816. Immediate operands of accumulator and index
+// instructions are 16 bit, when M or X flag is clear, which isn't reflected by Size.
+var WDC65816 = func() InstructionSet {
+	set := CMOS65C02
+	set[0x6C].Cycles = 5
+	// Bit instructions of 65C02 are replaced by long and stack relative modes
+	for i, mnemonic := range []string{"ORA", "AND", "EOR", "ADC", "STA", "LDA", "CMP", "SBC"} {
+		base := uint8(i) << 5
+		set[base+0x03] = Instruction{mnemonic, StackRelative, 4}
+		set[base+0x07] = Instruction{mnemonic, IndirectLong, 6}
+		set[base+0x0F] = Instruction{mnemonic, AbsoluteLong, 5}
+		set[base+0x13] = Instruction{mnemonic, StackRelativeIndirectIndexed, 7}
+		set[base+0x17] = Instruction{mnemonic, IndirectLongIndexed, 6}
+		set[base+0x1F] = Instruction{mnemonic, AbsoluteLongX, 5}
+	}
+	for opcode, instruction := range wdc65816Additions {
+		set[opcode] = instruction
+	}
+	return set
+}()
+
+var wdc65816Additions = map[uint8]Instruction{
+	0x02: {"COP", Immediate, 7},
+	0x42: {"WDM", Immediate, 2},
+	0xC2: {"REP", Immediate, 3},
+	0xE2: {"SEP", Immediate, 3},
+
+	0x22: {"JSL", AbsoluteLong, 8},
+	0x5C: {"JML", AbsoluteLong, 4},
+	0xDC: {"JML", AbsoluteIndirectLong, 6},
+	0xFC: {"JSR", AbsoluteIndexedIndirect, 8},
+	0x6B: {"RTL", Implied, 6},
+	0x82: {"BRL", RelativeLong, 4},
+
+	0x44: {"MVP", BlockMove, 7},
+	0x54: {"MVN", BlockMove, 7},
+
+	0xF4: {"PEA", Absolute, 5},
+	0xD4: {"PEI", ZeroPageIndirect, 6},
+	0x62: {"PER", RelativeLong, 6},
+	0x0B: {"PHD", Implied, 4},
+	0x2B: {"PLD", Implied, 5},
+	0x4B: {"PHK", Implied, 3},
+	0x8B: {"PHB", Implied, 3},
+	0xAB: {"PLB", Implied, 4},
+
+	0x1B: {"TCS", Implied, 2},
+	0x3B: {"TSC", Implied, 2},
+	0x5B: {"TCD", Implied, 2},
+	0x7B: {"TDC", Implied, 2},
+	0x9B: {"TXY", Implied, 2},
+	0xBB: {"TYX", Implied, 2},
+	0xEB: {"XBA", Implied, 3},
+	0xFB: {"XCE", Implied, 2},
+}
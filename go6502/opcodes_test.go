@@ -0,0 +1,49 @@
+package go6502
+
+import "testing"
+
+func TestInstructionSet(t *testing.T) {
+	defined := 0
+	for _, instruction := range NMOS6502 {
+		if instruction.Defined() {
+			defined++
+		}
+	}
+	if defined != 151 {
+		t.Fatalf("NMOS 6502 should have 151 documented opcodes, got %v", defined)
+	}
+
+	opcode, ok := NMOS6502.Opcode("LDA", AbsoluteY)
+	if !ok || opcode != OpLDA_absolute_y {
+		t.Fatalf("Wrong opcode for LDA abs,Y. Expected %x, got %x", OpLDA_absolute_y, opcode)
+	}
+	if NMOS6502[OpJMP_indirect].Size() != 3 {
+		t.Fatalf("Wrong size of JMP indirect. Expected %v, got %v", 3, NMOS6502[OpJMP_indirect].Size())
+	}
+	if _, ok := NMOS6502.Opcode("STX", AbsoluteY); ok {
+		t.Fatalf("STX abs,Y doesn't exist")
+	}
+}
+
+func TestCMOSInstructionSet(t *testing.T) {
+	defined := 0
+	for _, instruction := range CMOS65C02 {
+		if instruction.Defined() {
+			defined++
+		}
+	}
+	if defined != 212 {
+		t.Fatalf("65C02 should have 212 opcodes, got %v", defined)
+	}
+
+	opcode, ok := CMOS65C02.Opcode("STZ", AbsoluteX)
+	if !ok || opcode != OpSTZ_absolute_x {
+		t.Fatalf("Wrong opcode for STZ abs,X. Expected %x, got %x", OpSTZ_absolute_x, opcode)
+	}
+	if CMOS65C02[OpBBS0+7<<4].Size() != 3 || CMOS65C02[OpBBS0+7<<4].Mnemonic != "BBS7" {
+		t.Fatalf("Wrong BBS7 instruction, got %v", CMOS65C02[OpBBS0+7<<4])
+	}
+	if NMOS6502[OpPHX].Defined() {
+		t.Fatalf("PHX shouldn't be defined for NMOS 6502")
+	}
+}
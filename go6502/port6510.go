@@ -0,0 +1,93 @@
+package go6502
+
+import "fmt"
+
+const (
+	Port6510Direction = 0x0000
+	Port6510Data      = 0x0001
+)
+
+// Port6510 is on-chip I/O port of MOS 6510. Bits set in direction register make pins outputs,
+// driven by data register, the other pins are inputs. C64 selects ROM and I/O banks by output
+// pins, so Changed can be used to switch banks of memory controller.
+type Port6510 struct {
+	Direction uint8
+	Data      uint8
+	// Input is level of pins configured as inputs, pull-up resistors make it $FF by default
+	Input uint8
+	// Changed is called with new level of pins after write to port changes it
+	Changed func(pins uint8)
+}
+
+func (p *Port6510) WithinRange(address uint16) bool {
+	return address <= Port6510Data
+}
+
+func (p *Port6510) Get(address uint16) uint8 {
+	if address == Port6510Direction {
+		return p.Direction
+	}
+	return p.Pins()
+}
+
+// peek returns register instead of pins, so writes undone by History restore data register exactly
+func (p *Port6510) peek(address uint16) uint8 {
+	if address == Port6510Direction {
+		return p.Direction
+	}
+	return p.Data
+}
+
+func (p *Port6510) Set(address uint16, value uint8) {
+	previous := p.Pins()
+	if address == Port6510Direction {
+		p.Direction = value
+	} else {
+		p.Data = value
+	}
+	p.notify(previous)
+}
+
+// Pins returns level of all pins, outputs from data register and inputs from Input
+func (p *Port6510) Pins() uint8 {
+	return p.Data&p.Direction | p.Input&^p.Direction
+}
+
+// Reset makes all pins inputs, like reset of CPU
+func (p *Port6510) Reset() {
+	p.Set(Port6510Direction, 0)
+}
+
+func (p *Port6510) notify(previous uint8) {
+	if p.Changed != nil && p.Pins() != previous {
+		p.Changed(p.Pins())
+	}
+}
+
+func (p *Port6510) MarshalBinary() ([]byte, error) {
+	return []byte{p.Direction, p.Data}, nil
+}
+
+func (p *Port6510) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("port: state has %d bytes, expected 2", len(data))
+	}
+	previous := p.Pins()
+	p.Direction, p.Data = data[0], data[1]
+	p.notify(previous)
+	return nil
+}
+
+func NewPort6510() *Port6510 {
+	return &Port6510{Input: 0xFF}
+}
+
+// port6510 returns port mapped in memory, nil if there is none
+func (m *Memory) port6510() *Port6510 {
+	for _, entry := range m.entries {
+		if port, ok := entry.(*Port6510); ok {
+			return port
+		}
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package go6502
+
+import "strings"
+
+/*
+referenceCPU is a minimal NMOS 6502 interpreter used to check CPU by differential fuzzing.
+It's written independently from CPU: instructions are decoded from the opcode matrix below
+by mnemonic and addressing mode, and decimal mode follows
+http://www.6502.org/tutorials/decimal_mode.html (appendix A).
+*/
+type referenceCPU struct {
+	a, x, y, s, p uint8
+	pc            uint16
+	memory        [0x10000]uint8
+	written       map[uint16]bool
+}
+
+const (
+	referenceCarry    = 0x01
+	referenceZero     = 0x02
+	referenceIRQ      = 0x04
+	referenceDecimal  = 0x08
+	referenceBreak    = 0x10
+	referenceUnused   = 0x20
+	referenceOverflow = 0x40
+	referenceNegative = 0x80
+)
+
+type referenceInstruction struct {
+	mnemonic string
+	mode     string
+}
+
+// Rows are high nibble of opcode, columns low nibble, "-" marks undefined opcodes
+var referenceMatrix = [16]string{
+	"BRK impl|ORA X,ind|-|-|-|ORA zpg|ASL zpg|-|PHP impl|ORA #|ASL A|-|-|ORA abs|ASL abs|-",
+	"BPL rel|ORA ind,Y|-|-|-|ORA zpg,X|ASL zpg,X|-|CLC impl|ORA abs,Y|-|-|-|ORA abs,X|ASL abs,X|-",
+	"JSR abs|AND X,ind|-|-|BIT zpg|AND zpg|ROL zpg|-|PLP impl|AND #|ROL A|-|BIT abs|AND abs|ROL abs|-",
+	"BMI rel|AND ind,Y|-|-|-|AND zpg,X|ROL zpg,X|-|SEC impl|AND abs,Y|-|-|-|AND abs,X|ROL abs,X|-",
+	"RTI impl|EOR X,ind|-|-|-|EOR zpg|LSR zpg|-|PHA impl|EOR #|LSR A|-|JMP abs|EOR abs|LSR abs|-",
+	"BVC rel|EOR ind,Y|-|-|-|EOR zpg,X|LSR zpg,X|-|CLI impl|EOR abs,Y|-|-|-|EOR abs,X|LSR abs,X|-",
+	"RTS impl|ADC X,ind|-|-|-|ADC zpg|ROR zpg|-|PLA impl|ADC #|ROR A|-|JMP ind|ADC abs|ROR abs|-",
+	"BVS rel|ADC ind,Y|-|-|-|ADC zpg,X|ROR zpg,X|-|SEI impl|ADC abs,Y|-|-|-|ADC abs,X|ROR abs,X|-",
+	"-|STA X,ind|-|-|STY zpg|STA zpg|STX zpg|-|DEY impl|-|TXA impl|-|STY abs|STA abs|STX abs|-",
+	"BCC rel|STA ind,Y|-|-|STY zpg,X|STA zpg,X|STX zpg,Y|-|TYA impl|STA abs,Y|TXS impl|-|-|STA abs,X|-|-",
+	"LDY #|LDA X,ind|LDX #|-|LDY zpg|LDA zpg|LDX zpg|-|TAY impl|LDA #|TAX impl|-|LDY abs|LDA abs|LDX abs|-",
+	"BCS rel|LDA ind,Y|-|-|LDY zpg,X|LDA zpg,X|LDX zpg,Y|-|CLV impl|LDA abs,Y|TSX impl|-|LDY abs,X|LDA abs,X|LDX abs,Y|-",
+	"CPY #|CMP X,ind|-|-|CPY zpg|CMP zpg|DEC zpg|-|INY impl|CMP #|DEX impl|-|CPY abs|CMP abs|DEC abs|-",
+	"BNE rel|CMP ind,Y|-|-|-|CMP zpg,X|DEC zpg,X|-|CLD impl|CMP abs,Y|-|-|-|CMP abs,X|DEC abs,X|-",
+	"CPX #|SBC X,ind|-|-|CPX zpg|SBC zpg|INC zpg|-|INX impl|SBC #|NOP impl|-|CPX abs|SBC abs|INC abs|-",
+	"BEQ rel|SBC ind,Y|-|-|-|SBC zpg,X|INC zpg,X|-|SED impl|SBC abs,Y|-|-|-|SBC abs,X|INC abs,X|-",
+}
+
+var referenceInstructions = func() (instructions [256]referenceInstruction) {
+	for high, row := range referenceMatrix {
+		for low, cell := range strings.Split(row, "|") {
+			if mnemonic, mode, ok := strings.Cut(cell, " "); ok {
+				instructions[high<<4|low] = referenceInstruction{mnemonic, mode}
+			}
+		}
+	}
+	return
+}()
+
+func (r *referenceCPU) read(address uint16) uint8 {
+	return r.memory[address]
+}
+
+func (r *referenceCPU) read16(address uint16) uint16 {
+	return uint16(r.read(address)) | uint16(r.read(address+1))<<8
+}
+
+// readZeropage16 reads pointer, whose high byte wraps around to start of zero page
+func (r *referenceCPU) readZeropage16(address uint8) uint16 {
+	return uint16(r.read(uint16(address))) | uint16(r.read(uint16(address+1)))<<8
+}
+
+func (r *referenceCPU) write(address uint16, value uint8) {
+	r.memory[address] = value
+	r.written[address] = true
+}
+
+func (r *referenceCPU) fetch() uint8 {
+	value := r.read(r.pc)
+	r.pc++
+	return value
+}
+
+func (r *referenceCPU) push(value uint8) {
+	r.write(0x0100|uint16(r.s), value)
+	r.s--
+}
+
+func (r *referenceCPU) pull() uint8 {
+	r.s++
+	return r.read(0x0100 | uint16(r.s))
+}
+
+func (r *referenceCPU) flag(mask uint8) bool {
+	return r.p&mask != 0
+}
+
+func (r *referenceCPU) setFlag(mask uint8, value bool) {
+	if value {
+		r.p |= mask
+	} else {
+		r.p &^= mask
+	}
+}
+
+func (r *referenceCPU) setNZ(value uint8) uint8 {
+	r.setFlag(referenceZero, value == 0)
+	r.setFlag(referenceNegative, value&0x80 != 0)
+	return value
+}
+
+func (r *referenceCPU) carry() int {
+	if r.flag(referenceCarry) {
+		return 1
+	}
+	return 0
+}
+
+// operand returns effective address of instruction, for immediate mode it's address of the operand byte
+func (r *referenceCPU) operand(mode string) uint16 {
+	switch mode {
+	case "#":
+		r.pc++
+		return r.pc - 1
+	case "zpg":
+		return uint16(r.fetch())
+	case "zpg,X":
+		return uint16(r.fetch() + r.x)
+	case "zpg,Y":
+		return uint16(r.fetch() + r.y)
+	case "abs":
+		r.pc += 2
+		return r.read16(r.pc - 2)
+	case "abs,X":
+		r.pc += 2
+		return r.read16(r.pc-2) + uint16(r.x)
+	case "abs,Y":
+		r.pc += 2
+		return r.read16(r.pc-2) + uint16(r.y)
+	case "X,ind":
+		return r.readZeropage16(r.fetch() + r.x)
+	case "ind,Y":
+		return r.readZeropage16(r.fetch()) + uint16(r.y)
+	case "ind":
+		// NMOS 6502 doesn't carry into high byte of pointer
+		r.pc += 2
+		pointer := r.read16(r.pc - 2)
+		return uint16(r.read(pointer)) | uint16(r.read(pointer&0xFF00|(pointer+1)&0x00FF))<<8
+	}
+	return 0
+}
+
+func (r *referenceCPU) add(value uint8) {
+	binary := int(r.a) + int(value) + r.carry()
+	result := uint8(binary)
+	r.setFlag(referenceZero, result == 0)
+	if !r.flag(referenceDecimal) {
+		r.setFlag(referenceNegative, result&0x80 != 0)
+		r.setFlag(referenceOverflow, (r.a^result)&(value^result)&0x80 != 0)
+		r.setFlag(referenceCarry, binary > 0xFF)
+		r.a = result
+		return
+	}
+	low := int(r.a&0x0F) + int(value&0x0F) + r.carry()
+	if low >= 0x0A {
+		low = ((low + 0x06) & 0x0F) + 0x10
+	}
+	// N and V come from signed sum before high nibble is adjusted
+	signed := int(int8(r.a&0xF0)) + int(int8(value&0xF0)) + low
+	r.setFlag(referenceNegative, signed&0x80 != 0)
+	r.setFlag(referenceOverflow, signed < -128 || signed > 127)
+	sum := int(r.a&0xF0) + int(value&0xF0) + low
+	if sum >= 0xA0 {
+		sum += 0x60
+	}
+	r.setFlag(referenceCarry, sum >= 0x100)
+	r.a = uint8(sum)
+}
+
+func (r *referenceCPU) subtract(value uint8) {
+	binary := int(r.a) - int(value) - 1 + r.carry()
+	result := uint8(binary)
+	// NMOS 6502 sets flags as for binary subtraction even in decimal mode
+	r.setNZ(result)
+	r.setFlag(referenceOverflow, (r.a^value)&(r.a^result)&0x80 != 0)
+	if r.flag(referenceDecimal) {
+		low := int(r.a&0x0F) - int(value&0x0F) + r.carry() - 1
+		if low < 0 {
+			low = ((low - 0x06) & 0x0F) - 0x10
+		}
+		difference := int(r.a&0xF0) - int(value&0xF0) + low
+		if difference < 0 {
+			difference -= 0x60
+		}
+		result = uint8(difference)
+	}
+	r.setFlag(referenceCarry, binary >= 0)
+	r.a = result
+}
+
+func (r *referenceCPU) compare(register uint8, value uint8) {
+	r.setNZ(register - value)
+	r.setFlag(referenceCarry, register >= value)
+}
+
+func (r *referenceCPU) branch(condition bool) {
+	offset := int8(r.fetch())
+	if condition {
+		r.pc += uint16(offset)
+	}
+}
+
+// shift applies read-modify-write operation to accumulator or memory
+func (r *referenceCPU) shift(mode string, operation func(value uint8) uint8) {
+	if mode == "A" {
+		r.a = r.setNZ(operation(r.a))
+		return
+	}
+	address := r.operand(mode)
+	r.write(address, r.setNZ(operation(r.read(address))))
+}
+
+// step executes one instruction, it returns false for undefined opcode without executing anything
+func (r *referenceCPU) step() bool {
+	instruction := referenceInstructions[r.read(r.pc)]
+	if instruction.mnemonic == "" {
+		return false
+	}
+	r.pc++
+	mode := instruction.mode
+	switch instruction.mnemonic {
+	case "LDA":
+		r.a = r.setNZ(r.read(r.operand(mode)))
+	case "LDX":
+		r.x = r.setNZ(r.read(r.operand(mode)))
+	case "LDY":
+		r.y = r.setNZ(r.read(r.operand(mode)))
+	case "STA":
+		r.write(r.operand(mode), r.a)
+	case "STX":
+		r.write(r.operand(mode), r.x)
+	case "STY":
+		r.write(r.operand(mode), r.y)
+	case "TAX":
+		r.x = r.setNZ(r.a)
+	case "TAY":
+		r.y = r.setNZ(r.a)
+	case "TXA":
+		r.a = r.setNZ(r.x)
+	case "TYA":
+		r.a = r.setNZ(r.y)
+	case "TSX":
+		r.x = r.setNZ(r.s)
+	case "TXS":
+		r.s = r.x
+	case "INX":
+		r.x = r.setNZ(r.x + 1)
+	case "INY":
+		r.y = r.setNZ(r.y + 1)
+	case "DEX":
+		r.x = r.setNZ(r.x - 1)
+	case "DEY":
+		r.y = r.setNZ(r.y - 1)
+	case "INC":
+		address := r.operand(mode)
+		r.write(address, r.setNZ(r.read(address)+1))
+	case "DEC":
+		address := r.operand(mode)
+		r.write(address, r.setNZ(r.read(address)-1))
+	case "ADC":
+		r.add(r.read(r.operand(mode)))
+	case "SBC":
+		r.subtract(r.read(r.operand(mode)))
+	case "AND":
+		r.a = r.setNZ(r.a & r.read(r.operand(mode)))
+	case "ORA":
+		r.a = r.setNZ(r.a | r.read(r.operand(mode)))
+	case "EOR":
+		r.a = r.setNZ(r.a ^ r.read(r.operand(mode)))
+	case "BIT":
+		value := r.read(r.operand(mode))
+		r.setFlag(referenceZero, r.a&value == 0)
+		r.setFlag(referenceNegative, value&0x80 != 0)
+		r.setFlag(referenceOverflow, value&0x40 != 0)
+	case "CMP":
+		r.compare(r.a, r.read(r.operand(mode)))
+	case "CPX":
+		r.compare(r.x, r.read(r.operand(mode)))
+	case "CPY":
+		r.compare(r.y, r.read(r.operand(mode)))
+	case "ASL":
+		r.shift(mode, func(value uint8) uint8 {
+			r.setFlag(referenceCarry, value&0x80 != 0)
+			return value << 1
+		})
+	case "LSR":
+		r.shift(mode, func(value uint8) uint8 {
+			r.setFlag(referenceCarry, value&0x01 != 0)
+			return value >> 1
+		})
+	case "ROL":
+		r.shift(mode, func(value uint8) uint8 {
+			carry := uint8(r.carry())
+			r.setFlag(referenceCarry, value&0x80 != 0)
+			return value<<1 | carry
+		})
+	case "ROR":
+		r.shift(mode, func(value uint8) uint8 {
+			carry := uint8(r.carry()) << 7
+			r.setFlag(referenceCarry, value&0x01 != 0)
+			return value>>1 | carry
+		})
+	case "BPL":
+		r.branch(!r.flag(referenceNegative))
+	case "BMI":
+		r.branch(r.flag(referenceNegative))
+	case "BVC":
+		r.branch(!r.flag(referenceOverflow))
+	case "BVS":
+		r.branch(r.flag(referenceOverflow))
+	case "BCC":
+		r.branch(!r.flag(referenceCarry))
+	case "BCS":
+		r.branch(r.flag(referenceCarry))
+	case "BNE":
+		r.branch(!r.flag(referenceZero))
+	case "BEQ":
+		r.branch(r.flag(referenceZero))
+	case "CLC":
+		r.setFlag(referenceCarry, false)
+	case "SEC":
+		r.setFlag(referenceCarry, true)
+	case "CLI":
+		r.setFlag(referenceIRQ, false)
+	case "SEI":
+		r.setFlag(referenceIRQ, true)
+	case "CLV":
+		r.setFlag(referenceOverflow, false)
+	case "CLD":
+		r.setFlag(referenceDecimal, false)
+	case "SED":
+		r.setFlag(referenceDecimal, true)
+	case "PHA":
+		r.push(r.a)
+	case "PLA":
+		r.a = r.setNZ(r.pull())
+	case "PHP":
+		r.push(r.p | referenceBreak | referenceUnused)
+	case "PLP":
+		r.p = r.pull()&^referenceBreak | referenceUnused
+	case "JMP":
+		r.pc = r.operand(mode)
+	case "JSR":
+		// High byte of target is read after return address is pushed, stack can overwrite it
+		low := r.fetch()
+		r.push(uint8(r.pc >> 8))
+		r.push(uint8(r.pc))
+		r.pc = uint16(r.read(r.pc))<<8 | uint16(low)
+	case "RTS":
+		r.pc = uint16(r.pull()) | uint16(r.pull())<<8
+		r.pc++
+	case "BRK":
+		r.pc++
+		r.push(uint8(r.pc >> 8))
+		r.push(uint8(r.pc))
+		r.push(r.p | referenceBreak | referenceUnused)
+		r.setFlag(referenceIRQ, true)
+		r.pc = r.read16(0xFFFE)
+	case "RTI":
+		r.p = r.pull()&^referenceBreak | referenceUnused
+		r.pc = uint16(r.pull()) | uint16(r.pull())<<8
+	case "NOP":
+	}
+	return true
+}
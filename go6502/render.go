@@ -0,0 +1,76 @@
+package go6502
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"os"
+)
+
+// Render draws screen into img, which must be at least ScreenWidth x ScreenHeight pixels.
+// Unlike window of display package, it works without GUI, e.g. in tests.
+func (s *Screen) Render(img *image.RGBA) {
+	for block := 0; block < blocks; block++ {
+		s.renderBlock(img, block)
+	}
+}
+
+// RenderDirty redraws only blocks changed since its previous call, so img must be kept between
+// calls. The first call draws whole screen. It returns false when nothing was changed.
+func (s *Screen) RenderDirty(img *image.RGBA) bool {
+	changed := false
+	for block, dirty := range s.dirty {
+		if dirty {
+			s.renderBlock(img, block)
+			s.dirty[block] = false
+			changed = true
+		}
+	}
+	return changed
+}
+
+// renderBlock writes pixels of block directly to img, with colors mapped once per block
+func (s *Screen) renderBlock(img *image.RGBA, block int) {
+	fg := mapColor(s.colorMappings[block*2])
+	bg := mapColor(s.colorMappings[block*2+1])
+	blockX := block % BlocksInLine * BlockWidth
+	blockY := block / BlocksInLine * BlockHeight
+	for line := 0; line < BlockHeight; line++ {
+		y := blockY + line
+		pixels := s.pixels[y*bytesInLine+blockX/PixelsPerByte]
+		offset := img.PixOffset(img.Rect.Min.X+blockX, img.Rect.Min.Y+y)
+		for bit := 0; bit < PixelsPerByte; bit++ {
+			c := bg
+			if pixels&(1<<bit) != 0 {
+				c = fg
+			}
+			pix := img.Pix[offset+bit*4 : offset+bit*4+4]
+			pix[0], pix[1], pix[2], pix[3] = c.R, c.G, c.B, c.A
+		}
+	}
+}
+
+// Image returns new image with contents of screen
+func (s *Screen) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	s.Render(img)
+	return img
+}
+
+// WritePNG encodes contents of screen as PNG image
+func (s *Screen) WritePNG(w io.Writer) error {
+	return png.Encode(w, s.Image())
+}
+
+// SavePNG writes contents of screen to PNG file
+func (s *Screen) SavePNG(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := s.WritePNG(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,140 @@
+package go6502
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden images in testdata")
+
+// testScreen draws stripes in blocks of different colors
+func testScreen() *Screen {
+	screen := NewScreen(0xD000)
+	for y := 0; y < ScreenHeight; y += BlockHeight {
+		for x := 0; x < ScreenWidth; x += BlockWidth {
+			screen.SetMapping(x, y, uint8(x/BlockWidth*7+y/BlockHeight), 0b00000011)
+		}
+	}
+	for i := 0; i < PixelColorBytes; i++ {
+		screen.Set(0xD000+ColorMappingsBytes+uint16(i), uint8(i/(ScreenWidth/PixelsPerByte)))
+	}
+	return screen
+}
+
+func TestRender(t *testing.T) {
+	screen := testScreen()
+	img := screen.Image()
+	if img.Bounds() != image.Rect(0, 0, ScreenWidth, ScreenHeight) {
+		t.Fatalf("Wrong image size. Expected %v, got %v", image.Rect(0, 0, ScreenWidth, ScreenHeight), img.Bounds())
+	}
+	for _, p := range []image.Point{{0, 0}, {9, 1}, {100, 37}, {ScreenWidth - 1, ScreenHeight - 1}} {
+		if img.RGBAAt(p.X, p.Y) != screen.PixelColor(p.X, p.Y) {
+			t.Fatalf("Pixel %v should have color of screen. Expected %v, got %v", p, screen.PixelColor(p.X, p.Y), img.RGBAAt(p.X, p.Y))
+		}
+	}
+
+	offset := image.NewRGBA(image.Rect(10, 20, 10+ScreenWidth, 20+ScreenHeight))
+	screen.Render(offset)
+	if offset.RGBAAt(19, 21) != img.RGBAAt(9, 1) {
+		t.Fatalf("Image should be drawn from its origin. Expected %v, got %v", img.RGBAAt(9, 1), offset.RGBAAt(19, 21))
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	golden := filepath.Join("testdata", "screen.png")
+	output := bytes.Buffer{}
+	if err := testScreen().WritePNG(&output); err != nil {
+		t.Fatalf("Can't encode screen: %v", err)
+	}
+	if *updateGolden {
+		if err := os.WriteFile(golden, output.Bytes(), 0o644); err != nil {
+			t.Fatalf("Can't write golden image: %v", err)
+		}
+	}
+	expected, err := readPNG(golden)
+	if err != nil {
+		t.Fatalf("Can't read golden image, run tests with -update to create it: %v", err)
+	}
+	actual, _ := png.Decode(&output)
+	if diff := diffImages(expected, actual); diff != "" {
+		t.Fatalf("Screen doesn't match %s: %s", golden, diff)
+	}
+}
+
+func readPNG(name string) (image.Image, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+// diffImages describes the first pixel, which differs
+func diffImages(expected, actual image.Image) string {
+	if expected.Bounds() != actual.Bounds() {
+		return "size " + actual.Bounds().String() + " instead of " + expected.Bounds().String()
+	}
+	bounds := expected.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, a1 := expected.At(x, y).RGBA()
+			r2, g2, b2, a2 := actual.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return "pixel " + image.Pt(x, y).String() + " differs"
+			}
+		}
+	}
+	return ""
+}
+
+func TestRenderDirty(t *testing.T) {
+	screen := testScreen()
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	if !screen.RenderDirty(img) || diffImages(screen.Image(), img) != "" {
+		t.Fatalf("The first render should draw whole screen")
+	}
+	if screen.RenderDirty(img) {
+		t.Fatalf("Unchanged screen shouldn't be redrawn")
+	}
+
+	// Pixel byte of the second block in line 9, i.e. the second row of blocks
+	screen.Set(0xD000+ColorMappingsBytes+9*bytesInLine+1, 0xFF)
+	img.SetRGBA(200, 100, color.RGBA{})
+	if !screen.RenderDirty(img) || img.RGBAAt(8, 9) != screen.PixelColor(8, 9) {
+		t.Fatalf("Changed block should be redrawn. Expected %v, got %v", screen.PixelColor(8, 9), img.RGBAAt(8, 9))
+	}
+	if img.RGBAAt(200, 100) != (color.RGBA{}) {
+		t.Fatalf("Other blocks shouldn't be redrawn")
+	}
+
+	screen.SetMapping(200, 100, 0xFF, 0xFF)
+	screen.RenderDirty(img)
+	if img.RGBAAt(200, 100) != mapColor(0xFF) {
+		t.Fatalf("Block with changed colors should be redrawn. Expected %v, got %v", mapColor(0xFF), img.RGBAAt(200, 100))
+	}
+}
+
+func BenchmarkRenderFullFrame(b *testing.B) {
+	screen := testScreen()
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	for i := 0; i < b.N; i++ {
+		screen.Render(img)
+	}
+}
+
+func BenchmarkRenderDirtyBlock(b *testing.B) {
+	screen := testScreen()
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	screen.RenderDirty(img)
+	for i := 0; i < b.N; i++ {
+		screen.Set(0xD000+ColorMappingsBytes, uint8(i))
+		screen.RenderDirty(img)
+	}
+}
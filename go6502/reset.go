@@ -0,0 +1,55 @@
+package go6502
+
+// Reset pulls RES input of CPU. Instruction in progress and pending interrupts are abandoned and
+// reset sequence is run by the next Advance or Tick, also when CPU is halted. I/O port of 6510
+// is reset at once.
+func (cpu *CPU) Reset() {
+	if cpu.bus.active {
+		cpu.endInstruction()
+	}
+	cpu.resetting = true
+	cpu.irqPending, cpu.nmiPending, cpu.interrupting = false, false, 0
+	if cpu.Port != nil {
+		cpu.Port.Reset()
+	}
+}
+
+// reset takes 7 cycles like interrupt, but pushes are turned into reads,
+// so stack pointer is only decremented by 3
+func (cpu *CPU) reset() {
+	cpu.dummyRead(cpu.PC)
+	cpu.dummyRead(cpu.PC)
+	for i := 0; i < 3; i++ {
+		cpu.dummyRead(cpu.stackPointerAddress())
+		cpu.S--
+	}
+	cpu.Flags.SetInterruptDisable(true)
+	if cpu.Variant == WDC65C02 {
+		cpu.Flags.SetDecimal(false)
+	}
+	lowerBytes := uint16(cpu.read(ResetVectorL))
+	cpu.PC = uint16(cpu.read(ResetVectorH))<<8 + lowerBytes
+	cpu.State = Running
+	cpu.Err = nil
+	cpu.resetting = false
+}
+
+// SetRDY sets level of RDY input. While it's low, CPU stops on read cycles,
+// so Advance does nothing and Tick repeats the cycle.
+func (cpu *CPU) SetRDY(ready bool) {
+	cpu.notReady = !ready
+}
+
+// SetSO sets level of SO input, its falling edge sets overflow flag
+func (cpu *CPU) SetSO(high bool) {
+	if !high && !cpu.soLow {
+		cpu.Flags.SetOverflow(true)
+		if cpu.bus.active {
+			// Instruction in progress starts again from saved registers on every tick
+			flags := Flags{val: cpu.bus.start.P}
+			flags.SetOverflow(true)
+			cpu.bus.start.P = flags.Value()
+		}
+	}
+	cpu.soLow = !high
+}
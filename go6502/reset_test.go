@@ -0,0 +1,117 @@
+package go6502
+
+import "testing"
+
+func TestResetSequence(t *testing.T) {
+	cpu := NewDefaultMemoryCPU(WithVariant(WDC65C02))
+	cpu.Memory.Set(ResetVectorL, 0x00, 0x02)
+	cpu.Memory.Set(0x0200, OpINX, OpINX, OpINX, OpSTP)
+	cpu.Flags.SetDecimal(true)
+	cpu.Initialize()
+	if cpu.PC != 0x0200 || cpu.S != 0xFD || !cpu.Flags.HasInterruptDisable() || cpu.Flags.HasDecimal() {
+		t.Fatalf("Reset should set S to %x and disable interrupts, got\n%v", 0xFD, cpu)
+	}
+
+	cpu.Tick()
+	cpu.Reset()
+	cycles := tickInstruction(cpu)
+	expected := []BusCycle{{Address: 0x0200, Value: OpINX}, {Address: 0x0200, Value: OpINX},
+		{Address: 0x01FD}, {Address: 0x01FC}, {Address: 0x01FB}, {Address: 0xFFFC}, {Address: 0xFFFD, Value: 0x02}}
+	if len(cycles) != len(expected) || cpu.X != 0 || cpu.S != 0xFA {
+		t.Fatalf("Soft reset should abandon instruction and take 7 cycles. Expected %v, got %v\n%v", expected, cycles, cpu)
+	}
+	for i := range expected {
+		if cycles[i] != expected[i] {
+			t.Fatalf("Wrong reset cycle %d. Expected %v, got %v", i, expected[i], cycles[i])
+		}
+	}
+
+	cpu.PC = 0x0203
+	cpu.Advance()
+	cpu.Reset()
+	cpu.Advance()
+	if cpu.State != Running || cpu.PC != 0x0200 {
+		t.Fatalf("Reset should start stopped CPU. Expected %v at %x, got %v at %x", Running, 0x0200, cpu.State, cpu.PC)
+	}
+}
+
+func TestRDY(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpINC_zeropage, 0x10, OpINX)
+	cpu.PC = 0x0200
+	cpu.SetRDY(false)
+	cpu.Advance()
+	if cpu.PC != 0x0200 {
+		t.Fatalf("Advance shouldn't run while RDY is low. Expected PC %x, got %x", 0x0200, cpu.PC)
+	}
+
+	cpu.SetRDY(true)
+	cpu.Tick()
+	cpu.Tick()
+	cpu.Tick()
+	cpu.SetRDY(false)
+	// Writes aren't stopped by RDY
+	cpu.Tick()
+	if !cpu.Tick() || cpu.Memory.Get(0x0010) != 0x01 {
+		t.Fatalf("Read-modify-write should finish writes while RDY is low. Expected %x, got %x", 0x01, cpu.Memory.Get(0x0010))
+	}
+	for i := 0; i < 10; i++ {
+		if cpu.Tick() {
+			t.Fatalf("Read cycle shouldn't happen while RDY is low")
+		}
+	}
+	cpu.SetRDY(true)
+	if cpu.Tick() || !cpu.Tick() || cpu.X != 1 || cpu.Cycles != 17 {
+		t.Fatalf("INX should run after RDY is high. Expected X %x, got %x after %d cycles", 1, cpu.X, cpu.Cycles)
+	}
+}
+
+func TestSO(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.SetSO(true)
+	if cpu.Flags.HasOverflow() {
+		t.Fatalf("Rising edge of SO shouldn't set overflow")
+	}
+	cpu.SetSO(false)
+	if !cpu.Flags.HasOverflow() {
+		t.Fatalf("Falling edge of SO should set overflow")
+	}
+	cpu.Flags.SetOverflow(false)
+	cpu.SetSO(false)
+	if cpu.Flags.HasOverflow() {
+		t.Fatalf("Low level of SO shouldn't set overflow again")
+	}
+
+	cpu.Memory.Set(0x0200, OpLDA_absolute, 0x00, 0x30)
+	cpu.PC = 0x0200
+	cpu.SetSO(true)
+	cpu.Tick()
+	cpu.SetSO(false)
+	for !cpu.Tick() {
+	}
+	if !cpu.Flags.HasOverflow() {
+		t.Fatalf("SO should set overflow during instruction run by Tick")
+	}
+}
+
+func TestRAMFill(t *testing.T) {
+	ram := NewRAM(0x1000, 0x100)
+	ram.Fill(FillPattern(0x00, 0xFF, 0x55))
+	if ram.Get(0x1000) != 0x00 || ram.Get(0x1001) != 0xFF || ram.Get(0x10FF) != 0x00 {
+		t.Fatalf("Pattern should repeat from start of RAM. Expected %x %x %x, got %x %x %x",
+			0x00, 0xFF, 0x00, ram.Get(0x1000), ram.Get(0x1001), ram.Get(0x10FF))
+	}
+
+	other := NewRAM(0, 0x100)
+	ram.Fill(FillRandom(42))
+	other.Fill(FillRandom(42))
+	data, _ := ram.MarshalBinary()
+	otherData, _ := other.MarshalBinary()
+	if string(data) != string(otherData) {
+		t.Fatalf("Random fill should be repeatable with the same seed")
+	}
+	ram.Fill(FillZeros)
+	if ram.Get(0x1042) != 0 {
+		t.Fatalf("Zero fill should clear RAM. Expected %x, got %x", 0, ram.Get(0x1042))
+	}
+}
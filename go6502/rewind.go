@@ -0,0 +1,161 @@
+package go6502
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// RewindKeyframeInterval is how often full snapshot is kept, other ones are deltas to previous snapshot.
+// It limits how many deltas have to be applied to restore any snapshot.
+const RewindKeyframeInterval = 60
+
+// Rewind keeps ring buffer of periodic snapshots (save states) of CPU and memory, so emulation
+// can go back in time. Snapshot is taken with Capture, e.g. once per frame, and the oldest ones
+// are dropped when buffer is full. Restore jumps back to any snapshot and the next Capture
+// discards snapshots newer than restored one, so emulation resumes from that point.
+type Rewind struct {
+	cpu       *CPU
+	snapshots []rewindSnapshot
+	start     int // index of the oldest snapshot, which is always a keyframe
+	count     int
+	newest    []byte // full state of the newest snapshot, delta of next snapshot is made against it
+	restored  int    // snapshot restored by Restore, counted back from the newest, or -1
+}
+
+type rewindSnapshot struct {
+	keyframe bool
+	data     []byte // full state for keyframes, otherwise compressed XOR with previous snapshot
+}
+
+// Len returns number of snapshots
+func (r *Rewind) Len() int {
+	return r.count
+}
+
+func (r *Rewind) snapshot(index int) *rewindSnapshot {
+	return &r.snapshots[(r.start+index)%len(r.snapshots)]
+}
+
+// Capture takes snapshot of current state. Like Save, it fails in the middle of instruction run by Tick.
+func (r *Rewind) Capture() error {
+	var state bytes.Buffer
+	if err := r.cpu.Save(&state); err != nil {
+		return err
+	}
+	if r.restored > 0 {
+		r.count -= r.restored
+		r.newest = r.state(r.count - 1)
+	}
+	r.restored = -1
+
+	if r.count == len(r.snapshots) {
+		r.dropOldest()
+	}
+	snapshot := rewindSnapshot{keyframe: r.count == 0, data: state.Bytes()}
+	if !snapshot.keyframe {
+		distance := 1
+		for !r.snapshot(r.count - distance).keyframe {
+			distance++
+		}
+		snapshot.keyframe = distance >= RewindKeyframeInterval
+	}
+	if !snapshot.keyframe {
+		snapshot.data = compressDelta(r.newest, state.Bytes())
+	}
+	*r.snapshot(r.count) = snapshot
+	r.count++
+	r.newest = state.Bytes()
+	return nil
+}
+
+// dropOldest removes the oldest snapshot, turning the next one into keyframe if needed
+func (r *Rewind) dropOldest() {
+	if r.count > 1 && !r.snapshot(1).keyframe {
+		next := r.snapshot(1)
+		next.data = applyDelta(r.snapshot(0).data, next.data)
+		next.keyframe = true
+	}
+	*r.snapshot(0) = rewindSnapshot{}
+	r.start = (r.start + 1) % len(r.snapshots)
+	r.count--
+}
+
+// state returns full state of snapshot, rebuilding it from the nearest keyframe
+func (r *Rewind) state(index int) []byte {
+	keyframe := index
+	for !r.snapshot(keyframe).keyframe {
+		keyframe--
+	}
+	state := r.snapshot(keyframe).data
+	for i := keyframe + 1; i <= index; i++ {
+		state = applyDelta(state, r.snapshot(i).data)
+	}
+	return state
+}
+
+// Restore loads snapshot taken back captures ago, 0 is the newest one. Snapshots newer
+// than restored one are kept until the next Capture, so it's possible to scrub back and forth.
+func (r *Rewind) Restore(back int) error {
+	if back < 0 || back >= r.count {
+		return fmt.Errorf("rewind: there are only %d snapshots", r.count)
+	}
+	if err := r.cpu.Load(bytes.NewReader(r.state(r.count - 1 - back))); err != nil {
+		return err
+	}
+	r.restored = back
+	return nil
+}
+
+/*
+Delta is XOR of two states of the same length, with runs of zeros (unchanged bytes) removed.
+It's a sequence of pairs: number of unchanged bytes and number of changed bytes (both uvarint),
+followed by XOR values of changed bytes.
+*/
+
+func compressDelta(previous []byte, current []byte) []byte {
+	var delta []byte
+	var number [binary.MaxVarintLen64]byte
+	for i := 0; i < len(current); {
+		unchanged := i
+		for i < len(current) && current[i] == previous[i] {
+			i++
+		}
+		changed := i
+		for i < len(current) && current[i] != previous[i] {
+			i++
+		}
+		delta = append(delta, number[:binary.PutUvarint(number[:], uint64(changed-unchanged))]...)
+		delta = append(delta, number[:binary.PutUvarint(number[:], uint64(i-changed))]...)
+		for j := changed; j < i; j++ {
+			delta = append(delta, current[j]^previous[j])
+		}
+	}
+	return delta
+}
+
+func applyDelta(previous []byte, delta []byte) []byte {
+	state := append([]byte(nil), previous...)
+	position := 0
+	reader := bytes.NewReader(delta)
+	for reader.Len() > 0 {
+		unchanged, _ := binary.ReadUvarint(reader)
+		changed, _ := binary.ReadUvarint(reader)
+		position += int(unchanged)
+		for i := 0; i < int(changed); i++ {
+			value, _ := reader.ReadByte()
+			state[position] ^= value
+			position++
+		}
+	}
+	return state
+}
+
+// NewRewind creates buffer for given number of snapshots
+func NewRewind(cpu *CPU, size int) *Rewind {
+	return &Rewind{
+		cpu:       cpu,
+		snapshots: make([]rewindSnapshot, size),
+		restored:  -1,
+	}
+}
@@ -0,0 +1,61 @@
+package go6502
+
+import "testing"
+
+func TestRewind(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	rewind := NewRewind(cpu, 100)
+	for i := 0; i < 150; i++ {
+		cpu.PC = uint16(i)
+		cpu.Memory.Set(0x1000+uint16(i), uint8(i))
+		if err := rewind.Capture(); err != nil {
+			t.Fatalf("Snapshot should be captured, got error: %v", err)
+		}
+	}
+	if rewind.Len() != 100 {
+		t.Fatalf("Only the newest snapshots should be kept. Expected %d, got %d", 100, rewind.Len())
+	}
+
+	if err := rewind.Restore(99); err != nil {
+		t.Fatalf("The oldest snapshot should be restored, got error: %v", err)
+	}
+	if cpu.PC != 50 || cpu.Memory.Get(0x1000+50) != 50 || cpu.Memory.Get(0x1000+51) != 0 {
+		t.Fatalf("State of the oldest snapshot should be restored. Expected PC %x, got %x", 50, cpu.PC)
+	}
+	if err := rewind.Restore(10); err != nil {
+		t.Fatalf("Newer snapshot should be restored after older one, got error: %v", err)
+	}
+	if cpu.PC != 139 || cpu.Memory.Get(0x1000+139) != 139 || cpu.Memory.Get(0x1000+140) != 0 {
+		t.Fatalf("State of snapshot should be restored. Expected PC %x, got %x", 139, cpu.PC)
+	}
+
+	// Resuming from restored point drops newer snapshots
+	cpu.PC = 0x1234
+	rewind.Capture()
+	if rewind.Len() != 91 {
+		t.Fatalf("Snapshots after restored one should be dropped. Expected %d, got %d", 91, rewind.Len())
+	}
+	rewind.Restore(1)
+	if cpu.PC != 139 {
+		t.Fatalf("Restored snapshot should precede resumed one. Expected PC %x, got %x", 139, cpu.PC)
+	}
+	if err := rewind.Restore(91); err == nil {
+		t.Fatalf("Restoring beyond the oldest snapshot should fail")
+	}
+}
+
+func TestRewindDelta(t *testing.T) {
+	previous := make([]byte, 1000)
+	current := make([]byte, 1000)
+	current[0], current[500], current[501], current[999] = 1, 2, 3, 4
+	delta := compressDelta(previous, current)
+	if len(delta) > 20 {
+		t.Fatalf("Delta should contain only changed bytes, got %d bytes", len(delta))
+	}
+	restored := applyDelta(previous, delta)
+	for i := range current {
+		if restored[i] != current[i] {
+			t.Fatalf("Delta should restore byte %d. Expected %x, got %x", i, current[i], restored[i])
+		}
+	}
+}
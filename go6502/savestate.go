@@ -0,0 +1,233 @@
+package go6502
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Snapshotter is implemented by memory map entries, which keep their state in save states.
+// Entries not implementing it (e.g. stateless I/O devices) are skipped.
+type Snapshotter interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+/*
+Save state format (little endian):
+	magic "GO6502SS", version (2 bytes)
+	A, X, Y, S, P (1 byte each), PC (2 bytes)
+	number of memory map entries (2 bytes)
+	run state, variant, decimal mode, flags (1 byte each), opcode (1 byte) and address (2 bytes)
+	of fault, cycles (8 bytes) and the last bus cycle (4 bytes)
+	for each entry: state length (4 bytes) and state of entry, empty if it's not Snapshotter
+Memory map of machine loading the state must have the same entries as when it was saved.
+*/
+
+const SaveStateVersion = 1
+
+var saveStateMagic = [8]byte{'G', 'O', '6', '5', '0', '2', 'S', 'S'}
+
+type saveStateHeader struct {
+	Magic   [8]byte
+	Version uint16
+	A       uint8
+	X       uint8
+	Y       uint8
+	S       uint8
+	P       uint8
+	PC      uint16
+	Entries uint16
+}
+
+// saveStateRun follows header
+type saveStateRun struct {
+	State        uint8
+	Variant      uint8
+	DecimalMode  uint8
+	Flags        uint8
+	FaultOpcode  uint8
+	FaultAddress uint16
+	Cycles       uint64
+	LastAddress  uint16
+	LastValue    uint8
+	LastWrite    bool
+}
+
+// runFlags lists CPU flags kept in Flags of saveStateRun, from bit 0
+func (cpu *CPU) runFlags() []*bool {
+	return []*bool{&cpu.Undocumented, &cpu.Strict, &cpu.resetting, &cpu.notReady, &cpu.soLow, &cpu.irqPending, &cpu.nmiPending}
+}
+
+func (cpu *CPU) saveRun() saveStateRun {
+	run := saveStateRun{
+		State:       uint8(cpu.State),
+		Variant:     uint8(cpu.Variant),
+		DecimalMode: uint8(cpu.DecimalMode),
+		Cycles:      cpu.Cycles,
+		LastAddress: cpu.bus.last.Address,
+		LastValue:   cpu.bus.last.Value,
+		LastWrite:   cpu.bus.last.Write,
+	}
+	for i, flag := range cpu.runFlags() {
+		if *flag {
+			run.Flags |= 1 << i
+		}
+	}
+	if fault, ok := cpu.Err.(*UndefinedOpcodeError); ok {
+		run.FaultOpcode, run.FaultAddress = fault.Opcode, fault.Address
+	}
+	return run
+}
+
+func (cpu *CPU) loadRun(run saveStateRun) {
+	cpu.State = RunState(run.State)
+	cpu.Err = nil
+	if cpu.State == Faulted {
+		cpu.Err = &UndefinedOpcodeError{Opcode: run.FaultOpcode, Address: run.FaultAddress}
+	}
+	cpu.Variant = Variant(run.Variant)
+	cpu.Port = nil
+	if cpu.Variant == MOS6510 {
+		cpu.Port = cpu.Memory.port6510()
+	}
+	cpu.DecimalMode = DecimalMode(run.DecimalMode)
+	for i, flag := range cpu.runFlags() {
+		*flag = run.Flags&(1<<i) != 0
+	}
+	cpu.Cycles = run.Cycles
+	cpu.bus.last = BusCycle{Address: run.LastAddress, Value: run.LastValue, Write: run.LastWrite}
+}
+
+// Save writes state of CPU registers, run state and all memory map entries.
+// It fails in the middle of instruction run by Tick, so it should be called when Busy is false.
+func (cpu *CPU) Save(w io.Writer) error {
+	if cpu.bus.active {
+		return fmt.Errorf("state: instruction run by Tick is in progress")
+	}
+	header := saveStateHeader{
+		Magic:   saveStateMagic,
+		Version: SaveStateVersion,
+		A:       cpu.A,
+		X:       cpu.X,
+		Y:       cpu.Y,
+		S:       cpu.S,
+		P:       cpu.Flags.Value(),
+		PC:      cpu.PC,
+		Entries: uint16(len(cpu.Memory.entries)),
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, cpu.saveRun()); err != nil {
+		return err
+	}
+	if err := saveEntries(w, cpu.Memory.entries); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	return nil
+}
+
+// saveEntries writes state length and state of every memory map entry
+func saveEntries(w io.Writer, entries []MemoryMapEntry) error {
+	for i, entry := range entries {
+		var state []byte
+		if snapshotter, ok := entry.(Snapshotter); ok {
+			var err error
+			if state, err = snapshotter.MarshalBinary(); err != nil {
+				return fmt.Errorf("memory entry %d: %w", i, err)
+			}
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(state))); err != nil {
+			return err
+		}
+		if _, err := w.Write(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readEntries reads states of count memory map entries written by saveEntries
+func readEntries(r io.Reader, count int) ([][]byte, error) {
+	states := make([][]byte, count)
+	for i := range states {
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, fmt.Errorf("memory entry %d: %w", i, err)
+		}
+		// Copying doesn't allocate whole corrupted length up front
+		var state bytes.Buffer
+		if _, err := io.CopyN(&state, r, int64(length)); err != nil {
+			return nil, fmt.Errorf("memory entry %d: %w", i, err)
+		}
+		states[i] = state.Bytes()
+	}
+	return states, nil
+}
+
+// restoreEntries loads states read by readEntries into memory map entries
+func restoreEntries(entries []MemoryMapEntry, states [][]byte) error {
+	for i, entry := range entries {
+		snapshotter, ok := entry.(Snapshotter)
+		if !ok {
+			if len(states[i]) != 0 {
+				return fmt.Errorf("memory entry %d doesn't keep state", i)
+			}
+			continue
+		}
+		if err := snapshotter.UnmarshalBinary(states[i]); err != nil {
+			return fmt.Errorf("memory entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Load restores state written by Save, including variant and options of CPU. If state of some
+// memory entry doesn't match, memory can be left partially restored. Recorded history is cleared,
+// as it doesn't lead to loaded state.
+func (cpu *CPU) Load(r io.Reader) error {
+	var header saveStateHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("state: can't read header: %w", err)
+	}
+	if header.Magic != saveStateMagic {
+		return fmt.Errorf("state: not a save state")
+	}
+	if header.Version > SaveStateVersion {
+		return fmt.Errorf("state: unsupported version %d", header.Version)
+	}
+	entries := cpu.Memory.entries
+	if int(header.Entries) != len(entries) {
+		return fmt.Errorf("state: saved with %d memory entries, but memory has %d", header.Entries, len(entries))
+	}
+	var run saveStateRun
+	if err := binary.Read(r, binary.LittleEndian, &run); err != nil {
+		return fmt.Errorf("state: can't read run state: %w", err)
+	}
+
+	states, err := readEntries(r, len(entries))
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	if err := restoreEntries(entries, states); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	cpu.A = header.A
+	cpu.X = header.X
+	cpu.Y = header.Y
+	cpu.S = header.S
+	cpu.Flags.SetValue(header.P)
+	cpu.PC = header.PC
+	// Instruction started by Tick is abandoned
+	cpu.bus.active = false
+	cpu.interrupting = 0
+	cpu.loadRun(run)
+	if cpu.History != nil {
+		cpu.History.Clear()
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package go6502
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/*
+Save state format of 65816 (little endian):
+	magic "GO65816S", version (2 bytes)
+	C, X, Y, S, D (2 bytes each), DBR, PBR, P (1 byte each), PC (2 bytes)
+	modes (1 byte, bits from 0: emulation, memory select, index select, IRQ pending, NMI pending), run state (1 byte)
+	for each of 256 banks: kind (1 byte), which is one of
+	  0 - missing bank
+	  1 - memory, followed by number of its map entries (2 bytes) and their states as in save state of CPU
+	  2 - mirror, followed by number of bank with the same memory (1 byte)
+Banks of machine loading the state must be mapped the same way as when it was saved.
+*/
+
+const SaveState65816Version = 1
+
+var saveState65816Magic = [8]byte{'G', 'O', '6', '5', '8', '1', '6', 'S'}
+
+type saveState65816Header struct {
+	Magic         [8]byte
+	Version       uint16
+	C, X, Y, S, D uint16
+	DBR, PBR, P   uint8
+	PC            uint16
+	Modes         uint8
+	State         uint8
+}
+
+const (
+	bankMissing = iota
+	bankMemory
+	bankMirror
+)
+
+// Save writes state of registers and memory of all banks, mirrored banks are written once
+func (cpu *CPU65816) Save(w io.Writer) error {
+	header := saveState65816Header{
+		Magic:   saveState65816Magic,
+		Version: SaveState65816Version,
+		C:       cpu.C,
+		X:       cpu.X,
+		Y:       cpu.Y,
+		S:       cpu.S,
+		D:       cpu.D,
+		DBR:     cpu.DBR,
+		PBR:     cpu.PBR,
+		P:       cpu.Flags.Value(),
+		PC:      cpu.PC,
+		State:   uint8(cpu.State),
+	}
+	for i, mode := range []bool{cpu.Emulation, cpu.MemorySelect, cpu.IndexSelect, cpu.irqPending, cpu.nmiPending} {
+		if mode {
+			header.Modes |= 1 << i
+		}
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	saved := map[*Memory]uint8{}
+	for bank, memory := range cpu.Memory.banks {
+		var err error
+		if mirrored, ok := saved[memory]; ok {
+			_, err = w.Write([]byte{bankMirror, mirrored})
+		} else if memory == nil {
+			_, err = w.Write([]byte{bankMissing})
+		} else {
+			saved[memory] = uint8(bank)
+			err = binary.Write(w, binary.LittleEndian, struct {
+				Kind    uint8
+				Entries uint16
+			}{bankMemory, uint16(len(memory.entries))})
+			if err == nil {
+				err = saveEntries(w, memory.entries)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("state: bank %02X: %w", bank, err)
+		}
+	}
+	return nil
+}
+
+// Load restores state written by Save. Memory is changed only when all banks match the state.
+func (cpu *CPU65816) Load(r io.Reader) error {
+	var header saveState65816Header
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("state: can't read header: %w", err)
+	}
+	if header.Magic != saveState65816Magic {
+		return fmt.Errorf("state: not a save state of 65816")
+	}
+	if header.Version > SaveState65816Version {
+		return fmt.Errorf("state: unsupported version %d", header.Version)
+	}
+
+	states := make([][][]byte, len(cpu.Memory.banks))
+	for bank, memory := range cpu.Memory.banks {
+		if err := cpu.readBank(r, uint8(bank), memory, &states[bank]); err != nil {
+			return fmt.Errorf("state: bank %02X: %w", bank, err)
+		}
+	}
+	for bank, memory := range cpu.Memory.banks {
+		if states[bank] == nil {
+			continue
+		}
+		if err := restoreEntries(memory.entries, states[bank]); err != nil {
+			return fmt.Errorf("state: bank %02X: %w", bank, err)
+		}
+	}
+
+	cpu.C, cpu.X, cpu.Y, cpu.S, cpu.D = header.C, header.X, header.Y, header.S, header.D
+	cpu.DBR, cpu.PBR, cpu.PC = header.DBR, header.PBR, header.PC
+	cpu.Flags.SetValue(header.P)
+	for i, mode := range []*bool{&cpu.Emulation, &cpu.MemorySelect, &cpu.IndexSelect, &cpu.irqPending, &cpu.nmiPending} {
+		*mode = header.Modes&(1<<i) != 0
+	}
+	cpu.State = RunState(header.State)
+	return nil
+}
+
+// readBank checks that bank is mapped as in state and reads states of its entries, unless it's mirror
+func (cpu *CPU65816) readBank(r io.Reader, bank uint8, memory *Memory, states *[][]byte) error {
+	var kind [1]byte
+	if _, err := io.ReadFull(r, kind[:]); err != nil {
+		return err
+	}
+	switch kind[0] {
+	case bankMissing:
+		if memory != nil {
+			return fmt.Errorf("saved as missing, but memory is mapped")
+		}
+	case bankMirror:
+		var mirrored [1]byte
+		if _, err := io.ReadFull(r, mirrored[:]); err != nil {
+			return err
+		}
+		if mirrored[0] >= bank || memory == nil || cpu.Memory.banks[mirrored[0]] != memory {
+			return fmt.Errorf("saved as mirror of bank %02X, which it isn't", mirrored[0])
+		}
+	case bankMemory:
+		var entries uint16
+		if err := binary.Read(r, binary.LittleEndian, &entries); err != nil {
+			return err
+		}
+		if memory == nil {
+			return fmt.Errorf("saved with memory, but bank is missing")
+		}
+		for earlier := 0; earlier < int(bank); earlier++ {
+			if cpu.Memory.banks[earlier] == memory {
+				return fmt.Errorf("saved with memory, but bank mirrors bank %02X", earlier)
+			}
+		}
+		if int(entries) != len(memory.entries) {
+			return fmt.Errorf("saved with %d memory entries, but memory has %d", entries, len(memory.entries))
+		}
+		var err error
+		*states, err = readEntries(r, len(memory.entries))
+		return err
+	default:
+		return fmt.Errorf("unknown kind %d", kind[0])
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package go6502
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newSaveStateCPU() (*CPU, *Screen) {
+	screen := NewScreen(0xD000)
+	return NewCPU(NewMemory(NewRAM(0x0000, 0xD000), screen, NewRAM(0xFF00, 0x0100))), screen
+}
+
+func TestSaveAndLoadState(t *testing.T) {
+	cpu, screen := newSaveStateCPU()
+	cpu.A, cpu.X, cpu.Y, cpu.S, cpu.PC = 0x01, 0x02, 0x03, 0xF0, 0x1234
+	cpu.Flags.SetCarry(true)
+	cpu.Memory.Set(0x0200, 0x42)
+	cpu.Memory.Set(0xFFFC, 0x00, 0x02)
+	screen.SetMapping(8, 0, 0xE0, 0x03)
+	screen.Set(0xD960, 0xAA)
+
+	var state bytes.Buffer
+	if err := cpu.Save(&state); err != nil {
+		t.Fatalf("State should be saved, got error: %v", err)
+	}
+
+	restored, restoredScreen := newSaveStateCPU()
+	if err := restored.Load(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatalf("State should be loaded, got error: %v", err)
+	}
+	if restored.A != 0x01 || restored.X != 0x02 || restored.Y != 0x03 || restored.S != 0xF0 || restored.PC != 0x1234 || !restored.Flags.HasCarry() {
+		t.Fatalf("Registers should be restored, got %v", restored)
+	}
+	if restored.Memory.Get(0x0200) != 0x42 || restored.Memory.Get(0xFFFD) != 0x02 {
+		t.Fatalf("RAM should be restored. Expected %x, got %x", 0x42, restored.Memory.Get(0x0200))
+	}
+	if fg, bg := restoredScreen.getColorMappings(8, 0); fg != 0xE0 || bg != 0x03 {
+		t.Fatalf("Screen color mappings should be restored. Expected %x %x, got %x %x", 0xE0, 0x03, fg, bg)
+	}
+	if restoredScreen.Get(0xD960) != 0xAA {
+		t.Fatalf("Screen pixels should be restored. Expected %x, got %x", 0xAA, restoredScreen.Get(0xD960))
+	}
+}
+
+func TestLoadInvalidState(t *testing.T) {
+	cpu, _ := newSaveStateCPU()
+	var state bytes.Buffer
+	cpu.Save(&state)
+
+	other := NewDefaultMemoryCPU()
+	if err := other.Load(bytes.NewReader(state.Bytes())); err == nil || !strings.Contains(err.Error(), "memory entries") {
+		t.Fatalf("State of different memory map shouldn't be loaded, got %v", err)
+	}
+	data := append([]byte(nil), state.Bytes()...)
+	data[0] = 'X'
+	if err := cpu.Load(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "not a save state") {
+		t.Fatalf("Data without magic shouldn't be loaded, got %v", err)
+	}
+	data = append([]byte(nil), state.Bytes()...)
+	data[8] = SaveStateVersion + 1
+	if err := cpu.Load(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Fatalf("Newer version shouldn't be loaded, got %v", err)
+	}
+	if err := cpu.Load(bytes.NewReader(state.Bytes()[:100])); err == nil {
+		t.Fatalf("Truncated state shouldn't be loaded")
+	}
+}
+
+func TestSaveRunState(t *testing.T) {
+	cpu := NewDefaultMemoryCPU(WithVariant(WDC65C02), WithStrict())
+	cpu.Memory.Set(0x0200, OpINX, 0x03)
+	cpu.PC = 0x0200
+	cpu.Tick()
+	cpu.Tick()
+	cpu.Advance()
+	cpu.SetSO(false)
+	cpu.SetRDY(false)
+	// Halted CPU ignores NMI
+	cpu.nmiPending = true
+	var state bytes.Buffer
+	if err := cpu.Save(&state); err != nil {
+		t.Fatalf("State should be saved, got error: %v", err)
+	}
+
+	restored := NewDefaultMemoryCPU()
+	if err := restored.Load(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatalf("State should be loaded, got error: %v", err)
+	}
+	if restored.State != Faulted || restored.Err == nil || restored.Err.Error() != cpu.Err.Error() {
+		t.Fatalf("Fault should be restored. Expected %v (%v), got %v (%v)", cpu.State, cpu.Err, restored.State, restored.Err)
+	}
+	if restored.Variant != WDC65C02 || restored.DecimalMode != DecimalCMOS || !restored.Strict || restored.Undocumented {
+		t.Fatalf("Variant and options should be restored, got %v %v", restored.Variant, restored.DecimalMode)
+	}
+	if restored.Cycles != 2 || restored.LastCycle() != cpu.LastCycle() {
+		t.Fatalf("Cycles should be restored. Expected %d, got %d", 2, restored.Cycles)
+	}
+	if !restored.notReady || !restored.soLow || !restored.nmiPending || restored.irqPending || restored.resetting {
+		t.Fatalf("Inputs and pending interrupts should be restored")
+	}
+}
+
+func TestSaveInProgress(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpLDA_absolute, 0x00, 0x10)
+	cpu.PC = 0x0200
+	cpu.Tick()
+	var state bytes.Buffer
+	if err := cpu.Save(&state); err == nil {
+		t.Fatalf("State shouldn't be saved in the middle of instruction")
+	}
+	rewind := NewRewind(cpu, 10)
+	if err := rewind.Capture(); err == nil {
+		t.Fatalf("Snapshot shouldn't be captured in the middle of instruction")
+	}
+	for !cpu.Tick() {
+	}
+	if err := rewind.Capture(); err != nil {
+		t.Fatalf("Snapshot should be captured after instruction, got error: %v", err)
+	}
+}
+
+func TestSaveAndLoad65816(t *testing.T) {
+	newMachine := func() *CPU65816 {
+		mirrored := DefaultMemory()
+		return NewCPU65816(NewMemory24(DefaultMemory(), mirrored, mirrored))
+	}
+	cpu := newMachine()
+	cpu.SetEmulation(false)
+	cpu.SetP(IndexSelectBit | 0x01)
+	cpu.C, cpu.X, cpu.D, cpu.DBR, cpu.PBR, cpu.PC = 0x1234, 0x56, 0x0300, 0x01, 0x02, 0x4000
+	cpu.Memory.Set(0x010000, 0x42)
+	cpu.Memory.Set(0x0200, 0x99)
+	cpu.NMI()
+	var state bytes.Buffer
+	if err := cpu.Save(&state); err != nil {
+		t.Fatalf("State should be saved, got error: %v", err)
+	}
+
+	restored := newMachine()
+	if err := restored.Load(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatalf("State should be loaded, got error: %v", err)
+	}
+	if restored.String() != cpu.String() || restored.Emulation || restored.MemorySelect || !restored.IndexSelect ||
+		!restored.nmiPending {
+		t.Fatalf("Registers and modes should be restored. Expected\n%v\ngot\n%v", cpu, restored)
+	}
+	if restored.Memory.Get(0x020000) != 0x42 || restored.Memory.Get(0x0200) != 0x99 {
+		t.Fatalf("Banks should be restored. Expected %x %x, got %x %x",
+			0x42, 0x99, restored.Memory.Get(0x020000), restored.Memory.Get(0x0200))
+	}
+
+	other := NewCPU65816(NewMemory24(DefaultMemory(), DefaultMemory(), DefaultMemory()))
+	if err := other.Load(bytes.NewReader(state.Bytes())); err == nil || !strings.Contains(err.Error(), "mirror") {
+		t.Fatalf("State of differently mirrored banks shouldn't be loaded, got %v", err)
+	}
+}
@@ -1,8 +1,7 @@
 package go6502
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"fmt"
 	"image/color"
 )
 
@@ -18,12 +17,17 @@ const (
 
 	ColorMappingsBytes = 2 * (ScreenWidth * ScreenHeight) / (BlockWidth * BlockHeight) // 2400b
 	PixelColorBytes    = ScreenWidth * ScreenHeight / PixelsPerByte                    // 9600b
+
+	blocks      = ColorMappingsBytes / 2
+	bytesInLine = ScreenWidth / PixelsPerByte
 )
 
 type Screen struct {
 	colorMappings []uint8
 	pixels        []uint8
 	addressStart  uint16
+	// dirty marks blocks changed since the last RenderDirty
+	dirty []bool
 }
 
 /*
@@ -45,19 +49,53 @@ func (s *Screen) Get(address uint16) uint8 {
 	}
 }
 
+func (s *Screen) peek(address uint16) uint8 {
+	return s.Get(address)
+}
+
 func (s *Screen) Set(address uint16, value uint8) {
 	internalAddress := address - s.addressStart
 	if internalAddress < ColorMappingsBytes {
-		s.colorMappings[internalAddress] = value
+		if s.colorMappings[internalAddress] != value {
+			s.colorMappings[internalAddress] = value
+			s.dirty[internalAddress/2] = true
+		}
 	} else {
-		s.pixels[internalAddress-ColorMappingsBytes] = value
+		byteNumber := int(internalAddress - ColorMappingsBytes)
+		if s.pixels[byteNumber] != value {
+			s.pixels[byteNumber] = value
+			// Byte of pixels is one line of block
+			s.dirty[byteNumber/bytesInLine/BlockHeight*BlocksInLine+byteNumber%bytesInLine] = true
+		}
+	}
+}
+
+// MarshalBinary returns color mappings followed by pixels
+func (s *Screen) MarshalBinary() ([]byte, error) {
+	return append(append([]uint8(nil), s.colorMappings...), s.pixels...), nil
+}
+
+func (s *Screen) UnmarshalBinary(data []byte) error {
+	if len(data) != ColorMappingsBytes+PixelColorBytes {
+		return fmt.Errorf("screen: state has %d bytes, expected %d", len(data), ColorMappingsBytes+PixelColorBytes)
 	}
+	copy(s.colorMappings, data[:ColorMappingsBytes])
+	copy(s.pixels, data[ColorMappingsBytes:])
+	s.markDirty()
+	return nil
 }
 
 func (s *Screen) SetMapping(x int, y int, fg uint8, bg uint8) {
 	blockNumber := s.getBlockNumber(x, y)
 	s.colorMappings[blockNumber*2] = fg
 	s.colorMappings[blockNumber*2+1] = bg
+	s.dirty[blockNumber] = true
+}
+
+func (s *Screen) markDirty() {
+	for i := range s.dirty {
+		s.dirty[i] = true
+	}
 }
 
 func (s *Screen) getColorMappings(x, y int) (uint8, uint8) {
@@ -74,52 +112,29 @@ func (s *Screen) getBlockNumber(x int, y int) int {
 
 func (s *Screen) GetPixels(x, y int) uint8 {
 	byteInRow := x / PixelsPerByte
-	byteNumber := y*(ScreenWidth/PixelsPerByte) + byteInRow
+	byteNumber := y*bytesInLine + byteInRow
 	return s.pixels[byteNumber]
 }
 
-func (s *Screen) Update() error {
-	return nil
-}
-
-func (s *Screen) Draw(screen *ebiten.Image) {
-	for blockStartY := 0; blockStartY < ScreenHeight; blockStartY += BlockHeight {
-		for blockStartX := 0; blockStartX < ScreenWidth; blockStartX += BlockWidth {
-			s.drawBlock(screen, blockStartX, blockStartY)
-		}
-	}
-}
-
-func (s *Screen) drawBlock(screen *ebiten.Image, blockStartX int, blockStartY int) {
-	colorFg, colorBg := s.getColorMappings(blockStartX, blockStartY)
-	for line := 0; line < BlockHeight; line++ {
-		lineStartY := blockStartY + line
-		linePixels := s.GetPixels(blockStartX, lineStartY)
-		s.drawLine(screen, blockStartX, lineStartY, linePixels, colorFg, colorBg)
+// PixelColor returns color of pixel, taking into account color mapping of its block
+func (s *Screen) PixelColor(x, y int) color.RGBA {
+	colorFg, colorBg := s.getColorMappings(x, y)
+	pixel := s.GetPixels(x, y) & (1 << (x % PixelsPerByte))
+	if pixel == 0 {
+		return mapColor(colorBg)
 	}
-}
-
-func (s *Screen) drawLine(screen *ebiten.Image, lineStartX int, y int, pixels uint8, fg uint8, bg uint8) {
-	for b := 0; b < PixelsPerByte; b++ {
-		pixel := pixels & (1 << b)
-		pixelColor := fg
-		if pixel == 0 {
-			pixelColor = bg
-		}
-		ebitenutil.DrawRect(screen, float64(lineStartX+b), float64(y), 1, 1, mapColor(pixelColor))
-	}
-}
-
-func (s *Screen) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return ScreenWidth, ScreenHeight
+	return mapColor(colorFg)
 }
 
 func NewScreen(addressStart uint16) *Screen {
-	return &Screen{
+	s := &Screen{
 		colorMappings: make([]uint8, ColorMappingsBytes),
 		pixels:        make([]uint8, PixelColorBytes),
 		addressStart:  addressStart,
+		dirty:         make([]bool, blocks),
 	}
+	s.markDirty()
+	return s
 }
 
 var (
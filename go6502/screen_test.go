@@ -33,3 +33,13 @@ func assertColor(t *testing.T, rgba color.RGBA, r uint8, g uint8, b uint8) {
 		t.Fatalf("Alpha component is wrong. Expected: %v, got: %v", 255, rgba.A)
 	}
 }
+
+func TestPixelColor(t *testing.T) {
+	screen := NewScreen(0xD000)
+	screen.SetMapping(8, 0, 0b11100000, 0b00000011) // Second block: red foreground, blue background
+	screen.Set(0xD000+ColorMappingsBytes+1, 0b00000100)
+
+	assertColor(t, screen.PixelColor(10, 0), 0xFF, 0x00, 0x00)
+	assertColor(t, screen.PixelColor(11, 0), 0x00, 0x00, 0xFF)
+	assertColor(t, screen.PixelColor(0, 0), 0x00, 0x00, 0x00)
+}
@@ -0,0 +1,182 @@
+package go6502
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+Single step tests (https://github.com/SingleStepTests/65x02) describe one instruction per case:
+	{"name": "a9 42 00",
+	 "initial": {"pc": 1024, "s": 253, "a": 0, "x": 0, "y": 0, "p": 36, "ram": [[1024, 169], [1025, 66]]},
+	 "final": {...same fields after instruction...},
+	 "cycles": [[1024, 169, "read"], ...]}
+Files of 6502/v1 (one per opcode, e.g. a9.json) can be copied to testdata/singlestep, which
+already has cases of all stable opcodes written from 64doc bus cycle tables (opcodes.json)
+and a few hand written ones (sample.json), see testdata/README.md. Cases of opcodes,
+which aren't defined in NMOS6502Undocumented, and JAM are skipped.
+Instructions are run by Tick, so number of cycles and bus cycles are compared too.
+*/
+
+// Failed cases reported per file, the rest of them usually fail for the same reason
+const singleStepMaxFailures = 10
+
+type singleStepState struct {
+	PC  uint16     `json:"pc"`
+	S   uint8      `json:"s"`
+	A   uint8      `json:"a"`
+	X   uint8      `json:"x"`
+	Y   uint8      `json:"y"`
+	P   uint8      `json:"p"`
+	RAM [][2]int32 `json:"ram"`
+}
+
+type singleStepCase struct {
+	Name    string          `json:"name"`
+	Initial singleStepState `json:"initial"`
+	Final   singleStepState `json:"final"`
+	Cycles  [][3]any        `json:"cycles"`
+}
+
+func (state *singleStepState) apply(cpu *CPU) {
+	cpu.PC, cpu.S, cpu.A, cpu.X, cpu.Y = state.PC, state.S, state.A, state.X, state.Y
+	cpu.Flags.SetValue(state.P)
+	for _, cell := range state.RAM {
+		cpu.Memory.Set(uint16(cell[0]), uint8(cell[1]))
+	}
+}
+
+func flagsString(value uint8) string {
+	flags := Flags{val: value}
+	return fmt.Sprintf("%02X (%v)", value, &flags)
+}
+
+// diff lists differences between expected state and state of CPU, one per line
+func (state *singleStepState) diff(cpu *CPU) string {
+	var lines []string
+	compare := func(name string, expected string, actual string) {
+		if expected != actual {
+			lines = append(lines, fmt.Sprintf("    %-6s expected %s, got %s", name, expected, actual))
+		}
+	}
+	compare("PC", fmt.Sprintf("%04X", state.PC), fmt.Sprintf("%04X", cpu.PC))
+	compare("S", fmt.Sprintf("%02X", state.S), fmt.Sprintf("%02X", cpu.S))
+	compare("A", fmt.Sprintf("%02X", state.A), fmt.Sprintf("%02X", cpu.A))
+	compare("X", fmt.Sprintf("%02X", state.X), fmt.Sprintf("%02X", cpu.X))
+	compare("Y", fmt.Sprintf("%02X", state.Y), fmt.Sprintf("%02X", cpu.Y))
+	// Break and unused bits aren't part of the register, vectors may have any value of them
+	compare("P", flagsString(state.P&^BreakBit|UnusedBit), flagsString(cpu.Flags.Value()))
+	for _, cell := range state.RAM {
+		address := uint16(cell[0])
+		compare(fmt.Sprintf("$%04X", address), fmt.Sprintf("%02X", cell[1]), fmt.Sprintf("%02X", cpu.Memory.Get(address)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatCycle(address uint16, value uint8, write bool) string {
+	if write {
+		return fmt.Sprintf("$%04X %02X write", address, value)
+	}
+	return fmt.Sprintf("$%04X %02X read", address, value)
+}
+
+// diffCycles lists expected and actual bus cycles, if they don't match
+func (c *singleStepCase) diffCycles(cycles []BusCycle) string {
+	var expected, actual []string
+	for _, cycle := range c.Cycles {
+		address, _ := cycle[0].(float64)
+		value, _ := cycle[1].(float64)
+		expected = append(expected, formatCycle(uint16(address), uint8(value), cycle[2] == "write"))
+	}
+	for _, cycle := range cycles {
+		actual = append(actual, formatCycle(cycle.Address, cycle.Value, cycle.Write))
+	}
+	if strings.Join(expected, ", ") == strings.Join(actual, ", ") {
+		return ""
+	}
+	diff := fmt.Sprintf("    cycles expected %s\n           got %s", strings.Join(expected, ", "), strings.Join(actual, ", "))
+	if len(expected) != len(actual) {
+		diff = fmt.Sprintf("    cycle count expected %d, got %d\n", len(expected), len(actual)) + diff
+	}
+	return diff
+}
+
+func runSingleStepFile(t *testing.T, file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Can't read test vectors: %v", err)
+	}
+	var cases []singleStepCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("Can't parse test vectors: %v", err)
+	}
+	cpu := NewCPU(NewMemory(NewRAM(0, 0x10000)), WithUndocumented())
+	failures := 0
+	for _, c := range cases {
+		c.Initial.apply(cpu)
+		// JAM never finishes, so its vectors describe state of locked bus
+		if instruction := NMOS6502Undocumented[cpu.Memory.Get(cpu.PC)]; !instruction.Defined() || instruction.Mnemonic == "JAM" {
+			continue
+		}
+		var cycles []BusCycle
+		for done := false; !done; {
+			done = cpu.Tick()
+			cycles = append(cycles, cpu.LastCycle())
+		}
+		diff := c.Final.diff(cpu)
+		if cyclesDiff := c.diffCycles(cycles); cyclesDiff != "" {
+			diff = strings.TrimPrefix(diff+"\n"+cyclesDiff, "\n")
+		}
+		if diff != "" {
+			t.Errorf("%s:\n%s", c.Name, diff)
+			if failures++; failures == singleStepMaxFailures {
+				t.Fatalf("Too many failures, skipping rest of %s", filepath.Base(file))
+			}
+		}
+	}
+}
+
+func TestSingleStep(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "singlestep", "*.json"))
+	if len(files) == 0 {
+		t.Fatalf("There are no single step test vectors in testdata/singlestep")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			runSingleStepFile(t, file)
+		})
+	}
+}
+
+func TestSingleStepDiff(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	state := singleStepState{PC: 0x0200, A: 0x42, P: 0x80, RAM: [][2]int32{{0x0300, 0x12}}}
+	state.apply(cpu)
+	if diff := state.diff(cpu); diff != "" {
+		t.Fatalf("Applied state should match. Expected no diff, got\n%s", diff)
+	}
+	cpu.A = 0x43
+	cpu.Flags.SetZero(true)
+	cpu.Memory.Set(0x0300, 0x13)
+	expected := "    A      expected 42, got 43\n" +
+		"    P      expected A0 (Nv1bdizc), got A2 (Nv1bdiZc)\n" +
+		"    $0300  expected 12, got 13"
+	if diff := state.diff(cpu); diff != expected {
+		t.Fatalf("Diff should list changed values. Expected\n%s\ngot\n%s", expected, diff)
+	}
+}
+
+func TestSingleStepCycleCount(t *testing.T) {
+	c := singleStepCase{Cycles: [][3]any{{float64(0x0200), float64(OpINX), "read"}, {float64(0x0201), float64(0), "read"}}}
+	if diff := c.diffCycles([]BusCycle{{Address: 0x0200, Value: OpINX}}); !strings.HasPrefix(diff, "    cycle count expected 2, got 1\n") {
+		t.Fatalf("Different number of cycles should be reported, got\n%s", diff)
+	}
+	if diff := c.diffCycles([]BusCycle{{Address: 0x0200, Value: OpINX}, {Address: 0x0201}}); diff != "" {
+		t.Fatalf("Matching cycles shouldn't be reported, got\n%s", diff)
+	}
+}
@@ -0,0 +1,922 @@
+; Functional test of documented NMOS 6502 instructions
+;
+; Written for go6502 after Klaus Dormann's 6502 functional test, whose image isn't
+; in testdata. Every check branches or jumps to itself when it fails (trap), so the
+; failing check is found by PC in listing, and number of current test is kept in
+; TESTNUM. Table driven tests keep index of current entry in ENTRY and addressing
+; mode in MODE. The test ends by jumping to itself at SUCCESS.
+;
+; Expected results in tables were computed by a model written independently from
+; go6502, with NMOS 6502 flags of decimal mode as predicted by Bruce Clark's tutorial
+; (http://www.6502.org/tutorials/decimal_mode.html).
+;
+; Assemble with: go6502 asm -l functional_test.lst functional_test.s
+; The image is loaded and started at $0400.
+
+TESTNUM = $0200
+
+; Workspace of table driven tests
+AIN	= $40		; accumulator before instruction
+OPD	= $41		; operand
+PIN	= $42		; flags before instruction
+AOUT	= $43		; expected accumulator or memory result
+POUT	= $44		; expected flags as pushed by PHP, with break and unused bits
+GRP	= $45		; opcode of instruction group in zero page (RMW) or (zp,X) mode (ALU)
+MODE	= $46		; index of addressing mode
+ENTRY	= $47		; offset of the next table entry
+COUNT	= $48		; number of table entries left
+FIRST	= $49		; index of the first addressing mode
+TPTR	= $4A		; pointer to table, 2 bytes
+
+; Operand is in ZPOPD or ABSOPD, the rest of modes reach them with index 3
+ZPOPD	= $10
+ZPBASE	= ZPOPD-3
+PTRX	= $20		; ($20,X) points to ABSOPD through $23
+PTRY	= $26		; ($26),Y points to ABSOPD through $26, crossing page
+ABSOPD	= $0301
+ABSBASE	= ABSOPD-3
+
+	.org $0400
+start:	cld
+	ldx #$FF
+	txs
+	lda #<ABSOPD
+	sta PTRX+3
+	lda #>ABSOPD
+	sta PTRX+4
+	lda #<ABSBASE
+	sta PTRY
+	lda #>ABSBASE
+	sta PTRY+1
+
+; Test 1: branches and status register
+test1:	lda #1
+	sta TESTNUM
+	lda #0
+	pha
+	plp
+	bmi *
+	bvs *
+	bcs *
+	beq *
+	bpl *+5
+	jmp *
+	bvc *+5
+	jmp *
+	bcc *+5
+	jmp *
+	bne *+5
+	jmp *
+	php
+	pla
+	cmp #$30		; break and unused bits are set by PHP
+	bne *
+	lda #$FF
+	pha
+	plp
+	bpl *
+	bvc *
+	bcc *
+	bne *
+	bmi *+5
+	jmp *
+	bvs *+5
+	jmp *
+	bcs *+5
+	jmp *
+	beq *+5
+	jmp *
+	php
+	pla
+	cmp #$FF
+	bne *
+
+; Test 2: flag instructions
+test2:	lda #2
+	sta TESTNUM
+	lda #0
+	pha
+	plp
+	sec
+	sed
+	sei
+	php
+	pla
+	cmp #$3D
+	bne *
+	lda #$FF
+	pha
+	plp
+	clc
+	cld
+	cli
+	clv
+	php
+	pla
+	cmp #$B2
+	bne *
+
+; Test 3: stack
+test3:	lda #3
+	sta TESTNUM
+	ldx #$80
+	txs
+	tsx
+	cpx #$80
+	bne *
+	lda #$42
+	pha
+	tsx
+	cpx #$7F
+	bne *
+	lda $0180
+	cmp #$42
+	bne *
+	lda #$00
+	pha
+	lda #$FF		; PLA sets N and Z by pulled value
+	pla
+	bne *
+	pla
+	bmi *
+	cmp #$42
+	bne *
+	tsx
+	cpx #$80
+	bne *
+	lda #$C3
+	pha
+	plp
+	php
+	pla
+	cmp #$F3
+	bne *
+	ldx #$00		; stack pointer wraps within page 1
+	txs
+	pla
+	tsx
+	cpx #$01
+	bne *
+	lda #$5A
+	pha
+	pha
+	tsx
+	cpx #$FF
+	bne *
+	lda $0100
+	cmp #$5A
+	bne *
+	ldx #$FF
+	txs
+
+; Test 4: transfers, increments and decrements of registers
+test4:	lda #4
+	sta TESTNUM
+	lda #0
+	pha
+	plp
+	lda #$80
+	tax
+	bpl *
+	cpx #$80
+	bne *
+	lda #$00
+	tay
+	bne *
+	cpy #$00
+	bne *
+	ldx #$7F
+	txa
+	bmi *
+	cmp #$7F
+	bne *
+	ldy #$FF
+	tya
+	bpl *
+	cmp #$FF
+	bne *
+	ldx #$FF
+	inx
+	bne *
+	dex
+	bpl *
+	cpx #$FF
+	bne *
+	ldy #$7F
+	iny
+	bpl *
+	dey
+	bmi *
+	cpy #$7F
+	bne *
+	ldx #$00
+	lda #$FF		; TXS doesn't change flags
+	txs
+	beq *
+	tsx
+	bne *
+	ldx #$FF
+	txs
+
+; Test 5: loads and stores of X and Y in all addressing modes
+test5:	lda #5
+	sta TESTNUM
+	lda #$81
+	sta ZPOPD
+	sta ABSOPD
+	lda #$00
+	pha
+	plp
+	ldx #$81
+	bpl *
+	cpx #$81
+	bne *
+	ldy #0
+	bne *
+	ldx ZPOPD
+	cpx #$81
+	bne *
+	ldx ABSOPD
+	cpx #$81
+	bne *
+	ldy #3
+	ldx ZPBASE,y
+	cpx #$81
+	bne *
+	ldx ABSBASE,y
+	cpx #$81
+	bne *
+	ldy ZPOPD
+	cpy #$81
+	bne *
+	ldy ABSOPD
+	cpy #$81
+	bne *
+	ldx #3
+	ldy ZPBASE,x
+	cpy #$81
+	bne *
+	ldy ABSBASE,x
+	cpy #$81
+	bne *
+	ldx #$00
+	stx ZPOPD
+	stx ABSOPD
+	ldx ZPOPD
+	bne *
+	ldx ABSOPD
+	bne *
+	ldy #$3C
+	sty ZPOPD
+	sty ABSOPD
+	lda ZPOPD
+	cmp #$3C
+	bne *
+	lda ABSOPD
+	cmp #$3C
+	bne *
+	ldy #3
+	ldx #$C3
+	stx ZPBASE,y
+	lda ZPOPD
+	cmp #$C3
+	bne *
+	ldx #3
+	ldy #$7E
+	sty ZPBASE,x
+	lda ZPOPD
+	cmp #$7E
+	bne *
+	ldx #$00		; stores don't change flags
+	ldy #$00
+	lda #$00
+	pha
+	plp
+	stx ZPOPD
+	sty ABSOPD
+	php
+	pla
+	cmp #$30
+	bne *
+
+; Test 6: CPX and CPY in all addressing modes
+test6:	lda #6
+	sta TESTNUM
+	lda #$42
+	sta ZPOPD
+	sta ABSOPD
+	lda #$00
+	pha
+	plp
+	ldx #$42
+	cpx #$42
+	php
+	cpx ZPOPD
+	php
+	cpx ABSOPD
+	php
+	ldx #$41
+	cpx #$42
+	php
+	cpx ZPOPD
+	php
+	cpx ABSOPD
+	php
+	ldy #$43
+	cpy #$42
+	php
+	cpy ZPOPD
+	php
+	cpy ABSOPD
+	php
+	ldy #$C0
+	cpy #$42
+	php
+	cpy ZPOPD
+	php
+	cpy ABSOPD
+	php
+	ldx #3			; flags are pulled in reverse order, Y=$C0 and $43 set only C
+@cpyc:	pla
+	cmp #$31
+	bne *
+	dex
+	bne @cpyc
+	ldx #3
+@cpy:	pla
+	cmp #$31
+	bne *
+	dex
+	bne @cpy
+	ldx #3
+@cpxn:	pla
+	cmp #$B0
+	bne *
+	dex
+	bne @cpxn
+	ldx #3
+@cpxz:	pla
+	cmp #$33
+	bne *
+	dex
+	bne @cpxz
+
+; Test 7: zero page indexing and pointers wrap within zero page
+test7:	lda #7
+	sta TESTNUM
+	lda #$11
+	sta $02
+	lda #$22
+	sta $0102
+	sta $0302
+	ldx #3
+	lda $FF,x
+	cmp #$11
+	bne *
+	lda $02FF,x		; absolute indexing carries to the next page
+	cmp #$22
+	bne *
+	lda #$02
+	sta $FF
+	lda #$01
+	sta $00
+	lda #$33
+	sta $0102
+	ldx #3
+	lda ($FC,x)		; pointer at $FF, high byte at $00
+	cmp #$33
+	bne *
+	ldy #0
+	lda ($FF),y
+	cmp #$33
+	bne *
+	lda #$00
+	sta $00
+	sta $FF
+
+; Test 8: ALU instructions in all addressing modes
+test8:	lda #8
+	sta TESTNUM
+	lda #$01
+	ldx #<tabora
+	ldy #>tabora
+	jsr alu5
+	lda #$21
+	ldx #<taband
+	ldy #>taband
+	jsr alu5
+	lda #$41
+	ldx #<tabeor
+	ldy #>tabeor
+	jsr alu5
+	lda #$A1
+	ldx #<tablda
+	ldy #>tablda
+	jsr alu4
+	lda #$C1
+	ldx #<tabcmp
+	ldy #>tabcmp
+	jsr alu5
+
+; Test 9: binary ADC and SBC in all addressing modes
+test9:	lda #9
+	sta TESTNUM
+	lda #$61
+	ldx #<tabadc
+	ldy #>tabadc
+	jsr alu9
+	lda #$E1
+	ldx #<tabsbc
+	ldy #>tabsbc
+	jsr alu8
+
+; Test 10: decimal ADC and SBC of valid BCD numbers, flags are NMOS ones
+test10:	lda #10
+	sta TESTNUM
+	lda #$61
+	ldx #<tabadcd
+	ldy #>tabadcd
+	jsr alu6
+	lda #$E1
+	ldx #<tabsbcd
+	ldy #>tabsbcd
+	jsr alu6
+	cld
+
+; Test 11: stores of accumulator in all addressing modes
+test11:	lda #11
+	sta TESTNUM
+	ldx #0
+@mode:	stx MODE
+	lda #$00
+	sta ABSOPD
+	sta ZPOPD
+	lda #$81
+	ora modeofs,x
+	sta @exec
+	lda modeop1,x
+	sta @exec+1
+	lda modeop2,x
+	sta @exec+2
+	cpx #2			; STA has no immediate mode
+	beq @next
+	ldx #3
+	ldy #3
+	lda #$FF
+	pha
+	lda #$A5
+	plp
+@exec:	.byte 0, 0, 0
+	php
+	pla
+	cmp #$FF
+	bne *
+	ldx MODE
+	lda modezp,x
+	bne @zp
+	lda ABSOPD
+	cmp #$A5
+	bne *
+	lda ZPOPD
+	bne *
+	beq @next
+@zp:	lda ZPOPD
+	cmp #$A5
+	bne *
+	lda ABSOPD
+	bne *
+@next:	ldx MODE
+	inx
+	cpx #8
+	bne @mode
+
+; Test 12: BIT
+test12:	lda #12
+	sta TESTNUM
+	lda #$C0
+	sta ZPOPD
+	lda #$3F
+	sta ABSOPD
+	lda #$00
+	pha
+	plp
+	lda #$3F
+	bit ZPOPD
+	php
+	pla
+	cmp #$F2
+	bne *
+	lda #$FF
+	pha
+	plp
+	lda #$01
+	bit ABSOPD
+	php
+	pla
+	cmp #$3D
+	bne *
+	lda #$C0
+	bit ZPOPD
+	php
+	pla
+	cmp #$FD
+	bne *
+
+; Test 13: shifts, rotations, increments and decrements in all addressing modes
+test13:	lda #13
+	sta TESTNUM
+	lda #$06
+	ldx #<tabasl
+	ldy #>tabasl
+	jsr rmw0
+	lda #$26
+	ldx #<tabrol
+	ldy #>tabrol
+	jsr rmw0
+	lda #$46
+	ldx #<tablsr
+	ldy #>tablsr
+	jsr rmw0
+	lda #$66
+	ldx #<tabror
+	ldy #>tabror
+	jsr rmw0
+	lda #$E6
+	ldx #<tabinc
+	ldy #>tabinc
+	jsr rmw1
+	lda #$C6
+	ldx #<tabdec
+	ldy #>tabdec
+	jsr rmw1
+
+; Test 14: jumps
+test14:	lda #14
+	sta TESTNUM
+	jmp @abs
+	jmp *
+@abs:	lda #<@ind
+	sta $0380
+	lda #>@ind
+	sta $0381
+	jmp ($0380)
+	jmp *
+@ind:	lda #<@wrap		; pointer at $xxFF takes high byte from $xx00
+	sta $03FF
+	lda #>@wrap
+	sta $0300
+	jmp ($03FF)		; carry into high byte would take it from $0400, opcode of start
+	jmp *
+@wrap:	nop
+
+; Test 15: subroutines
+test15:	lda #15
+	sta TESTNUM
+	ldx #$FF
+	txs
+	jsr @sub
+@ret:	tsx
+	cpx #$FF
+	bne *
+	jmp @done
+@sub:	tsx
+	cpx #$FD
+	bne *
+	lda $01FE		; JSR pushes address of its last byte
+	cmp #<(@ret-1)
+	bne *
+	lda $01FF
+	cmp #>(@ret-1)
+	bne *
+	rts
+@done:	nop
+
+; Test 16: BRK and RTI
+test16:	lda #16
+	sta TESTNUM
+	lda #<@handler
+	sta $FFFE
+	lda #>@handler
+	sta $FFFF
+	lda #$C3		; I and D are clear
+	pha
+	plp
+	brk
+@pad:	.byte $EA		; padding byte, which is skipped by RTI
+	php
+	pla
+	cmp #$F3
+	bne *
+	jmp @done
+@handler:
+	php
+	pla
+	cmp #$F7		; BRK sets I
+	bne *
+	tsx
+	cpx #$FC
+	bne *
+	lda $01FD		; flags are pushed with break bit
+	cmp #$F3
+	bne *
+	lda $01FE
+	cmp #<(@pad+1)
+	bne *
+	lda $01FF
+	cmp #>(@pad+1)
+	bne *
+	lda #$00
+	pha
+	plp
+	rti
+@done:	nop
+
+; Test 17: RTI restores all flags and doesn't add 1 to address
+test17:	lda #17
+	sta TESTNUM
+	lda #>@back
+	pha
+	lda #<@back
+	pha
+	lda #$CF
+	pha
+	lda #$00
+	rti
+	jmp *
+@back:	php
+	pla
+	cmp #$FF
+	bne *
+	cld
+	ldx #$FF
+	txs
+
+SUCCESS:
+	jmp SUCCESS
+
+; alu runs instruction group with opcode GRP in (zp,X) mode in all 8 addressing modes for COUNT
+; table entries at TPTR of 5 bytes: accumulator, operand, flags, expected accumulator and flags.
+; The entry points set group from A, table address from X and Y and number of entries.
+alu4:	pha
+	lda #4
+	bne alu
+alu5:	pha
+	lda #5
+	bne alu
+alu6:	pha
+	lda #6
+	bne alu
+alu8:	pha
+	lda #8
+	bne alu
+alu9:	pha
+	lda #9
+alu:	sta COUNT
+	stx TPTR
+	sty TPTR+1
+	pla
+	sta GRP
+	lda #0
+	sta ENTRY
+@entry:	ldy ENTRY
+	lda (TPTR),y
+	sta AIN
+	iny
+	lda (TPTR),y
+	sta OPD
+	sta ZPOPD
+	sta ABSOPD
+	iny
+	lda (TPTR),y
+	sta PIN
+	iny
+	lda (TPTR),y
+	sta AOUT
+	iny
+	lda (TPTR),y
+	sta POUT
+	iny
+	sty ENTRY
+	ldx #0
+@mode:	stx MODE
+	lda GRP
+	ora modeofs,x
+	sta @exec
+	lda modeop1,x
+	bne @op1
+	lda OPD			; immediate mode
+@op1:	sta @exec+1
+	lda modeop2,x
+	sta @exec+2
+	ldx #3
+	ldy #3
+	lda PIN
+	pha
+	lda AIN
+	plp
+@exec:	.byte 0, 0, 0
+	php
+	cmp AOUT
+	bne *
+	pla
+	cmp POUT
+	bne *
+	ldx MODE
+	inx
+	cpx #8
+	bne @mode
+	dec COUNT
+	bne @entry
+	rts
+
+; Addressing modes of ALU group: (zp,X), zp, #, abs, (zp),Y, zp,X, abs,Y, abs,X. Operand bytes
+; follow opcode, 0 stands for operand of immediate mode and NOP pads 2 byte instructions.
+modeofs:
+	.byte $00, $04, $08, $0C, $10, $14, $18, $1C
+modeop1:
+	.byte PTRX, ZPOPD, 0, <ABSOPD, PTRY, ZPBASE, <ABSBASE, <ABSBASE
+modeop2:
+	.byte $EA, $EA, $EA, >ABSOPD, $EA, $EA, >ABSBASE, >ABSBASE
+modezp:
+	.byte 0, 1, 0, 0, 0, 1, 0, 0
+
+; rmw runs read-modify-write instruction with opcode GRP in zero page mode in addressing modes
+; from FIRST for COUNT table entries at TPTR of 4 bytes: value, flags, expected value and flags.
+; rmw0 starts with accumulator mode, which INC and DEC don't have, so they use rmw1.
+rmw0:	pha
+	lda #0
+	beq rmw
+rmw1:	pha
+	lda #1
+rmw:	sta FIRST
+	stx TPTR
+	sty TPTR+1
+	pla
+	sta GRP
+	lda #4
+	sta COUNT
+	lda #0
+	sta ENTRY
+@entry:	ldy ENTRY
+	lda (TPTR),y
+	sta AIN
+	iny
+	lda (TPTR),y
+	sta PIN
+	iny
+	lda (TPTR),y
+	sta AOUT
+	iny
+	lda (TPTR),y
+	sta POUT
+	iny
+	sty ENTRY
+	ldx FIRST
+@mode:	stx MODE
+	lda GRP
+	cld
+	clc
+	adc rmwofs,x
+	sta @exec
+	lda rmwop1,x
+	sta @exec+1
+	lda rmwop2,x
+	sta @exec+2
+	lda AIN
+	sta ZPOPD
+	sta ABSOPD
+	ldx #3
+	lda PIN
+	pha
+	lda AIN
+	plp
+@exec:	.byte 0, 0, 0
+	php
+	ldx MODE
+	bne @mem
+	cmp AOUT
+	bne *
+	beq @flags
+@mem:	lda rmwabs,x
+	bne @abs
+	lda ZPOPD
+	jmp @cmp
+@abs:	lda ABSOPD
+@cmp:	cmp AOUT
+	bne *
+@flags:	pla
+	cmp POUT
+	bne *
+	ldx MODE
+	inx
+	cpx #5
+	bne @mode
+	dec COUNT
+	bne @entry
+	rts
+
+; Addressing modes of read-modify-write instructions: A, zp, abs, zp,X, abs,X
+rmwofs:
+	.byte $04, $00, $08, $10, $18
+rmwop1:
+	.byte $EA, ZPOPD, <ABSOPD, ZPBASE, <ABSBASE
+rmwop2:
+	.byte $EA, $EA, >ABSOPD, $EA, >ABSBASE
+rmwabs:
+	.byte 0, 0, 1, 0, 1
+
+; Tables: accumulator, operand, flags, expected accumulator, expected flags
+tabora:
+	.byte $00, $00, $00, $00, $32	; ORA $00, $00
+	.byte $00, $00, $C3, $00, $73	; ORA $00, $00
+	.byte $0F, $F0, $00, $FF, $B0	; ORA $0F, $F0
+	.byte $81, $01, $41, $81, $F1	; ORA $81, $01
+	.byte $5A, $00, $FF, $5A, $7D	; ORA $5A, $00
+taband:
+	.byte $FF, $00, $00, $00, $32	; AND $FF, $00
+	.byte $F0, $0F, $C3, $00, $73	; AND $F0, $0F
+	.byte $FF, $80, $00, $80, $B0	; AND $FF, $80
+	.byte $81, $81, $41, $81, $F1	; AND $81, $81
+	.byte $5A, $7E, $FF, $5A, $7D	; AND $5A, $7E
+tabeor:
+	.byte $FF, $FF, $00, $00, $32	; EOR $FF, $FF
+	.byte $0F, $FF, $C3, $F0, $F1	; EOR $0F, $FF
+	.byte $00, $00, $80, $00, $32	; EOR $00, $00
+	.byte $81, $01, $41, $80, $F1	; EOR $81, $01
+	.byte $5A, $A5, $FF, $FF, $FD	; EOR $5A, $A5
+tabadc:
+	.byte $00, $00, $00, $00, $32	; ADC $00, $00
+	.byte $00, $00, $01, $01, $30	; ADC $00, $00
+	.byte $7F, $01, $00, $80, $F0	; ADC $7F, $01
+	.byte $80, $FF, $00, $7F, $71	; ADC $80, $FF
+	.byte $FF, $01, $C0, $00, $33	; ADC $FF, $01
+	.byte $7F, $7F, $01, $FF, $F0	; ADC $7F, $7F
+	.byte $80, $80, $41, $01, $71	; ADC $80, $80
+	.byte $01, $FE, $01, $00, $33	; ADC $01, $FE
+	.byte $35, $42, $F7, $78, $34	; ADC $35, $42
+tabsbc:
+	.byte $00, $00, $01, $00, $33	; SBC $00, $00
+	.byte $00, $00, $00, $FF, $B0	; SBC $00, $00
+	.byte $80, $01, $01, $7F, $71	; SBC $80, $01
+	.byte $7F, $FF, $01, $80, $F0	; SBC $7F, $FF
+	.byte $01, $02, $C1, $FF, $B0	; SBC $01, $02
+	.byte $80, $00, $00, $7F, $71	; SBC $80, $00
+	.byte $42, $35, $F7, $0D, $35	; SBC $42, $35
+	.byte $FF, $FF, $00, $FF, $B0	; SBC $FF, $FF
+tabcmp:
+	.byte $42, $42, $00, $42, $33	; CMP $42, $42
+	.byte $42, $41, $C0, $42, $71	; CMP $42, $41
+	.byte $42, $43, $01, $42, $B0	; CMP $42, $43
+	.byte $00, $80, $00, $00, $B0	; CMP $00, $80
+	.byte $80, $00, $FE, $80, $FD	; CMP $80, $00
+tablda:
+	.byte $FF, $00, $80, $00, $32	; LDA $FF, $00
+	.byte $00, $80, $42, $80, $F0	; LDA $00, $80
+	.byte $00, $7F, $F7, $7F, $75	; LDA $00, $7F
+	.byte $80, $01, $00, $01, $30	; LDA $80, $01
+tabadcd:
+	.byte $00, $00, $08, $00, $3A	; ADC $00, $00
+	.byte $09, $01, $08, $10, $38	; ADC $09, $01
+	.byte $58, $46, $09, $05, $F9	; ADC $58, $46
+	.byte $99, $01, $08, $00, $B9	; ADC $99, $01
+	.byte $50, $49, $09, $00, $F9	; ADC $50, $49
+	.byte $12, $34, $3C, $46, $3C	; ADC $12, $34
+tabsbcd:
+	.byte $00, $00, $09, $00, $3B	; SBC $00, $00
+	.byte $00, $01, $09, $99, $B8	; SBC $00, $01
+	.byte $46, $12, $08, $33, $39	; SBC $46, $12
+	.byte $40, $13, $09, $27, $39	; SBC $40, $13
+	.byte $32, $02, $08, $29, $39	; SBC $32, $02
+	.byte $21, $34, $3D, $87, $BC	; SBC $21, $34
+
+; Tables of read-modify-write instructions: value, flags, expected value, expected flags
+tabasl:
+	.byte $00, $00, $00, $32	; ASL $00
+	.byte $81, $00, $02, $31	; ASL $81
+	.byte $40, $C3, $80, $F0	; ASL $40
+	.byte $FF, $01, $FE, $B1	; ASL $FF
+tabrol:
+	.byte $00, $00, $00, $32	; ROL $00
+	.byte $00, $01, $01, $30	; ROL $00
+	.byte $80, $00, $00, $33	; ROL $80
+	.byte $40, $C2, $80, $F0	; ROL $40
+tablsr:
+	.byte $00, $00, $00, $32	; LSR $00
+	.byte $01, $80, $00, $33	; LSR $01
+	.byte $80, $01, $40, $30	; LSR $80
+	.byte $FF, $00, $7F, $31	; LSR $FF
+tabror:
+	.byte $00, $00, $00, $32	; ROR $00
+	.byte $00, $01, $80, $B0	; ROR $00
+	.byte $01, $00, $00, $33	; ROR $01
+	.byte $02, $C3, $81, $F0	; ROR $02
+tabinc:
+	.byte $00, $00, $01, $30	; INC $00
+	.byte $FF, $00, $00, $32	; INC $FF
+	.byte $7F, $C3, $80, $F1	; INC $7F
+	.byte $80, $02, $81, $B0	; INC $80
+tabdec:
+	.byte $00, $00, $FF, $B0	; DEC $00
+	.byte $01, $80, $00, $32	; DEC $01
+	.byte $80, $41, $7F, $71	; DEC $80
+	.byte $81, $03, $80, $B1	; DEC $81
@@ -0,0 +1,181 @@
+package go6502
+
+import "testing"
+
+// tickInstruction runs instruction by Tick and returns its bus cycles
+func tickInstruction(cpu *CPU) []BusCycle {
+	var cycles []BusCycle
+	for done := false; !done; {
+		done = cpu.Tick()
+		cycles = append(cycles, cpu.LastCycle())
+	}
+	return cycles
+}
+
+func TestTickCycleCounts(t *testing.T) {
+	for opcode, instruction := range NMOS6502Undocumented {
+		// Branches are tested separately, as they aren't taken only for some flags
+		if !instruction.Defined() || instruction.Mnemonic == "JAM" || instruction.Mode == Relative {
+			continue
+		}
+		cpu := NewDefaultMemoryCPU(WithUndocumented())
+		cpu.Memory.Set(0x0200, uint8(opcode), 0x10, 0x03)
+		cpu.PC = 0x0200
+		if cycles := tickInstruction(cpu); len(cycles) != instruction.Cycles {
+			t.Fatalf("Wrong number of cycles of %02X %v. Expected %d, got %d: %v",
+				opcode, instruction.Mnemonic, instruction.Cycles, len(cycles), cycles)
+		}
+	}
+}
+
+func TestTickIndirectJump65C02(t *testing.T) {
+	cpu := NewDefaultMemoryCPU(WithVariant(WDC65C02))
+	cpu.Memory.Set(0x02FE, OpJMP_indexed_indirect, 0xFF, 0x03)
+	cpu.Memory.Set(0x0400, 0x00, 0x30)
+	cpu.Memory.Set(0x3000, OpJMP_indirect, 0xFF, 0x04)
+	cpu.Memory.Set(0x04FF, 0x00, 0x02)
+	cpu.PC = 0x02FE
+	cpu.X = 0x01
+	for _, expected := range []uint16{0x3000, 0x0200} {
+		cycles := tickInstruction(cpu)
+		if len(cycles) != CMOS65C02[cycles[0].Value].Cycles || cycles[3].Address != cycles[2].Address || cpu.PC != expected {
+			t.Fatalf("Indirect jump of 65C02 should read its last byte again. Expected PC %x, got %x %v",
+				expected, cpu.PC, cycles)
+		}
+	}
+}
+
+func TestTickPageCrossing(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpLDA_absolute_x, 0xFF, 0x03, OpSTA_absolute_x, 0x00, 0x03, OpBNE, 0x80)
+	cpu.Memory.Set(0x0400, 0x42)
+	cpu.PC = 0x0200
+	cpu.X = 0x01
+	cycles := tickInstruction(cpu)
+	if len(cycles) != 5 || cycles[3] != (BusCycle{Address: 0x0300}) || cpu.A != 0x42 {
+		t.Fatalf("Crossing page should read address without carry. Expected %x, got %v", 0x0300, cycles)
+	}
+	cycles = tickInstruction(cpu)
+	if len(cycles) != 5 || cycles[3] != (BusCycle{Address: 0x0301}) {
+		t.Fatalf("Store should always read address before writing. Expected %x, got %v", 0x0301, cycles)
+	}
+	cycles = tickInstruction(cpu)
+	if len(cycles) != 4 || cycles[2].Address != 0x0208 || cycles[3].Address != 0x0288 || cpu.PC != 0x0188 {
+		t.Fatalf("Taken branch should read next opcode and address without carry. Expected PC %x, got %x %v",
+			0x0188, cpu.PC, cycles)
+	}
+}
+
+func TestTickReadModifyWrite(t *testing.T) {
+	var writes []MemoryWrite
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.AddObserver(&writeRecorder{writes: &writes})
+	cpu.Memory.Set(0x0200, OpINC_zeropage, 0x10)
+	cpu.Memory.Set(0x0010, 0x41)
+	writes = nil
+	cpu.PC = 0x0200
+	tickInstruction(cpu)
+	expected := []MemoryWrite{{Address: 0x10, Previous: 0x41, Value: 0x41}, {Address: 0x10, Previous: 0x41, Value: 0x42}}
+	if len(writes) != 2 || writes[0] != expected[0] || writes[1] != expected[1] {
+		t.Fatalf("NMOS read-modify-write should write unmodified value first. Expected %v, got %v", expected, writes)
+	}
+
+	cpu = newCMOSCPU(OpINC_zeropage, 0x10)
+	cpu.Memory.Set(0x0010, 0x41)
+	cycles := tickInstruction(cpu)
+	if len(cycles) != 5 || cycles[3] != (BusCycle{Address: 0x10, Value: 0x41}) || cycles[4].Value != 0x42 {
+		t.Fatalf("65C02 read-modify-write should read value twice. Expected %x, got %v", 0x41, cycles)
+	}
+}
+
+type writeRecorder struct {
+	writes *[]MemoryWrite
+}
+
+func (r *writeRecorder) MemoryRead(address uint16, value uint8) {
+}
+
+func (r *writeRecorder) MemoryWritten(address uint16, previous uint8, value uint8) {
+	*r.writes = append(*r.writes, MemoryWrite{Address: address, Previous: previous, Value: value})
+}
+
+func TestTickKeepsRegistersUntilDone(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, OpJSR_absolute, 0x00, 0x30, OpINX)
+	cpu.Memory.Set(0x3000, OpRTS)
+	cpu.PC = 0x0200
+	for i := 0; i < 5; i++ {
+		if cpu.Tick() || cpu.PC != 0x0200 || cpu.S != 0xFF || !cpu.Busy() {
+			t.Fatalf("Registers shouldn't change before the last cycle. Expected PC %x, got %x", 0x0200, cpu.PC)
+		}
+	}
+	if !cpu.Tick() || cpu.PC != 0x3000 || cpu.S != 0xFD || cpu.Busy() {
+		t.Fatalf("JSR should be done in 6 cycles. Expected PC %x, got %x", 0x3000, cpu.PC)
+	}
+	cpu.Tick()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.PC != 0x0204 || cpu.X != 1 || cpu.Cycles != 12 {
+		t.Fatalf("Advance should finish instruction started by Tick. Expected PC %x, got %x after %d cycles",
+			0x0204, cpu.PC, cpu.Cycles)
+	}
+}
+
+func TestTickInterrupt(t *testing.T) {
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Memory.Set(0x0200, OpLDA_absolute, 0x00, 0x10, OpINX)
+	cpu.PC = 0x0200
+	cpu.Tick()
+	cpu.IRQ()
+	if cycles := tickInstruction(cpu); len(cycles) != 3 || cpu.PC != 0x0203 {
+		t.Fatalf("IRQ should wait for instruction in progress. Expected PC %x, got %x", 0x0203, cpu.PC)
+	}
+
+	cycles := tickInstruction(cpu)
+	expected := []BusCycle{{Address: 0x0203, Value: OpINX}, {Address: 0x0203, Value: OpINX},
+		{Address: 0x01FF, Value: 0x02, Write: true}, {Address: 0x01FE, Value: 0x03, Write: true},
+		{Address: 0x01FD, Value: 0b00100010, Write: true}, {Address: 0xFFFE}, {Address: 0xFFFF, Value: 0x30}}
+	if len(cycles) != len(expected) || cpu.PC != 0x3000 || cpu.X != 0 || cpu.Cycles != 11 {
+		t.Fatalf("IRQ should take 7 cycles instead of the next instruction. Expected %v, got %v after %d cycles",
+			expected, cycles, cpu.Cycles)
+	}
+	for i := range expected {
+		if cycles[i] != expected[i] {
+			t.Fatalf("Wrong interrupt cycle %d. Expected %v, got %v", i, expected[i], cycles[i])
+		}
+	}
+}
+
+// clearOnRead is device register, which is cleared when read, like interrupt flags of VIA
+type clearOnRead struct {
+	value uint8
+	reads int
+}
+
+func (r *clearOnRead) WithinRange(address uint16) bool { return address == 0xD000 }
+func (r *clearOnRead) Get(address uint16) uint8 {
+	value := r.value
+	r.value = 0
+	r.reads++
+	return value
+}
+func (r *clearOnRead) Set(address uint16, value uint8) { r.value = value }
+
+func TestTickReadsDeviceOnce(t *testing.T) {
+	register := &clearOnRead{value: 0x42}
+	cpu := NewCPU(NewMemory(register, NewRAM(0, 0x10000)))
+	cpu.Memory.Set(0x0200, OpLDA_absolute, 0x00, 0xD0, OpORA_absolute, 0x00, 0xD0)
+	cpu.PC = 0x0200
+	tickInstruction(cpu)
+	if cpu.A != 0x42 || register.reads != 1 {
+		t.Fatalf("Replayed cycles shouldn't read device again. Expected A %x after 1 read, got %x after %d",
+			0x42, cpu.A, register.reads)
+	}
+	register.value = 0x81
+	tickInstruction(cpu)
+	if cpu.A != 0xC3 || register.reads != 2 || register.value != 0 {
+		t.Fatalf("Each instruction should read device once. Expected A %x after 2 reads, got %x after %d",
+			0xC3, cpu.A, register.reads)
+	}
+}
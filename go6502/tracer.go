@@ -0,0 +1,39 @@
+package go6502
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tracer is called by CPU before executing each instruction, while PC still points at it.
+type Tracer interface {
+	Trace(cpu *CPU)
+}
+
+// PrintTracer writes disassembled instruction and CPU state before every instruction.
+// When debug info is present, it's used to print symbols and source line of current PC.
+type PrintTracer struct {
+	w     io.Writer
+	Debug *DebugInfo
+}
+
+func (t *PrintTracer) Trace(cpu *CPU) {
+	disassembler := NewDisassembler(t.Debug)
+	disassembler.Instructions = cpu.Instructions()
+	line := disassembler.DisassembleOne(cpu.Memory, cpu.PC)
+	trace := fmt.Sprintf("%-44s A: %02X  X: %02X  Y: %02X  S: %02X  %s",
+		line, cpu.A, cpu.X, cpu.Y, cpu.S, cpu.Flags)
+	if t.Debug != nil {
+		if source, ok := t.Debug.Line(cpu.PC); ok {
+			trace += "  ; " + source.String()
+		}
+	}
+	io.WriteString(t.w, trace+"\n")
+}
+
+func NewPrintTracer(w io.Writer, debug *DebugInfo) *PrintTracer {
+	return &PrintTracer{
+		w:     w,
+		Debug: debug,
+	}
+}
@@ -0,0 +1,124 @@
+package go6502
+
+import "fmt"
+
+// UndefinedOpcodeError is reported by CPU in strict mode, when it meets opcode missing in its instruction set
+type UndefinedOpcodeError struct {
+	Opcode  uint8
+	Address uint16
+}
+
+func (e *UndefinedOpcodeError) Error() string {
+	return fmt.Sprintf("undefined opcode $%02X at $%04X", e.Opcode, e.Address)
+}
+
+// advanceUndocumented executes undocumented opcodes of NMOS 6502, it returns false for documented ones
+func (cpu *CPU) advanceUndocumented(opcode uint8) bool {
+	if NMOS6502[opcode].Defined() {
+		return false
+	}
+	instruction := NMOS6502Undocumented[opcode]
+	switch instruction.Mnemonic {
+	case "JAM":
+		// Bus is locked until reset, PC stays at JAM
+		cpu.PC--
+		cpu.State = Jammed
+	case "NOP":
+		if instruction.Mode != Implied {
+			cpu.read(cpu.operandAddress(instruction.Mode, false))
+		}
+
+	case "LAX":
+		cpu.lda(cpu.operandAddress(instruction.Mode, false))
+		cpu.X = cpu.A
+	case "SAX":
+		cpu.write(cpu.operandAddress(instruction.Mode, true), cpu.A&cpu.X)
+
+	case "SLO":
+		cpu.A |= cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.asl)
+		cpu.updateNZ(cpu.A)
+	case "RLA":
+		cpu.A &= cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.rol)
+		cpu.updateNZ(cpu.A)
+	case "SRE":
+		cpu.A ^= cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.lsr)
+		cpu.updateNZ(cpu.A)
+	case "RRA":
+		cpu.add(cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.ror))
+	case "DCP":
+		cpu.compare(cpu.A, cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.dec))
+	case "ISC":
+		cpu.subtract(cpu.modify(cpu.operandAddress(instruction.Mode, true), cpu.inc))
+
+	case "ANC":
+		cpu.and(cpu.immediateAddress())
+		cpu.Flags.SetCarry(cpu.Flags.HasNegative())
+	case "ALR":
+		cpu.and(cpu.immediateAddress())
+		cpu.A = cpu.lsr(cpu.A)
+	case "ARR":
+		cpu.arr(cpu.A & cpu.read(cpu.immediateAddress()))
+	case "SBX":
+		value := cpu.read(cpu.immediateAddress())
+		cpu.compare(cpu.A&cpu.X, value)
+		cpu.X = cpu.A&cpu.X - value
+	case "SBC":
+		cpu.sbc(cpu.immediateAddress())
+
+	default:
+		return false
+	}
+	return true
+}
+
+// operandAddress reads operand of instruction in given addressing mode and returns its effective address,
+// write tells if indexed address is used by store or read-modify-write instruction
+func (cpu *CPU) operandAddress(mode AddressingMode, write bool) uint16 {
+	switch mode {
+	case Immediate:
+		return cpu.immediateAddress()
+	case ZeroPage:
+		return cpu.zeropageAddress()
+	case ZeroPageX:
+		return cpu.zeropageXAddress()
+	case ZeroPageY:
+		return cpu.zeropageYAddress()
+	case Absolute:
+		return cpu.absoluteAddress()
+	case AbsoluteX:
+		return cpu.indexed(cpu.absoluteAddress(), cpu.X, write)
+	case AbsoluteY:
+		return cpu.indexed(cpu.absoluteAddress(), cpu.Y, write)
+	case IndexedIndirect:
+		return cpu.indexedIndirectAddress()
+	case IndirectIndexed:
+		return cpu.indexed(cpu.zeropageIndirectAddress(), cpu.Y, write)
+	}
+	panic(fmt.Sprintf("addressing mode %d has no operand address", mode))
+}
+
+// arr is AND followed by ROR A, with carry and overflow from bits 6 and 5 of result.
+// In decimal mode NMOS 6502 also adjusts the result digits, like after ADC.
+func (cpu *CPU) arr(value uint8) {
+	result := value >> 1
+	if cpu.Flags.HasCarry() {
+		result |= 1 << 7
+	}
+	cpu.updateNZ(result)
+	if !cpu.decimal() {
+		cpu.Flags.SetCarry(result&(1<<6) != 0)
+		cpu.Flags.SetOverflow((result>>6^result>>5)&1 != 0)
+		cpu.A = result
+		return
+	}
+	cpu.Flags.SetOverflow((value^result)&(1<<6) != 0)
+	if value&0x0F+value&0x01 > 0x05 {
+		result = result&0xF0 | (result+0x06)&0x0F
+	}
+	carry := uint16(value&0xF0)+uint16(value&0x10) > 0x50
+	if carry {
+		result += 0x60
+	}
+	cpu.Flags.SetCarry(carry)
+	cpu.A = result
+}
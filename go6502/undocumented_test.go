@@ -0,0 +1,182 @@
+package go6502
+
+import "testing"
+
+func newUndocumentedCPU(program ...uint8) *CPU {
+	cpu := NewDefaultMemoryCPU(WithUndocumented())
+	cpu.Memory.Set(0x0200, program...)
+	cpu.PC = 0x0200
+	return cpu
+}
+
+func TestUndocumentedInstructionSet(t *testing.T) {
+	defined := 0
+	for _, instruction := range NMOS6502Undocumented {
+		if instruction.Defined() {
+			defined++
+		}
+	}
+	if defined != 248 {
+		t.Fatalf("NMOS 6502 should have 248 stable opcodes, got %v", defined)
+	}
+	if NMOS6502Undocumented[0x8B].Defined() || NMOS6502Undocumented[0x9F].Defined() {
+		t.Fatalf("Unstable opcodes shouldn't be defined")
+	}
+	if instruction := NMOS6502Undocumented[0xDB]; instruction.Mnemonic != "DCP" || instruction.Mode != AbsoluteY {
+		t.Fatalf("Wrong instruction for $DB. Expected DCP abs,Y, got %v", instruction)
+	}
+	if NMOS6502Undocumented[0x1C].Size() != 3 || NMOS6502Undocumented[0x12].Size() != 1 {
+		t.Fatalf("Wrong size of NOP abs,X or JAM")
+	}
+	if opcode, _ := NMOS6502Undocumented.Opcode("NOP", Implied); opcode != OpNOOP {
+		t.Fatalf("Documented NOP should be preferred. Expected %x, got %x", OpNOOP, opcode)
+	}
+	if opcode, _ := NMOS6502Undocumented.Opcode("SBC", Immediate); opcode != OpSBC_imm {
+		t.Fatalf("Documented SBC # should be preferred. Expected %x, got %x", OpSBC_imm, opcode)
+	}
+}
+
+func TestLAXAndSAX(t *testing.T) {
+	cpu := newUndocumentedCPU(0xA7, 0x10, 0x97, 0x10) // LAX $10, SAX $10,Y
+	cpu.Memory.Set(0x0010, 0x8F)
+	cpu.Y = 0x01
+	cpu.Advance()
+	if cpu.A != 0x8F || cpu.X != 0x8F || !cpu.Flags.HasNegative() {
+		t.Fatalf("LAX should load A and X. Expected %x, got %x %x", 0x8F, cpu.A, cpu.X)
+	}
+	cpu.A = 0xF1
+	cpu.Advance()
+	if cpu.Memory.Get(0x0011) != 0x81 {
+		t.Fatalf("SAX should store A and X. Expected %x, got %x", 0x81, cpu.Memory.Get(0x0011))
+	}
+}
+
+func TestUndocumentedReadModifyWrite(t *testing.T) {
+	cases := []struct {
+		name   string
+		opcode uint8
+		a      uint8
+		value  uint8
+		carry  bool
+		// Expected values
+		result   uint8
+		memory   uint8
+		carryOut bool
+	}{
+		{"SLO", 0x07, 0x10, 0x81, false, 0x12, 0x02, true},
+		{"RLA", 0x27, 0xFF, 0x81, false, 0x02, 0x02, true},
+		{"SRE", 0x47, 0xFF, 0x03, false, 0xFE, 0x01, true},
+		{"RRA", 0x67, 0x10, 0x03, false, 0x12, 0x01, false},
+		{"DCP", 0xC7, 0x42, 0x43, false, 0x42, 0x42, true},
+		{"ISC", 0xE7, 0x20, 0x0F, true, 0x10, 0x10, true},
+	}
+	for _, c := range cases {
+		cpu := newUndocumentedCPU(c.opcode, 0x10)
+		cpu.A = c.a
+		cpu.Memory.Set(0x0010, c.value)
+		cpu.Flags.SetCarry(c.carry)
+		cpu.Advance()
+		if cpu.A != c.result || cpu.Memory.Get(0x0010) != c.memory || cpu.Flags.HasCarry() != c.carryOut {
+			t.Fatalf("Wrong result of %s. Expected A %x, memory %x, carry %v, got %x, %x, %v",
+				c.name, c.result, c.memory, c.carryOut, cpu.A, cpu.Memory.Get(0x0010), cpu.Flags.HasCarry())
+		}
+	}
+}
+
+func TestUndocumentedImmediate(t *testing.T) {
+	cpu := newUndocumentedCPU(0x0B, 0x80) // ANC #$80
+	cpu.A = 0xF0
+	cpu.Advance()
+	if cpu.A != 0x80 || !cpu.Flags.HasCarry() {
+		t.Fatalf("ANC should copy N to C. Expected %x, got %x %v", 0x80, cpu.A, cpu.Flags)
+	}
+
+	cpu = newUndocumentedCPU(0x4B, 0x03) // ALR #$03
+	cpu.A = 0xFF
+	cpu.Advance()
+	if cpu.A != 0x01 || !cpu.Flags.HasCarry() {
+		t.Fatalf("ALR should AND and shift right. Expected %x, got %x %v", 0x01, cpu.A, cpu.Flags)
+	}
+
+	cpu = newUndocumentedCPU(0x6B, 0xC0) // ARR #$C0
+	cpu.A = 0xFF
+	cpu.Flags.SetCarry(true)
+	cpu.Advance()
+	if cpu.A != 0xE0 || !cpu.Flags.HasCarry() || cpu.Flags.HasOverflow() {
+		t.Fatalf("ARR should set C from bit 6 and V from bits 6 and 5. Expected %x, got %x %v", 0xE0, cpu.A, cpu.Flags)
+	}
+
+	cpu = newUndocumentedCPU(0x6B, 0x99) // ARR #$99 in decimal mode
+	cpu.A = 0xFF
+	cpu.Flags.SetDecimal(true)
+	cpu.Advance()
+	if cpu.A != 0xA2 || !cpu.Flags.HasCarry() || !cpu.Flags.HasOverflow() {
+		t.Fatalf("Decimal ARR should adjust digits. Expected %x, got %x %v", 0xA2, cpu.A, cpu.Flags)
+	}
+
+	cpu = newUndocumentedCPU(0xCB, 0x10) // SBX #$10
+	cpu.A, cpu.X = 0xF0, 0x3F
+	cpu.Advance()
+	if cpu.X != 0x20 || !cpu.Flags.HasCarry() || cpu.A != 0xF0 {
+		t.Fatalf("SBX should subtract from A AND X. Expected %x, got %x %v", 0x20, cpu.X, cpu.Flags)
+	}
+}
+
+func TestUndocumentedNOPs(t *testing.T) {
+	cpu := newUndocumentedCPU(0x80, 0xFF, 0x04, 0x10, 0x1C, 0x00, 0x10, 0x1A, OpINX)
+	for i := 0; i < 5; i++ {
+		cpu.Advance()
+	}
+	if cpu.PC != 0x0209 || cpu.X != 1 {
+		t.Fatalf("Undocumented NOPs should skip operands. Expected PC %x, got %x", 0x0209, cpu.PC)
+	}
+}
+
+func TestJAM(t *testing.T) {
+	cpu := newUndocumentedCPU(OpINX, 0x02, OpINX)
+	cpu.Memory.Set(IRQVectorL, 0x00, 0x30)
+	cpu.Advance()
+	cpu.Advance()
+	cpu.IRQ()
+	cpu.NMI()
+	cpu.Advance()
+	if cpu.State != Jammed || cpu.PC != 0x0201 || cpu.X != 1 {
+		t.Fatalf("JAM should halt CPU at its address. Expected %v at %x, got %v at %x", Jammed, 0x0201, cpu.State, cpu.PC)
+	}
+	cpu.Initialize()
+	if cpu.State != Running {
+		t.Fatalf("Reset should start jammed CPU. Expected state %v, got %v", Running, cpu.State)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	// Without undocumented opcodes LAX is skipped, unless CPU is strict
+	cpu := NewDefaultMemoryCPU()
+	cpu.Memory.Set(0x0200, 0xA7, OpINX)
+	cpu.PC = 0x0200
+	cpu.Advance()
+	if cpu.PC != 0x0201 || cpu.State != Running {
+		t.Fatalf("Undefined opcode should be skipped. Expected PC %x, got %x", 0x0201, cpu.PC)
+	}
+
+	cpu = NewDefaultMemoryCPU(WithStrict())
+	cpu.Memory.Set(0x0200, 0xA7, 0x10)
+	cpu.PC = 0x0200
+	cpu.Advance()
+	if cpu.State != Faulted || cpu.PC != 0x0200 {
+		t.Fatalf("Strict CPU should fault on undefined opcode. Expected %v at %x, got %v at %x", Faulted, 0x0200, cpu.State, cpu.PC)
+	}
+	if cpu.Err == nil || cpu.Err.Error() != "undefined opcode $A7 at $0200" {
+		t.Fatalf("Fault should be described, got %v", cpu.Err)
+	}
+
+	cpu = NewDefaultMemoryCPU(WithStrict(), WithUndocumented())
+	cpu.Memory.Set(0x0200, 0xA7, 0x10, 0x8B, 0x00)
+	cpu.PC = 0x0200
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.State != Faulted || cpu.PC != 0x0202 {
+		t.Fatalf("Strict CPU should execute stable opcodes and fault on unstable ones. Expected %v at %x, got %v at %x",
+			Faulted, 0x0202, cpu.State, cpu.PC)
+	}
+}
@@ -0,0 +1,78 @@
+package go6502
+
+import "fmt"
+
+// Variant is a member of 6502 family. Variants differ in instruction set and in details
+// of behavior of common instructions.
+type Variant int
+
+const (
+	// MOS6502 is original NMOS 6502, including its bugs
+	MOS6502 Variant = iota
+	// WDC65C02 is CMOS 65C02, with decimal mode flags and JMP indirect fixed
+	WDC65C02
+	// Ricoh2A03 is NMOS 6502 of NES, its decimal flag doesn't change ADC and SBC
+	Ricoh2A03
+	// MOS6510 is NMOS 6502 of C64 with I/O port at $0000 and $0001. Port is memory entry,
+	// which must be mapped before RAM, e.g. NewMemory(NewPort6510(), ram), see Port6510.
+	MOS6510
+)
+
+func (v Variant) String() string {
+	switch v {
+	case MOS6502:
+		return "6502"
+	case WDC65C02:
+		return "65C02"
+	case Ricoh2A03:
+		return "2A03"
+	case MOS6510:
+		return "6510"
+	}
+	return fmt.Sprintf("Variant(%d)", int(v))
+}
+
+// Option configures CPU created by NewCPU
+type Option func(cpu *CPU)
+
+// nmos tells if variant is based on NMOS 6502, including its bugs and undocumented opcodes
+func (v Variant) nmos() bool {
+	return v != WDC65C02
+}
+
+// WithVariant selects CPU variant, MOS6502 is used by default
+func WithVariant(variant Variant) Option {
+	return func(cpu *CPU) {
+		cpu.Variant = variant
+		switch variant {
+		case MOS6502, MOS6510:
+			cpu.DecimalMode = DecimalNMOS
+		case WDC65C02:
+			cpu.DecimalMode = DecimalCMOS
+		case Ricoh2A03:
+			cpu.DecimalMode = DecimalDisabled
+		}
+	}
+}
+
+// Instructions returns instruction set of variant, e.g. for disassembler
+func (v Variant) Instructions() *InstructionSet {
+	if v == WDC65C02 {
+		return &CMOS65C02
+	}
+	return &NMOS6502
+}
+
+// WithUndocumented enables stable undocumented opcodes, like LAX or DCP, on NMOS variants
+func WithUndocumented() Option {
+	return func(cpu *CPU) {
+		cpu.Undocumented = true
+	}
+}
+
+// WithStrict makes CPU fault on opcodes, which aren't defined in its instruction set
+func WithStrict() Option {
+	return func(cpu *CPU) {
+		cpu.Strict = true
+	}
+}
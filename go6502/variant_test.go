@@ -0,0 +1,117 @@
+package go6502
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRicoh2A03DecimalDisabled(t *testing.T) {
+	cpu := NewDefaultMemoryCPU(WithVariant(Ricoh2A03), WithUndocumented())
+	cpu.Memory.Set(0x0200, OpSED, OpLDA_imm, 0x09, OpADC_imm, 0x01, 0x6B, 0x99) // ARR #$99
+	cpu.PC = 0x0200
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if cpu.A != 0x0A || !cpu.Flags.HasDecimal() {
+		t.Fatalf("2A03 should add binary with decimal flag set. Expected %x, got %x", 0x0A, cpu.A)
+	}
+	cpu.Advance()
+	if cpu.A != 0x04 {
+		t.Fatalf("2A03 should execute undocumented ARR without decimal adjustment. Expected %x, got %x", 0x04, cpu.A)
+	}
+}
+
+// testBank shows ROM over RAM, when enabled by 6510 port
+type testBank struct {
+	*RAM
+	enabled bool
+}
+
+func (b *testBank) WithinRange(address uint16) bool {
+	return b.enabled && b.RAM.WithinRange(address)
+}
+
+func TestMOS6510Port(t *testing.T) {
+	rom := &testBank{RAM: NewRAM(0xA000, 0x2000), enabled: true}
+	rom.RAM.Set(0xA000, 0x42)
+	cpu := NewCPU(NewMemory(NewPort6510(), rom, NewRAM(0, 0x10000)), WithVariant(MOS6510))
+	cpu.Port.Changed = func(pins uint8) { rom.enabled = pins&0x01 != 0 }
+	cpu.Initialize()
+	if !rom.enabled || cpu.Memory.Get(Port6510Data) != 0xFF {
+		t.Fatalf("Pins should be pulled up after reset. Expected %x, got %x", 0xFF, cpu.Memory.Get(Port6510Data))
+	}
+
+	cpu.Memory.Set(0x0200,
+		OpLDA_imm, 0x06, OpSTA_zeropage, 0x01, // Data register doesn't drive input pins
+		OpLDA_imm, 0x07, OpSTA_zeropage, 0x00,
+		OpLDA_absolute, 0x00, 0xA0)
+	cpu.PC = 0x0200
+	cpu.Advance()
+	cpu.Advance()
+	if !rom.enabled {
+		t.Fatalf("ROM should stay enabled while pin is input")
+	}
+	cpu.Advance()
+	cpu.Advance()
+	cpu.Advance()
+	if rom.enabled || cpu.A != 0 {
+		t.Fatalf("Output pin should bank RAM in. Expected A %x, got %x", 0, cpu.A)
+	}
+	if cpu.Memory.Get(Port6510Direction) != 0x07 || cpu.Memory.Get(Port6510Data) != 0xFE {
+		t.Fatalf("Port should read direction and pins. Expected %x %x, got %x %x",
+			0x07, 0xFE, cpu.Memory.Get(Port6510Direction), cpu.Memory.Get(Port6510Data))
+	}
+
+	state, _ := cpu.Port.MarshalBinary()
+	cpu.Initialize()
+	if !rom.enabled {
+		t.Fatalf("Reset should make pins inputs and bank ROM in")
+	}
+	if err := cpu.Port.UnmarshalBinary(state); err != nil || rom.enabled {
+		t.Fatalf("Loaded port state should bank RAM in, got error %v", err)
+	}
+}
+
+func TestMOS6510PortStepBack(t *testing.T) {
+	rom := &testBank{RAM: NewRAM(0xA000, 0x2000), enabled: true}
+	cpu := NewCPU(NewMemory(NewPort6510(), rom, NewRAM(0, 0x10000)), WithVariant(MOS6510))
+	cpu.Port.Changed = func(pins uint8) { rom.enabled = pins&0x01 != 0 }
+	cpu.Port.Data = 0x36
+	cpu.Memory.Set(0x0200, OpLDA_imm, 0x07, OpSTA_zeropage, 0x00, OpLDA_imm, 0x34, OpSTA_zeropage, 0x01)
+	cpu.PC = 0x0200
+	history := NewHistory(cpu, 10)
+	for i := 0; i < 4; i++ {
+		cpu.Advance()
+	}
+	if rom.enabled {
+		t.Fatalf("Output pin should bank RAM in")
+	}
+	history.RunBack(func(cpu *CPU) bool { return cpu.PC == 0x0200 })
+	if !rom.enabled || cpu.Port.Direction != 0 || cpu.Port.Data != 0x36 {
+		t.Fatalf("Step back should restore port registers. Expected %x %x, got %x %x",
+			0, 0x36, cpu.Port.Direction, cpu.Port.Data)
+	}
+}
+
+func TestMOS6510SaveState(t *testing.T) {
+	newC64 := func() *CPU {
+		return NewCPU(NewMemory(NewPort6510(), NewRAM(0, 0x10000)), WithVariant(MOS6510))
+	}
+	cpu := newC64()
+	cpu.Memory.Set(Port6510Direction, 0x2F)
+	cpu.Memory.Set(Port6510Data, 0x37)
+	cpu.Memory.Set(0x0002, 0x42)
+	state := bytes.Buffer{}
+	if err := cpu.Save(&state); err != nil {
+		t.Fatalf("Can't save state: %v", err)
+	}
+
+	loaded := newC64()
+	if err := loaded.Load(&state); err != nil {
+		t.Fatalf("State of 6510 should be loaded: %v", err)
+	}
+	if loaded.Port.Direction != 0x2F || loaded.Port.Data != 0x37 || loaded.Memory.Get(0x0002) != 0x42 {
+		t.Fatalf("Port and RAM should be restored. Expected %x %x %x, got %x %x %x",
+			0x2F, 0x37, 0x42, loaded.Port.Direction, loaded.Port.Data, loaded.Memory.Get(0x0002))
+	}
+}
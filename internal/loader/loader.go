@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"go6502/asm"
+	"go6502/go6502"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Image loads image into memory and sets PC to its start. PRG and assembly source (.s, .asm)
+// files are recognized by extension, other files are flat binaries loaded at origin.
+// Symbols of assembled sources are added to debug info.
+func Image(cpu *go6502.CPU, debug *go6502.DebugInfo, file string, origin ...uint16) (uint16, int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	var start uint16
+	var size int
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".prg":
+		start, err = go6502.LoadPRG(cpu.Memory, bytes.NewReader(data))
+		size = len(data) - 2
+	case ".s", ".asm":
+		var program *asm.Program
+		program, err = asm.New().Assemble(filepath.Base(file), bytes.NewReader(data))
+		if err == nil && len(program.Segments) > 0 {
+			program.Load(cpu.Memory)
+			debug.Merge(program.DebugInfo())
+			start = program.Segments[0].Origin
+			for _, segment := range program.Segments {
+				size += len(segment.Data)
+			}
+		}
+	default:
+		if len(origin) == 0 {
+			return 0, 0, fmt.Errorf("load address is required for binary file")
+		}
+		start = origin[0]
+		size, err = go6502.LoadBinary(cpu.Memory, start, bytes.NewReader(data))
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	cpu.PC = start
+	return start, size, nil
+}
+
+// Symbols adds symbols from ld65 debug (.dbg) or map file, recognized by extension
+func Symbols(debug *go6502.DebugInfo, file string) error {
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	parse := go6502.ParseLd65Debug
+	if strings.ToLower(filepath.Ext(file)) == ".map" {
+		parse = go6502.ParseLd65Map
+	}
+	info, err := parse(reader)
+	if err != nil {
+		return err
+	}
+	debug.Merge(info)
+	return nil
+}
@@ -0,0 +1,621 @@
+package monitor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go6502/go6502"
+	"go6502/internal/loader"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Monitor is a command line debugger, in the spirit of Woz and VICE monitors.
+// It works on plain text input and output, so it can be used over SSH.
+type Monitor struct {
+	CPU         *go6502.CPU
+	Debug       *go6502.DebugInfo
+	out         io.Writer
+	breakpoints map[uint16]bool
+	interrupted int32
+	lastCommand string
+	repeating   bool   // set when command is repeated with empty line
+	nextDump    uint16 // where "m" without arguments continues
+	nextListing uint16 // where "d" without arguments continues
+}
+
+var errQuit = errors.New("quit")
+
+type command struct {
+	names []string
+	usage string
+	run   func(m *Monitor, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{[]string{"help", "?"}, "help - show this help", (*Monitor).help},
+		{[]string{"r", "registers"}, "r [reg=value ...] - show or change registers (A, X, Y, S, PC, P)", (*Monitor).registers},
+		{[]string{"z", "step"}, "z [count] - execute count instructions", (*Monitor).step},
+		{[]string{"n", "next"}, "n - execute instruction, stepping over subroutine calls", (*Monitor).next},
+		{[]string{"bz", "stepback"}, "bz [count] - undo count executed instructions", (*Monitor).stepBack},
+		{[]string{"bg", "goback"}, "bg - run backwards until breakpoint or start of history", (*Monitor).runBack},
+		{[]string{"hist", "history"}, "hist [count] - show recently executed instructions and their memory writes", (*Monitor).history},
+		{[]string{"g", "go"}, "g [address] - run from current PC or address until breakpoint", (*Monitor).run},
+		{[]string{"u", "until"}, "u address - run until PC reaches address or breakpoint", (*Monitor).until},
+		{[]string{"b", "break"}, "b [address] - set breakpoint or list breakpoints", (*Monitor).setBreakpoint},
+		{[]string{"del", "delete"}, "del [address] - delete breakpoint or all breakpoints", (*Monitor).deleteBreakpoint},
+		{[]string{"m", "mem"}, "m [start [end]] - dump memory", (*Monitor).dump},
+		{[]string{"f", "fill"}, "f start end byte ... - fill memory with pattern", (*Monitor).fill},
+		{[]string{">", "e", "edit"}, "> address byte ... - write bytes to memory", (*Monitor).edit},
+		{[]string{"d", "disasm"}, "d [start [end]] - disassemble, by default around PC", (*Monitor).disassemble},
+		{[]string{"l", "load"}, "l file [address] - load .prg, .s or binary file at address", (*Monitor).load},
+		{[]string{"sym", "symbols"}, "sym file - load ld65 .dbg or .map file", (*Monitor).symbols},
+		{[]string{"ss", "savestate"}, "ss file - save CPU and memory state to file", (*Monitor).saveState},
+		{[]string{"ls", "loadstate"}, "ls file - restore CPU and memory state from file", (*Monitor).loadState},
+		{[]string{"reset"}, "reset - reset CPU using reset vector", (*Monitor).reset},
+		{[]string{"q", "x", "quit"}, "q - quit", func(*Monitor, []string) error { return errQuit }},
+	}
+}
+
+// Run reads commands from input until quit command or end of input
+func (m *Monitor) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	m.showState()
+	for {
+		fmt.Fprintf(m.out, "(%04X) ", m.CPU.PC)
+		if !scanner.Scan() {
+			fmt.Fprintln(m.out)
+			return scanner.Err()
+		}
+		if err := m.Execute(scanner.Text()); err == errQuit {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(m.out, "error: %v\n", err)
+		}
+	}
+}
+
+// Execute runs single command line. Empty line repeats last stepping, dump or disassembly command.
+func (m *Monitor) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		if m.lastCommand == "" {
+			return nil
+		}
+		fields = []string{m.lastCommand}
+	}
+	m.repeating = len(strings.Fields(line)) == 0
+	// Allow ">C000 01 02" as well as "> C000 01 02"
+	if strings.HasPrefix(fields[0], ">") && len(fields[0]) > 1 {
+		fields = append([]string{">", fields[0][1:]}, fields[1:]...)
+	}
+	name := strings.ToLower(fields[0])
+	for _, c := range commands {
+		for _, alias := range c.names {
+			if alias == name {
+				m.lastCommand = ""
+				switch c.names[0] {
+				case "z", "n", "bz", "m", "d":
+					m.lastCommand = c.names[0]
+				}
+				return c.run(m, fields[1:])
+			}
+		}
+	}
+	return fmt.Errorf("unknown command %q, type help for list of commands", fields[0])
+}
+
+// Interrupt stops running program, can be called from other goroutine (e.g. on Ctrl-C)
+func (m *Monitor) Interrupt() {
+	atomic.StoreInt32(&m.interrupted, 1)
+}
+
+func (m *Monitor) help(args []string) error {
+	for _, c := range commands {
+		fmt.Fprintf(m.out, "  %s\n", c.usage)
+	}
+	fmt.Fprintf(m.out, "Numbers are hexadecimal, symbol names can be used instead of addresses.\n")
+	return nil
+}
+
+func (m *Monitor) showState() {
+	go6502.NewPrintTracer(m.out, m.Debug).Trace(m.CPU)
+}
+
+func (m *Monitor) registers(args []string) error {
+	for _, arg := range args {
+		register, text, found := strings.Cut(arg, "=")
+		if !found {
+			return fmt.Errorf("expected register=value, got %q", arg)
+		}
+		value, err := m.parseValue(text)
+		if err != nil {
+			return err
+		}
+		if strings.ToUpper(register) != "PC" && value > 0xFF {
+			return fmt.Errorf("value $%X doesn't fit in register %s", value, register)
+		}
+		switch strings.ToUpper(register) {
+		case "A":
+			m.CPU.A = uint8(value)
+		case "X":
+			m.CPU.X = uint8(value)
+		case "Y":
+			m.CPU.Y = uint8(value)
+		case "S", "SP":
+			m.CPU.S = uint8(value)
+		case "P":
+			m.CPU.Flags.SetValue(uint8(value))
+		case "PC":
+			m.CPU.PC = value
+		default:
+			return fmt.Errorf("unknown register %q", register)
+		}
+	}
+	m.showState()
+	return nil
+}
+
+// parseCount parses optional decimal count argument
+func parseCount(args []string, defaultCount int) (int, error) {
+	if len(args) == 0 {
+		return defaultCount, nil
+	}
+	value, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid count %q", args[0])
+	}
+	return int(value), nil
+}
+
+func (m *Monitor) step(args []string) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		m.CPU.Advance()
+	}
+	m.showState()
+	return nil
+}
+
+func (m *Monitor) next(args []string) error {
+	instruction := m.CPU.Memory.Get(m.CPU.PC)
+	if instruction != go6502.OpJSR_absolute {
+		return m.step(nil)
+	}
+	returnAddress := m.CPU.PC + 3
+	stack := m.CPU.S
+	// Stack check makes recursive calls return to the right level
+	return m.runUntil(func() bool { return m.CPU.PC == returnAddress && m.CPU.S >= stack })
+}
+
+func (m *Monitor) run(args []string) error {
+	if len(args) > 0 {
+		address, err := m.parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		m.CPU.PC = address
+	}
+	return m.runUntil(func() bool { return false })
+}
+
+func (m *Monitor) until(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: u address")
+	}
+	address, err := m.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	return m.runUntil(func() bool { return m.CPU.PC == address })
+}
+
+// runUntil executes instructions until stop returns true, breakpoint is hit or monitor is interrupted.
+// First instruction is always executed, so it's possible to continue from breakpoint.
+func (m *Monitor) runUntil(stop func() bool) error {
+	atomic.StoreInt32(&m.interrupted, 0)
+	for {
+		m.CPU.Advance()
+		if stop() {
+			break
+		}
+		if m.CPU.State != go6502.Running {
+			m.showRunState()
+			break
+		}
+		if m.breakpoints[m.CPU.PC] {
+			fmt.Fprintf(m.out, "Breakpoint at %s\n", m.describe(m.CPU.PC))
+			break
+		}
+		if atomic.LoadInt32(&m.interrupted) != 0 {
+			fmt.Fprintf(m.out, "Interrupted\n")
+			break
+		}
+	}
+	m.showState()
+	return nil
+}
+
+// showRunState tells why CPU doesn't execute instructions, e.g. after JAM
+func (m *Monitor) showRunState() {
+	if m.CPU.Err != nil {
+		fmt.Fprintf(m.out, "CPU %v: %v\n", m.CPU.State, m.CPU.Err)
+		return
+	}
+	fmt.Fprintf(m.out, "CPU %v\n", m.CPU.State)
+}
+
+func (m *Monitor) stepBack(args []string) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		if !m.CPU.History.StepBack() {
+			fmt.Fprintf(m.out, "Start of history\n")
+			break
+		}
+	}
+	m.showState()
+	return nil
+}
+
+func (m *Monitor) runBack(args []string) error {
+	if m.CPU.History.RunBack(func(cpu *go6502.CPU) bool { return m.breakpoints[cpu.PC] }) {
+		fmt.Fprintf(m.out, "Breakpoint at %s\n", m.describe(m.CPU.PC))
+	} else {
+		fmt.Fprintf(m.out, "Start of history\n")
+	}
+	m.showState()
+	return nil
+}
+
+// history shows recorded instructions, oldest first, with registers before each of them
+func (m *Monitor) history(args []string) error {
+	count, err := parseCount(args, 10)
+	if err != nil {
+		return err
+	}
+	history := m.CPU.History
+	if count > history.Len() {
+		count = history.Len()
+	}
+	disassembler := go6502.NewDisassembler(m.Debug)
+	disassembler.Instructions = m.CPU.Instructions()
+	for i := count - 1; i >= 0; i-- {
+		entry := history.Entry(i)
+		var flags go6502.Flags
+		flags.SetValue(entry.P)
+		line := fmt.Sprintf("%-44s A: %02X  X: %02X  Y: %02X  S: %02X  %s",
+			disassembler.DisassembleOne(m.CPU.Memory, entry.PC), entry.A, entry.X, entry.Y, entry.S, &flags)
+		for _, write := range entry.Writes {
+			line += fmt.Sprintf("  %04X: %02X->%02X", write.Address, write.Previous, write.Value)
+		}
+		fmt.Fprintln(m.out, line)
+	}
+	return nil
+}
+
+func (m *Monitor) setBreakpoint(args []string) error {
+	if len(args) == 0 {
+		addresses := make([]int, 0, len(m.breakpoints))
+		for address := range m.breakpoints {
+			addresses = append(addresses, int(address))
+		}
+		sort.Ints(addresses)
+		for _, address := range addresses {
+			fmt.Fprintf(m.out, "  %s\n", m.describe(uint16(address)))
+		}
+		return nil
+	}
+	for _, arg := range args {
+		address, err := m.parseAddress(arg)
+		if err != nil {
+			return err
+		}
+		m.breakpoints[address] = true
+		fmt.Fprintf(m.out, "Breakpoint set at %s\n", m.describe(address))
+	}
+	return nil
+}
+
+func (m *Monitor) deleteBreakpoint(args []string) error {
+	if len(args) == 0 {
+		m.breakpoints = map[uint16]bool{}
+		return nil
+	}
+	for _, arg := range args {
+		address, err := m.parseAddress(arg)
+		if err != nil {
+			return err
+		}
+		if !m.breakpoints[address] {
+			return fmt.Errorf("no breakpoint at %s", m.describe(address))
+		}
+		delete(m.breakpoints, address)
+	}
+	return nil
+}
+
+func (m *Monitor) dump(args []string) error {
+	start, end, err := m.parseRange(args, m.nextDump, 0x7F)
+	if err != nil {
+		return err
+	}
+	for row := int(start) &^ 0x0F; row <= int(end); row += 0x10 {
+		hex := strings.Builder{}
+		text := strings.Builder{}
+		for address := row; address < row+0x10; address++ {
+			if address < int(start) || address > int(end) {
+				hex.WriteString("   ")
+				text.WriteString(" ")
+				continue
+			}
+			value := m.CPU.Memory.Get(uint16(address))
+			hex.WriteString(fmt.Sprintf(" %02X", value))
+			if value >= 0x20 && value < 0x7F {
+				text.WriteByte(value)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintln(m.out, strings.TrimRight(fmt.Sprintf("%04X %s  %s", row, hex.String(), text.String()), " "))
+	}
+	m.nextDump = end + 1
+	return nil
+}
+
+func (m *Monitor) fill(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: f start end byte ...")
+	}
+	start, end, err := m.parseRange(args[:2], 0, 0)
+	if err != nil {
+		return err
+	}
+	pattern, err := m.parseBytes(args[2:])
+	if err != nil {
+		return err
+	}
+	for address := int(start); address <= int(end); address++ {
+		m.CPU.Memory.Set(uint16(address), pattern[(address-int(start))%len(pattern)])
+	}
+	return nil
+}
+
+func (m *Monitor) edit(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: > address byte ...")
+	}
+	address, err := m.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	values, err := m.parseBytes(args[1:])
+	if err != nil {
+		return err
+	}
+	m.CPU.Memory.Set(address, values...)
+	return nil
+}
+
+func (m *Monitor) disassemble(args []string) error {
+	disassembler := go6502.NewDisassembler(m.Debug)
+	disassembler.Instructions = m.CPU.Instructions()
+	var lines []go6502.DisassembledLine
+	if len(args) == 0 && m.repeating {
+		lines = disassembler.Disassemble(m.CPU.Memory, m.nextListing, m.nextListing+0x20)
+	} else if len(args) == 0 {
+		lines = m.disassembleAroundPC(disassembler)
+	} else {
+		start, end, err := m.parseRange(args, 0, 0x20)
+		if err != nil {
+			return err
+		}
+		lines = disassembler.Disassemble(m.CPU.Memory, start, end)
+	}
+	for _, line := range lines {
+		marker := " "
+		if line.Address == m.CPU.PC {
+			marker = ">"
+		}
+		fmt.Fprintf(m.out, "%s%s\n", marker, line)
+	}
+	if len(lines) > 0 {
+		last := lines[len(lines)-1]
+		m.nextListing = last.Address + uint16(len(last.Bytes))
+	}
+	return nil
+}
+
+// disassembleAroundPC shows few instructions before PC. Instructions have different lengths,
+// so it looks for the furthest start address, from which decoding gets exactly to PC.
+func (m *Monitor) disassembleAroundPC(disassembler *go6502.Disassembler) []go6502.DisassembledLine {
+	pc := m.CPU.PC
+	start := pc
+	for offset := uint16(12); offset > 0; offset-- {
+		if offset > pc {
+			continue
+		}
+		address := pc - offset
+		for address < pc {
+			address += uint16(len(disassembler.DisassembleOne(m.CPU.Memory, address).Bytes))
+		}
+		if address == pc {
+			start = pc - offset
+			break
+		}
+	}
+	lines := disassembler.Disassemble(m.CPU.Memory, start, pc+0x20)
+	// Keep few instructions before PC and some more after it
+	for i, line := range lines {
+		if line.Address == pc {
+			if len(lines) > i+10 {
+				lines = lines[:i+10]
+			}
+			if i > 5 {
+				lines = lines[i-5:]
+			}
+			break
+		}
+	}
+	return lines
+}
+
+func (m *Monitor) load(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: l file [address]")
+	}
+	start, size, err := m.Load(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Loaded %d bytes at %04X\n", size, start)
+	return nil
+}
+
+func (m *Monitor) saveState(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ss file")
+	}
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := m.CPU.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (m *Monitor) loadState(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ls file")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := m.CPU.Load(file); err != nil {
+		return err
+	}
+	m.showState()
+	return nil
+}
+
+// Load loads image using loader.Image, address is required for binary files
+func (m *Monitor) Load(file string, address ...string) (uint16, int, error) {
+	var origin []uint16
+	for _, text := range address {
+		value, err := m.parseAddress(text)
+		if err != nil {
+			return 0, 0, err
+		}
+		origin = append(origin, value)
+	}
+	return loader.Image(m.CPU, m.Debug, file, origin...)
+}
+
+func (m *Monitor) symbols(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sym file")
+	}
+	return loader.Symbols(m.Debug, args[0])
+}
+
+func (m *Monitor) reset(args []string) error {
+	m.CPU.Initialize()
+	m.showState()
+	return nil
+}
+
+// describe formats address together with symbol, if there is one
+func (m *Monitor) describe(address uint16) string {
+	if label, ok := m.Debug.Label(address); ok {
+		return fmt.Sprintf("%04X (%s)", address, label)
+	}
+	return fmt.Sprintf("%04X", address)
+}
+
+// parseValue parses hexadecimal number (optionally prefixed with $ or 0x) or symbol name
+func (m *Monitor) parseValue(text string) (uint16, error) {
+	if address, ok := m.Debug.Symbol(text); ok {
+		return address, nil
+	}
+	number := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(text), "$"), "0x")
+	value, err := strconv.ParseUint(number, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number or unknown symbol %q", text)
+	}
+	return uint16(value), nil
+}
+
+func (m *Monitor) parseAddress(text string) (uint16, error) {
+	return m.parseValue(text)
+}
+
+func (m *Monitor) parseBytes(args []string) ([]uint8, error) {
+	values := make([]uint8, len(args))
+	for i, arg := range args {
+		value, err := m.parseValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		if value > 0xFF {
+			return nil, fmt.Errorf("value $%X doesn't fit in byte", value)
+		}
+		values[i] = uint8(value)
+	}
+	return values, nil
+}
+
+// parseRange parses optional start and end addresses. Without end, range has given length.
+func (m *Monitor) parseRange(args []string, defaultStart uint16, length uint16) (uint16, uint16, error) {
+	start := defaultStart
+	var err error
+	if len(args) > 0 {
+		if start, err = m.parseAddress(args[0]); err != nil {
+			return 0, 0, err
+		}
+	}
+	end := start + length
+	if end < start {
+		end = 0xFFFF
+	}
+	if len(args) > 1 {
+		if end, err = m.parseAddress(args[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("end address %04X is before start %04X", end, start)
+	}
+	return start, end, nil
+}
+
+// historySize is number of instructions which can be stepped back
+const historySize = 100000
+
+func New(cpu *go6502.CPU, out io.Writer) *Monitor {
+	if cpu.History == nil {
+		go6502.NewHistory(cpu, historySize)
+	}
+	return &Monitor{
+		CPU:         cpu,
+		Debug:       go6502.NewDebugInfo(),
+		out:         out,
+		breakpoints: map[uint16]bool{},
+	}
+}
@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"go6502/asm"
+	"go6502/go6502"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMonitor(t *testing.T, source string) (*Monitor, *strings.Builder) {
+	t.Helper()
+	program, err := asm.Assemble(source)
+	if err != nil {
+		t.Fatalf("Test program should assemble: %v", err)
+	}
+	cpu := go6502.NewDefaultMemoryCPU()
+	program.Load(cpu.Memory)
+	output := &strings.Builder{}
+	m := New(cpu, output)
+	m.Debug.Merge(program.DebugInfo())
+	cpu.PC = program.Segments[0].Origin
+	return m, output
+}
+
+func execute(t *testing.T, m *Monitor, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if err := m.Execute(line); err != nil {
+			t.Fatalf("Command %q failed: %v", line, err)
+		}
+	}
+}
+
+const testProgram = `
+	.org $0200
+main:	lda #1
+	jsr double
+	jsr double
+	tax
+done:	jmp done
+double:	pha
+	pla
+	rts
+`
+
+func TestStepAndNext(t *testing.T) {
+	m, _ := newTestMonitor(t, testProgram)
+	execute(t, m, "z")
+	if m.CPU.PC != 0x0202 {
+		t.Fatalf("Step should execute one instruction. Expected PC %x, got %x", 0x0202, m.CPU.PC)
+	}
+	execute(t, m, "n")
+	if m.CPU.PC != 0x0205 || m.CPU.S != 0xFF {
+		t.Fatalf("Next should step over subroutine. Expected PC %x, got %x", 0x0205, m.CPU.PC)
+	}
+	execute(t, m, "z", "", "")
+	if m.CPU.PC != 0x020E {
+		t.Fatalf("Empty line should repeat step. Expected PC %x, got %x", 0x020E, m.CPU.PC)
+	}
+}
+
+func TestBreakpointsAndUntil(t *testing.T) {
+	m, output := newTestMonitor(t, testProgram)
+	execute(t, m, "b double", "g")
+	if m.CPU.PC != 0x020C {
+		t.Fatalf("Run should stop at breakpoint. Expected PC %x, got %x", 0x020C, m.CPU.PC)
+	}
+	if !strings.Contains(output.String(), "Breakpoint at 020C (double)") {
+		t.Fatalf("Breakpoint hit should be reported, got %q", output.String())
+	}
+	execute(t, m, "g")
+	if m.CPU.S != 0xFD {
+		t.Fatalf("Run should continue from breakpoint to the second call. Expected S %x, got %x", 0xFD, m.CPU.S)
+	}
+	execute(t, m, "del double", "u done")
+	if m.CPU.PC != 0x0209 || m.CPU.X != 1 {
+		t.Fatalf("Run until should stop at address. Expected PC %x, got %x", 0x0209, m.CPU.PC)
+	}
+	if err := m.Execute("del done"); err == nil {
+		t.Fatalf("Deleting missing breakpoint should fail")
+	}
+}
+
+func TestRegistersAndMemory(t *testing.T) {
+	m, output := newTestMonitor(t, testProgram)
+	execute(t, m, "r a=42 x=$10 pc=done p=81", ">1000 48 65 6C", "f 1003 1005 6C 6F", "m 1000 1005")
+	if m.CPU.A != 0x42 || m.CPU.X != 0x10 || m.CPU.PC != 0x0209 || m.CPU.Flags.String() != "Nv1bdizC" {
+		t.Fatalf("Registers weren't changed, got %v", m.CPU)
+	}
+	if !strings.Contains(output.String(), "1000  48 65 6C 6C 6F 6C") || !strings.Contains(output.String(), "Hellol") {
+		t.Fatalf("Memory dump should show edited and filled memory, got %q", output.String())
+	}
+	if err := m.Execute("r a=100"); err == nil {
+		t.Fatalf("Too big register value should fail")
+	}
+}
+
+func TestDisassembleAroundPC(t *testing.T) {
+	m, output := newTestMonitor(t, testProgram)
+	m.CPU.PC = 0x0205
+	execute(t, m, "d")
+	for _, expected := range []string{
+		" 0200  A9 01     main:      LDA #$01",
+		">0205  20 0C 02             JSR double",
+		" 020C  48        double:    PHA",
+	} {
+		if !strings.Contains(output.String(), expected+"\n") {
+			t.Fatalf("Disassembly should contain %q, got %q", expected, output.String())
+		}
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	m, output := newTestMonitor(t, testProgram)
+	execute(t, m, "z 3", "bz")
+	if m.CPU.PC != 0x020C || m.CPU.S != 0xFD {
+		t.Fatalf("Step back should undo one instruction. Expected PC %x, got %x", 0x020C, m.CPU.PC)
+	}
+	execute(t, m, "b main", "bg")
+	if m.CPU.PC != 0x0200 || m.CPU.S != 0xFF || m.CPU.A != 0 {
+		t.Fatalf("Running back should stop at breakpoint. Expected PC %x, got %x", 0x0200, m.CPU.PC)
+	}
+	if !strings.Contains(output.String(), "Breakpoint at 0200 (main)") {
+		t.Fatalf("Breakpoint should be reported, got:\n%s", output)
+	}
+
+	output.Reset()
+	execute(t, m, "z 2", "hist")
+	if !strings.Contains(output.String(), "0200  A9 01     main:      LDA #$01") ||
+		!strings.Contains(output.String(), "JSR double") || !strings.Contains(output.String(), "01FF: 00->02") {
+		t.Fatalf("History should show instructions and memory writes, got:\n%s", output)
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	m, _ := newTestMonitor(t, testProgram)
+	file := filepath.Join(t.TempDir(), "state.bin")
+	execute(t, m, "z 2", "ss "+file, "z 3", "> 0300 42")
+	execute(t, m, "ls "+file)
+	if m.CPU.PC != 0x020C || m.CPU.S != 0xFD || m.CPU.Memory.Get(0x0300) != 0 {
+		t.Fatalf("State should be restored. Expected PC %x, got %x", 0x020C, m.CPU.PC)
+	}
+}
+
+func TestRunStopsWhenCPUHalts(t *testing.T) {
+	m, output := newTestMonitor(t, `
+	.org $0200
+	lda #1
+	.byte $02
+`)
+	m.CPU.Undocumented = true
+	execute(t, m, "g")
+	if m.CPU.PC != 0x0202 || !strings.Contains(output.String(), "CPU jammed") {
+		t.Fatalf("Run should stop at JAM. Expected PC %x, got %x and %q", 0x0202, m.CPU.PC, output.String())
+	}
+
+	m, output = newTestMonitor(t, testProgram)
+	m.CPU.Strict = true
+	m.CPU.Memory.Set(0x0202, 0x02)
+	execute(t, m, "g")
+	if !strings.Contains(output.String(), "CPU faulted: undefined opcode $02 at $0202") {
+		t.Fatalf("Run should report undefined opcode in strict mode, got %q", output.String())
+	}
+}
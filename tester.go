@@ -2,9 +2,10 @@ package main
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
+	"go6502/display"
 	"go6502/go6502"
 	"log"
-	"sync"
+	"os"
 )
 
 const (
@@ -23,6 +24,7 @@ func main() {
 		firstRamSegment,
 		screen,
 		secondRamSegment))
+	cpu.Tracer = go6502.NewPrintTracer(os.Stdout, nil)
 
 	//Reset vector
 	cpu.Memory.Set(go6502.ResetVectorL, 0x00, 0x00)
@@ -38,23 +40,15 @@ func main() {
 		cpu.Memory.Set(uint16(addr), go6502.OpSTA_absolute, uint8(pixelAddress%256), uint8(pixelAddress/256)) //Beginning of pixel memory
 	}
 
+	programEnd := uint16(0x0002 + 0x03*go6502.BlocksInLine*8)
+	cpu.Memory.Set(programEnd, go6502.OpJMP_absolute, uint8(programEnd%256), uint8(programEnd/256))
+
 	ebiten.SetWindowSize(640, 480)
 	ebiten.SetWindowTitle("Go6502")
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	go func() {
-		cpu.Initialize()
-		for i := 0; i < go6502.BlocksInLine*8; i++ {
-			cpu.Advance()
-		}
-		wg.Done()
-	}()
-
-	if err := ebiten.RunGame(screen); err != nil {
+	// Drawing takes a few seconds, hold Backspace to rewind it
+	cpu.Initialize()
+	if err := ebiten.RunGame(display.NewEmulatorWindow(cpu, screen, 2, 10)); err != nil {
 		log.Fatal(err)
 	}
-
-	wg.Wait()
 }